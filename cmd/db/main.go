@@ -3,9 +3,16 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
+	"log"
 	"os"
 
 	"github.com/janschill/track-me/internal/db"
+	"github.com/janschill/track-me/internal/export"
+	"github.com/janschill/track-me/internal/importer"
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/internal/service"
+	"github.com/janschill/track-me/pkg/gpx"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -13,12 +20,222 @@ var (
 	dbPath    string
 	operation string
 	day       string
+	format    string
+	tripID    int64
+	from      string
+	to        string
+	out       string
+	file      string
+	demPath   string
+	timezone  string
+	units     string
+	routePath string
 )
 
 func init() {
 	flag.StringVar(&dbPath, "dbpath", "./data/trips.db", "Path to the database file.")
 	flag.StringVar(&operation, "operation", "", "Database operation to perform: create, reset, destroy.")
 	flag.StringVar(&day, "day", "", "Day for which to aggregate in yyyy-mm-dd")
+	flag.StringVar(&format, "format", "geojson", "Export format: geojson, kml, csv.")
+	flag.Int64Var(&tripID, "trip", 1, "Trip to export or import into.")
+	flag.StringVar(&from, "from", "", "First day to export in yyyy-mm-dd")
+	flag.StringVar(&to, "to", "", "Last day to export in yyyy-mm-dd")
+	flag.StringVar(&out, "out", "", "File to write the export to. Defaults to stdout.")
+	flag.StringVar(&file, "file", "", "GPX or FIT file to import.")
+	flag.StringVar(&demPath, "dem", "./data/dem", "Directory with SRTM .hgt tiles for altitude correction.")
+	flag.StringVar(&timezone, "timezone", "", "Timezone of the trip, e.g. America/Denver, or auto to follow the rider's position.")
+	flag.StringVar(&units, "units", "", "Default unit system of the trip: metric or imperial.")
+	flag.StringVar(&routePath, "route", "web/assets/gpx/Great_Divide_2024.gpx", "GPX file of the planned route to locate points of interest on.")
+}
+
+func exportEvents() {
+	Db, err := db.InitializeDB(dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer Db.Close()
+	if err := db.Migrate(Db); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+	repo := repository.NewRepository(Db)
+	loc := service.NewTripService(repo).Location(tripID)
+
+	exporter, err := export.New(format, loc)
+	if err != nil {
+		log.Fatal(err)
+	}
+	start, end, err := export.ParseDateRange(from, to, loc)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	events, err := repo.Events.AllInRange(tripID, start, end)
+	if err != nil {
+		log.Fatalf("Failed to read events: %v", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if out != "" {
+		file, err := os.Create(out)
+		if err != nil {
+			log.Fatalf("Failed to create export file: %v", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if err := exporter.Export(w, events); err != nil {
+		log.Fatalf("Failed to export events: %v", err)
+	}
+	log.Printf("Exported %d events as %s", len(events), format)
+}
+
+func importEvents() {
+	if file == "" {
+		fmt.Println("Usage: go run main.go -dbpath=<path-to-db> -operation=import-db -file=<ride.gpx|ride.fit> [-trip=<id>]")
+		os.Exit(1)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", file, err)
+	}
+	defer f.Close()
+
+	points, source, err := importer.Parse(file, f)
+	if err != nil {
+		log.Fatalf("Failed to parse %s: %v", file, err)
+	}
+
+	Db, err := db.InitializeDB(dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer Db.Close()
+	if err := db.Migrate(Db); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	// The running server notices the new events per day and recomputes those days
+	result, err := importer.New(repository.NewEventRepository(Db)).Import(tripID, source, points)
+	if err != nil {
+		log.Fatalf("Failed to import %s: %v", file, err)
+	}
+	log.Printf("Imported %d points, skipped %d already covered points", result.Imported, result.Skipped)
+}
+
+func correctAltitudes() {
+	Db, err := db.InitializeDB(dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer Db.Close()
+	if err := db.Migrate(Db); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	elevationService := service.NewElevationService(repository.NewRepository(Db), demPath)
+	count, err := elevationService.Backfill(tripID)
+	if err != nil {
+		log.Fatalf("Failed to correct altitudes: %v", err)
+	}
+	log.Printf("Corrected the altitude of %d events", count)
+}
+
+func setTimezone() {
+	if timezone == "" {
+		fmt.Println("Usage: go run main.go -dbpath=<path-to-db> -operation=timezone-db -timezone=<America/Denver|auto> [-trip=<id>]")
+		os.Exit(1)
+	}
+
+	Db, err := db.InitializeDB(dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer Db.Close()
+	if err := db.Migrate(Db); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	tripService := service.NewTripService(repository.NewRepository(Db))
+	if err := tripService.SetTimezone(tripID, timezone); err != nil {
+		log.Fatalf("Failed to set timezone: %v", err)
+	}
+	log.Printf("Trip %d uses %s, days are split in %s", tripID, timezone, tripService.Location(tripID))
+}
+
+func setDates() {
+	if from == "" {
+		fmt.Println("Usage: go run main.go -dbpath=<path-to-db> -operation=dates-db -from=<yyyy-mm-dd> [-to=<yyyy-mm-dd>] [-trip=<id>]")
+		os.Exit(1)
+	}
+
+	Db, err := db.InitializeDB(dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer Db.Close()
+	if err := db.Migrate(Db); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	tripService := service.NewTripService(repository.NewRepository(Db))
+	if err := tripService.SetDates(tripID, from, to); err != nil {
+		log.Fatalf("Failed to set dates: %v", err)
+	}
+	if to == "" {
+		log.Printf("Trip %d starts on %s and stays open", tripID, from)
+		return
+	}
+	log.Printf("Trip %d runs from %s to %s", tripID, from, to)
+}
+
+func setUnits() {
+	Db, err := db.InitializeDB(dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer Db.Close()
+	if err := db.Migrate(Db); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	if err := service.NewTripService(repository.NewRepository(Db)).SetUnits(tripID, units); err != nil {
+		log.Fatalf("Failed to set units: %v", err)
+	}
+	log.Printf("Trip %d uses %s units by default", tripID, units)
+}
+
+func importPOIs() {
+	if file == "" {
+		fmt.Println("Usage: go run main.go -dbpath=<path-to-db> -operation=poi-db -file=<waypoints.gpx> [-route=<route.gpx>] [-trip=<id>]")
+		os.Exit(1)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", file, err)
+	}
+	defer f.Close()
+
+	waypoints, err := gpx.Decode(f)
+	if err != nil {
+		log.Fatalf("Failed to parse %s: %v", file, err)
+	}
+
+	Db, err := db.InitializeDB(dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer Db.Close()
+	if err := db.Migrate(Db); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	routeService := service.NewRouteService(repository.NewRepository(Db), routePath)
+	imported, skipped, err := routeService.ImportPOIs(tripID, waypoints.Waypoints)
+	if err != nil {
+		log.Fatalf("Failed to import points of interest: %v", err)
+	}
+	log.Printf("Imported %d points of interest, skipped %d off the route", imported, skipped)
 }
 
 func main() {
@@ -40,6 +257,20 @@ func main() {
 		db.Seed(dbPath)
 	case "clear-db":
 		db.Clear(dbPath)
+	case "export-db":
+		exportEvents()
+	case "import-db":
+		importEvents()
+	case "correct-db":
+		correctAltitudes()
+	case "timezone-db":
+		setTimezone()
+	case "dates-db":
+		setDates()
+	case "units-db":
+		setUnits()
+	case "poi-db":
+		importPOIs()
 	case "aggregate-db":
 		fmt.Println("TODO: not supported")
 		return
@@ -2,119 +2,242 @@ package main
 
 import (
 	"encoding/json"
-	"encoding/xml"
+	"flag"
 	"fmt"
-	"io"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/janschill/track-me/internal/export"
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/internal/utils"
+	"github.com/janschill/track-me/pkg/gpx"
 )
 
-type GPX struct {
-	XMLName xml.Name `xml:"gpx"`
-	Trk     Trk      `xml:"trk"`
-}
+var (
+	format  string
+	workers int
+)
 
-type Trk struct {
-	TrkSeg TrkSeg `xml:"trkseg"`
+type summary struct {
+	Input         string
+	Output        string
+	Points        int
+	Distance      float64
+	MovingTime    float64
+	AverageSpeed  float64
+	ElevationGain int64
+	ElevationLoss int64
+	Err           error
 }
 
-type TrkSeg struct {
-	TrkPts []TrkPt `xml:"trkpt"`
+func init() {
+	formats := append([]string{"json"}, export.Formats()...)
+	flag.StringVar(&format, "format", "json", "Output format: "+strings.Join(formats, ", ")+".")
+	flag.IntVar(&workers, "workers", runtime.NumCPU(), "Number of files converted concurrently.")
+	flag.Usage = func() {
+		fmt.Println("Usage: go run main.go [-format=json] [-workers=n] <input.gpx|input-dir> <output-file|output-dir>")
+		flag.PrintDefaults()
+	}
 }
 
-type TrkPt struct {
-	Lat  float64 `xml:"lat,attr"`
-	Lon  float64 `xml:"lon,attr"`
-	Ele  float64 `xml:"ele"`
-	Time string  `xml:"time"`
-}
+func readEvents(path string) ([]repository.Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening GPX file: %w", err)
+	}
+	defer file.Close()
 
-type Point struct {
-	Longitude float64 `json:"longitude"`
-	Latitude  float64 `json:"latitude"`
-	Altitude  float64 `json:"altitude"`
-	TimeStamp int64   `json:"timestamp"`
-}
+	g, err := gpx.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing GPX file: %w", err)
+	}
 
-type Output struct {
-	Distance      string  `json:"distance"`
-	MovingTime    string  `json:"movingTime"`
-	AverageSpeed  string  `json:"averageSpeed"`
-	ElevationGain string  `json:"elevationGain"`
-	ElevationLoss string  `json:"elevationLoss"`
-	Points        []Point `json:"points"`
+	var points []utils.TestPoint
+	for _, p := range g.Points() {
+		var timestamp int64
+		if !p.Time.IsZero() {
+			timestamp = p.Time.Unix()
+		}
+		points = append(points, utils.TestPoint{
+			Longitude: p.Longitude,
+			Latitude:  p.Latitude,
+			Altitude:  p.Elevation,
+			TimeStamp: timestamp,
+		})
+	}
+	return utils.ConvertPointsToEvents(points), nil
 }
 
-func main() {
-	if len(os.Args) < 3 {
-		fmt.Println("Usage: go run main.go <input.gpx> <output.json>")
-		return
+func writeOutput(path string, s summary, events []repository.Event) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating output file: %w", err)
+	}
+	defer file.Close()
+
+	if format != "json" {
+		exporter, err := export.New(format, time.UTC)
+		if err != nil {
+			return err
+		}
+		return exporter.Export(file, events)
 	}
 
-	inputFile := os.Args[1]
-	outputFile := os.Args[2]
+	// Same structure as the test data in internal/utils/test_data
+	points := make([]utils.TestPoint, len(events))
+	for i, e := range events {
+		points[i] = utils.TestPoint{Longitude: e.Longitude, Latitude: e.Latitude, Altitude: e.Altitude, TimeStamp: e.TimeStamp}
+	}
+	output := utils.GPXData{
+		Distance:      fmt.Sprintf("%.0f", s.Distance),
+		MovingTime:    fmt.Sprintf("%.0f", s.MovingTime),
+		AverageSpeed:  fmt.Sprintf("%.1f", s.AverageSpeed),
+		ElevationGain: fmt.Sprintf("%d", s.ElevationGain),
+		ElevationLoss: fmt.Sprintf("%d", s.ElevationLoss),
+		Points:        points,
+	}
 
-	file, err := os.Open(inputFile)
+	jsonData, err := json.MarshalIndent(output, "", "  ")
 	if err != nil {
-		fmt.Printf("Error opening GPX file: %v\n", err)
-		return
+		return fmt.Errorf("error marshalling JSON: %w", err)
 	}
-	defer file.Close()
+	_, err = file.Write(append(jsonData, '\n'))
+	return err
+}
 
-	gpxData, err := io.ReadAll(file)
+func convert(input, output string) summary {
+	s := summary{Input: input, Output: output}
+
+	events, err := readEvents(input)
 	if err != nil {
-		fmt.Printf("Error reading GPX file: %v\n", err)
-		return
+		s.Err = err
+		return s
 	}
 
-	var gpx GPX
-	err = xml.Unmarshal(gpxData, &gpx)
+	s.Points = len(events)
+	s.Distance = utils.DistanceInMeters(events)
+	s.MovingTime, s.AverageSpeed, _ = utils.CalculateMovingTimeAndAverageSpeed(events, 0.001)
+	s.ElevationGain, s.ElevationLoss = utils.CalculateElevationGainAndLoss(events)
+
+	s.Err = writeOutput(output, s, events)
+	return s
+}
+
+func extension() string {
+	if format == "json" {
+		return "json"
+	}
+	exporter, err := export.New(format, time.UTC)
 	if err != nil {
-		fmt.Printf("Error parsing GPX file: %v\n", err)
-		return
+		return format
+	}
+	return exporter.Extension()
+}
+
+// convertDir converts all GPX files of a directory concurrently.
+// Summaries are returned in file name order so runs are reproducible.
+func convertDir(inputDir, outputDir string) ([]summary, error) {
+	inputs, err := filepath.Glob(filepath.Join(inputDir, "*.gpx"))
+	if err != nil {
+		return nil, err
 	}
+	sort.Strings(inputs)
 
-	var points []Point
-	for _, trkPt := range gpx.Trk.TrkSeg.TrkPts {
-		var timestamp time.Time
-		if trkPt.Time != "" {
-			parsedTimestamp, err := time.Parse(time.RFC3339, trkPt.Time)
-			if err != nil {
-				fmt.Printf("Error parsing time: %v\n", err)
-				return
-			} else {
-				timestamp = parsedTimestamp
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, err
+	}
+
+	summaries := make([]summary, len(inputs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < max(workers, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				name := strings.TrimSuffix(filepath.Base(inputs[i]), filepath.Ext(inputs[i]))
+				summaries[i] = convert(inputs[i], filepath.Join(outputDir, name+"."+extension()))
 			}
+		}()
+	}
+	for i := range inputs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
 
+	return summaries, nil
+}
+
+func printSummaries(summaries []summary) (failed int) {
+	var total summary
+	for _, s := range summaries {
+		if s.Err != nil {
+			fmt.Printf("%s: %v\n", s.Input, s.Err)
+			failed++
+			continue
 		}
-		points = append(points, Point{
-			Longitude: trkPt.Lon,
-			Latitude:  trkPt.Lat,
-			Altitude:  trkPt.Ele,
-			TimeStamp: timestamp.Unix(),
-		})
+		fmt.Printf("%s: %d points, %.1f km, moving %s, %.1f km/h, +%d m / -%d m -> %s\n",
+			s.Input, s.Points, utils.InKm(s.Distance), utils.FormatTime(int64(s.MovingTime)), s.AverageSpeed, s.ElevationGain, s.ElevationLoss, s.Output)
+
+		total.Points += s.Points
+		total.Distance += s.Distance
+		total.MovingTime += s.MovingTime
+		total.ElevationGain += s.ElevationGain
+		total.ElevationLoss += s.ElevationLoss
 	}
 
-	output := Output{
-		Distance:      "TODO",
-		MovingTime:    "TODO",
-		AverageSpeed:  "TODO",
-		ElevationGain: "TODO",
-		ElevationLoss: "TODO",
-		Points:        points,
+	if len(summaries) > 1 {
+		if total.MovingTime > 0 {
+			total.AverageSpeed = total.Distance / total.MovingTime * 3.6
+		}
+		fmt.Printf("Total: %d files, %d points, %.1f km, moving %s, %.1f km/h, +%d m / -%d m\n",
+			len(summaries)-failed, total.Points, utils.InKm(total.Distance), utils.FormatTime(int64(total.MovingTime)), total.AverageSpeed, total.ElevationGain, total.ElevationLoss)
 	}
+	return failed
+}
 
-	jsonData, err := json.MarshalIndent(output, "", "  ")
-	if err != nil {
-		fmt.Printf("Error marshalling JSON: %v\n", err)
+func main() {
+	flag.Parse()
+	if flag.NArg() < 2 {
+		flag.Usage()
 		return
 	}
 
-	err = os.WriteFile(outputFile, jsonData, 0644)
+	if format != "json" {
+		if _, err := export.New(format, time.UTC); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	input := flag.Arg(0)
+	output := flag.Arg(1)
+
+	info, err := os.Stat(input)
 	if err != nil {
-		fmt.Printf("Error writing JSON file: %v\n", err)
-		return
+		fmt.Printf("Error opening input: %v\n", err)
+		os.Exit(1)
 	}
 
-	fmt.Println("Successfully converted GPX to JSON")
+	var summaries []summary
+	if info.IsDir() {
+		summaries, err = convertDir(input, output)
+		if err != nil {
+			fmt.Printf("Error converting directory: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		summaries = []summary{convert(input, output)}
+	}
+
+	if failed := printSummaries(summaries); failed > 0 {
+		os.Exit(1)
+	}
+	fmt.Printf("Successfully converted GPX to %s\n", format)
 }
@@ -4,18 +4,24 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
+	"time"
 
 	"github.com/janschill/track-me/internal/server"
 )
 
 func main() {
-	ping := flag.Bool("ping", false, "Run a self-test and exit")
+	ping := flag.Bool("ping", false, "Test the database and templates on a copy of the database and exit")
+	dev := flag.Bool("dev", false, "Read templates and assets from web/ on every request")
 	flag.Parse()
 
 	if *ping {
+		if err := server.SelfTest(); err != nil {
+			slog.Error("Self-test failed", "error", err)
+			os.Exit(1)
+		}
 		fmt.Println("Self-test passed")
 		os.Exit(0)
 	}
@@ -24,19 +30,24 @@ func main() {
 	defer stop()
 
 	port := "8080"
-	log.Default().Println("Server starting on port " + port)
-	srv := server.HttpServer(port, ctx)
+	slog.Info("Server starting", "port", port)
+	srv := server.HttpServer(port, ctx, *dev)
 	srvErr := make(chan error, 1)
 	go func() {
 		srvErr <- srv.ListenAndServe()
 	}()
 	select {
 	case err := <-srvErr:
-		log.Fatalf("HTTP server error: %v", err)
+		slog.Error("HTTP server error", "error", err)
+		os.Exit(1)
 	case <-ctx.Done():
 		stop()
 	}
-	if err := srv.Shutdown(context.Background()); err != nil {
-		log.Fatalf("HTTP server shutdown error: %v", err)
+	// Live streams end with the server context, give them time to say goodbye
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("HTTP server shutdown error", "error", err)
+		os.Exit(1)
 	}
 }
@@ -1,12 +1,26 @@
 package config
 
 import (
-	"log"
+	"fmt"
+	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/janschill/track-me/internal/logging"
+	"github.com/janschill/track-me/internal/utils"
 	"github.com/joho/godotenv"
 )
 
+// The planned route the map shows
+const defaultRoutePath = "web/assets/gpx/Great_Divide_2024.gpx"
+
+// Silence of the tracker after which the watchdog alerts, one per escalation level
+const defaultWatchdogThresholds = "2h,6h,24h"
+
+const defaultWatchdogInterval = 5 * time.Minute
+
 type Config struct {
 	DatabaseURL              string
 	SentryDsn                string
@@ -15,11 +29,70 @@ type Config struct {
 	GarminIpcInboundEmail    string
 	GarminIpcInboundPassword string
 	ICloudAlbumToken         string
+	DemPath                  string
+	PlacesPath               string
+	RoutePath                string
+	ElevationThreshold       float64
+	LogLevel                 slog.Level
+	// Increasing, the watchdog escalates when the tracker is silent for longer
+	WatchdogThresholds []time.Duration
+	WatchdogInterval   time.Duration
+	// SMTP relay like localhost:25, alerts are mailed when set
+	AlertSMTPAddr     string
+	AlertSMTPFrom     string
+	AlertSMTPTo       []string
+	AlertSMTPUsername string
+	AlertSMTPPassword string
+	// Alerts are posted as JSON when set
+	AlertWebhookURL string
 }
 
 func LoadConfig() (*Config, error) {
 	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
+		slog.Info("No .env file found")
+	}
+
+	elevationThreshold := utils.DefaultElevationThreshold
+	if threshold := os.Getenv("ELEVATION_THRESHOLD"); threshold != "" {
+		var err error
+		elevationThreshold, err = strconv.ParseFloat(threshold, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ELEVATION_THRESHOLD: %w", err)
+		}
+	}
+
+	logLevel, err := logging.ParseLevel(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOG_LEVEL: %w", err)
+	}
+
+	thresholds := os.Getenv("WATCHDOG_THRESHOLDS")
+	if thresholds == "" {
+		thresholds = defaultWatchdogThresholds
+	}
+	watchdogThresholds, err := parseThresholds(thresholds)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WATCHDOG_THRESHOLDS: %w", err)
+	}
+
+	watchdogInterval := defaultWatchdogInterval
+	if interval := os.Getenv("WATCHDOG_INTERVAL"); interval != "" {
+		watchdogInterval, err = time.ParseDuration(interval)
+		if err != nil || watchdogInterval <= 0 {
+			return nil, fmt.Errorf("invalid WATCHDOG_INTERVAL: %q", interval)
+		}
+	}
+
+	var alertSMTPTo []string
+	for _, to := range strings.Split(os.Getenv("ALERT_SMTP_TO"), ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			alertSMTPTo = append(alertSMTPTo, to)
+		}
+	}
+
+	routePath := os.Getenv("ROUTE_PATH")
+	if routePath == "" {
+		routePath = defaultRoutePath
 	}
 
 	return &Config{
@@ -30,5 +103,34 @@ func LoadConfig() (*Config, error) {
 		GarminIpcInboundEmail:    os.Getenv("GARMIN_IPC_INBOUND_EMAIL"),
 		GarminIpcInboundPassword: os.Getenv("GARMIN_IPC_INBOUND_PASSWORD"),
 		ICloudAlbumToken:         os.Getenv("ICLOUD_ALBUM_TOKEN"),
+		DemPath:                  os.Getenv("DEM_PATH"),
+		PlacesPath:               os.Getenv("PLACES_PATH"),
+		RoutePath:                routePath,
+		ElevationThreshold:       elevationThreshold,
+		LogLevel:                 logLevel,
+		WatchdogThresholds:       watchdogThresholds,
+		WatchdogInterval:         watchdogInterval,
+		AlertSMTPAddr:            os.Getenv("ALERT_SMTP_ADDR"),
+		AlertSMTPFrom:            os.Getenv("ALERT_SMTP_FROM"),
+		AlertSMTPTo:              alertSMTPTo,
+		AlertSMTPUsername:        os.Getenv("ALERT_SMTP_USERNAME"),
+		AlertSMTPPassword:        os.Getenv("ALERT_SMTP_PASSWORD"),
+		AlertWebhookURL:          os.Getenv("ALERT_WEBHOOK_URL"),
 	}, nil
 }
+
+// parseThresholds reads a list like 2h,6h,24h, which has to increase
+func parseThresholds(s string) ([]time.Duration, error) {
+	var thresholds []time.Duration
+	for _, part := range strings.Split(s, ",") {
+		threshold, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		if threshold <= 0 || (len(thresholds) > 0 && threshold <= thresholds[len(thresholds)-1]) {
+			return nil, fmt.Errorf("thresholds have to be positive and increasing: %s", s)
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	return thresholds, nil
+}
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"log/slog"
 	"os"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -27,3 +30,71 @@ func TestLoadConfig(t *testing.T) {
 	os.Unsetenv("DB_PATH")
 	os.Unsetenv("SENTRY_DSN")
 }
+
+func TestLoadConfigElevationThreshold(t *testing.T) {
+	os.Setenv("ELEVATION_THRESHOLD", "3.5")
+	defer os.Unsetenv("ELEVATION_THRESHOLD")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v, wantErr %v", err, false)
+	}
+	if cfg.ElevationThreshold != 3.5 {
+		t.Errorf("LoadConfig().ElevationThreshold = %v, want %v", cfg.ElevationThreshold, 3.5)
+	}
+
+	os.Setenv("ELEVATION_THRESHOLD", "steep")
+	if _, err := LoadConfig(); err == nil {
+		t.Errorf("LoadConfig() error = %v, wantErr %v", err, true)
+	}
+}
+
+func TestLoadConfigLogLevel(t *testing.T) {
+	os.Setenv("LOG_LEVEL", "debug")
+	defer os.Unsetenv("LOG_LEVEL")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v, wantErr %v", err, false)
+	}
+	if cfg.LogLevel != slog.LevelDebug {
+		t.Errorf("LoadConfig().LogLevel = %v, want %v", cfg.LogLevel, slog.LevelDebug)
+	}
+
+	os.Setenv("LOG_LEVEL", "loud")
+	if _, err := LoadConfig(); err == nil {
+		t.Errorf("LoadConfig() error = %v, wantErr %v", err, true)
+	}
+}
+
+func TestLoadConfigWatchdog(t *testing.T) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v, wantErr %v", err, false)
+	}
+	if !reflect.DeepEqual(cfg.WatchdogThresholds, []time.Duration{2 * time.Hour, 6 * time.Hour, 24 * time.Hour}) || cfg.WatchdogInterval != 5*time.Minute {
+		t.Errorf("LoadConfig() watchdog = %v every %v, want the defaults", cfg.WatchdogThresholds, cfg.WatchdogInterval)
+	}
+
+	os.Setenv("WATCHDOG_THRESHOLDS", "30m, 90m")
+	os.Setenv("ALERT_SMTP_TO", "rider@example.com, ,home@example.com")
+	defer os.Unsetenv("WATCHDOG_THRESHOLDS")
+	defer os.Unsetenv("ALERT_SMTP_TO")
+	cfg, err = LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v, wantErr %v", err, false)
+	}
+	if !reflect.DeepEqual(cfg.WatchdogThresholds, []time.Duration{30 * time.Minute, 90 * time.Minute}) {
+		t.Errorf("LoadConfig().WatchdogThresholds = %v, want [30m 1h30m]", cfg.WatchdogThresholds)
+	}
+	if !reflect.DeepEqual(cfg.AlertSMTPTo, []string{"rider@example.com", "home@example.com"}) {
+		t.Errorf("LoadConfig().AlertSMTPTo = %v, want both addresses", cfg.AlertSMTPTo)
+	}
+
+	for _, thresholds := range []string{"6h,2h", "2h,2h", "-1h", "soon"} {
+		os.Setenv("WATCHDOG_THRESHOLDS", thresholds)
+		if _, err := LoadConfig(); err == nil {
+			t.Errorf("LoadConfig() with WATCHDOG_THRESHOLDS=%s error = %v, wantErr %v", thresholds, err, true)
+		}
+	}
+}
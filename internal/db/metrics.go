@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"time"
+
+	"github.com/janschill/track-me/pkg/metrics"
+	"github.com/mattn/go-sqlite3"
+)
+
+// timedDriverName is the sqlite3 driver timing every statement
+const timedDriverName = "sqlite3_timed"
+
+var queryDuration = metrics.NewHistogramVec(
+	"sqlite_query_duration_seconds",
+	"Time SQLite statements take by operation, queries until their rows are closed.",
+	[]float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1},
+	"operation",
+)
+
+func init() {
+	sql.Register(timedDriverName, timedDriver{&sqlite3.SQLiteDriver{}})
+}
+
+// operation is the kind of a statement, a label with few values
+func operation(query string) string {
+	keyword, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	switch keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword {
+	case "select", "insert", "update", "delete":
+		return keyword
+	case "with":
+		return "select"
+	}
+	return "other"
+}
+
+func observe(op string, start time.Time) {
+	queryDuration.Observe(time.Since(start).Seconds(), op)
+}
+
+type timedDriver struct {
+	driver *sqlite3.SQLiteDriver
+}
+
+func (d timedDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.driver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &timedConn{conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+// timedConn implements the context interfaces database/sql prefers, so all
+// statements pass through it
+type timedConn struct {
+	conn *sqlite3.SQLiteConn
+}
+
+func (c *timedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *timedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.conn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &timedStmt{stmt: stmt.(*sqlite3.SQLiteStmt), op: operation(query)}, nil
+}
+
+func (c *timedConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *timedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *timedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.conn.BeginTx(ctx, opts)
+}
+
+func (c *timedConn) Ping(ctx context.Context) error {
+	return c.conn.Ping(ctx)
+}
+
+func (c *timedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	defer observe(operation(query), time.Now())
+	return c.conn.ExecContext(ctx, query, args)
+}
+
+func (c *timedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := c.conn.QueryContext(ctx, query, args)
+	if err != nil {
+		observe(operation(query), start)
+		return nil, err
+	}
+	return &timedRows{Rows: rows, op: operation(query), start: start}, nil
+}
+
+type timedStmt struct {
+	stmt *sqlite3.SQLiteStmt
+	op   string
+}
+
+func (s *timedStmt) Close() error {
+	return s.stmt.Close()
+}
+
+func (s *timedStmt) NumInput() int {
+	return s.stmt.NumInput()
+}
+
+// Exec and Query are only called by drivers without the context variants
+func (s *timedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	defer observe(s.op, time.Now())
+	return s.stmt.Exec(args)
+}
+
+func (s *timedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := s.stmt.Query(args)
+	if err != nil {
+		observe(s.op, start)
+		return nil, err
+	}
+	return &timedRows{Rows: rows, op: s.op, start: start}, nil
+}
+
+func (s *timedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	defer observe(s.op, time.Now())
+	return s.stmt.ExecContext(ctx, args)
+}
+
+func (s *timedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := s.stmt.QueryContext(ctx, args)
+	if err != nil {
+		observe(s.op, start)
+		return nil, err
+	}
+	return &timedRows{Rows: rows, op: s.op, start: start}, nil
+}
+
+// timedRows observes a query once its rows are read, SQLite runs it while stepping
+type timedRows struct {
+	driver.Rows
+	op    string
+	start time.Time
+}
+
+func (r *timedRows) Close() error {
+	defer observe(r.op, r.start)
+	return r.Rows.Close()
+}
@@ -0,0 +1,189 @@
+package db
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+type Migration struct {
+	Version   int
+	Name      string
+	Statement string
+}
+
+// Migrations are applied in order on top of the schema and never edited once released
+var migrations = []Migration{
+	{
+		Version:   1,
+		Name:      "add source to events",
+		Statement: `ALTER TABLE events ADD COLUMN "source" TEXT NOT NULL DEFAULT 'inreach';`,
+	},
+	{
+		Version:   2,
+		Name:      "add corrected altitude to events",
+		Statement: `ALTER TABLE events ADD COLUMN "correctedAltitude" REAL;`,
+	},
+	{
+		Version: 3,
+		Name:    "create records",
+		Statement: `CREATE TABLE IF NOT EXISTS records (
+			"tripId" INTEGER NOT NULL,
+			"name" TEXT NOT NULL,
+			"value" REAL NOT NULL,
+			"day" TEXT NOT NULL,
+			"timeStamp" INTEGER NOT NULL,
+			PRIMARY KEY ("tripId", "name")
+		);`,
+	},
+	{
+		Version: 4,
+		Name:    "add timezone to trips",
+		// The first trip was ridden on mountain time before trips had a timezone
+		Statement: `ALTER TABLE trips ADD COLUMN "timezone" TEXT NOT NULL DEFAULT 'auto';
+			INSERT OR IGNORE INTO trips(id, description, timezone) VALUES (1, 'The Great Divide Mountain Bike Route', 'America/Denver');
+			UPDATE trips SET timezone = 'America/Denver' WHERE id = 1 AND timezone = 'auto';`,
+	},
+	{
+		Version:   5,
+		Name:      "add units to trips",
+		Statement: `ALTER TABLE trips ADD COLUMN "units" TEXT NOT NULL DEFAULT 'metric';`,
+	},
+	{
+		Version: 6,
+		Name:    "create pois",
+		Statement: `CREATE TABLE IF NOT EXISTS pois (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"tripId" INTEGER NOT NULL,
+			"name" TEXT NOT NULL,
+			"kind" TEXT NOT NULL,
+			"description" TEXT NOT NULL DEFAULT '',
+			"latitude" REAL NOT NULL,
+			"longitude" REAL NOT NULL,
+			"routeDistance" REAL NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS pois_trip_route_distance ON pois("tripId", "routeDistance");`,
+	},
+	{
+		Version:   7,
+		Name:      "add updated at to kudos",
+		Statement: `ALTER TABLE kudos ADD COLUMN "updatedAt" INTEGER NOT NULL DEFAULT 0;`,
+	},
+	{
+		Version: 8,
+		Name:    "create alerts",
+		Statement: `CREATE TABLE IF NOT EXISTS alerts (
+			"tripId" INTEGER PRIMARY KEY,
+			"level" INTEGER NOT NULL,
+			"lastPositionAt" INTEGER NOT NULL,
+			"notifiedAt" INTEGER NOT NULL,
+			FOREIGN KEY(tripId) REFERENCES trips(id)
+		);`,
+	},
+	{
+		Version: 9,
+		Name:    "create webhooks",
+		// Pending deliveries are the queue, the others the delivery log
+		Statement: `CREATE TABLE IF NOT EXISTS webhooks (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"url" TEXT NOT NULL,
+			"secret" TEXT NOT NULL,
+			"events" TEXT NOT NULL,
+			"createdAt" INTEGER NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+			"webhookId" INTEGER NOT NULL,
+			"event" TEXT NOT NULL,
+			"payload" TEXT NOT NULL,
+			"status" TEXT NOT NULL,
+			"attempts" INTEGER NOT NULL DEFAULT 0,
+			"nextAttemptAt" INTEGER NOT NULL,
+			"lastAttemptAt" INTEGER NOT NULL DEFAULT 0,
+			"responseStatus" INTEGER NOT NULL DEFAULT 0,
+			"error" TEXT NOT NULL DEFAULT '',
+			"createdAt" INTEGER NOT NULL,
+			FOREIGN KEY(webhookId) REFERENCES webhooks(id)
+		);
+		CREATE INDEX IF NOT EXISTS webhook_deliveries_queue ON webhook_deliveries("status", "nextAttemptAt");
+		CREATE INDEX IF NOT EXISTS webhook_deliveries_log ON webhook_deliveries("webhookId", "id");`,
+	},
+}
+
+func Migrate(Db *sql.DB) error {
+	_, err := Db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		appliedAt INTEGER NOT NULL
+	);`)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		var applied int
+		err := Db.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE version = ?", m.Version).Scan(&applied)
+		if err != nil {
+			return err
+		}
+		if applied > 0 {
+			continue
+		}
+
+		tx, err := Db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(m.Statement); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO schema_migrations(version, name, appliedAt) VALUES(?,?,?)", m.Version, m.Name, time.Now().Unix()); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		slog.Info("Applied migration", "version", m.Version, "migration", m.Name)
+	}
+
+	return nil
+}
+
+// Pending returns the versions of the migrations not applied yet, all of them
+// on a database that was never migrated
+func Pending(Db *sql.DB) ([]int, error) {
+	var tables int
+	err := Db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'").Scan(&tables)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]bool)
+	if tables > 0 {
+		rows, err := Db.Query("SELECT version FROM schema_migrations")
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var version int
+			if err := rows.Scan(&version); err != nil {
+				return nil, err
+			}
+			applied[version] = true
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	var pending []int
+	for _, m := range migrations {
+		if !applied[m.Version] {
+			pending = append(pending, m.Version)
+		}
+	}
+	return pending, nil
+}
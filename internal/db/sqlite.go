@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql"
 	"log"
+	"log/slog"
 	"os"
 	"time"
 
@@ -10,8 +11,9 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// InitializeDB opens the database and times its statements for /metrics
 func InitializeDB(filePath string) (*sql.DB, error) {
-	Db, err := sql.Open("sqlite3", filePath)
+	Db, err := sql.Open(timedDriverName, filePath)
 	if err != nil {
 		return nil, err
 	}
@@ -20,7 +22,7 @@ func InitializeDB(filePath string) (*sql.DB, error) {
 		return nil, err
 	}
 
-	log.Println("Database connection established")
+	slog.Info("Database connection established")
 
 	return Db, nil
 }
@@ -31,7 +33,7 @@ func DestroyDB(filePath string) {
 		log.Fatalf("Failed to delete database file: %v", err)
 	}
 
-	log.Println("Database file deleted successfully.")
+	slog.Info("Database file deleted successfully")
 }
 
 func CreateTables(filePath string) {
@@ -43,11 +45,15 @@ func CreateTables(filePath string) {
 	for _, table := range schema.Tables {
 		_, err := Db.Exec(table.Definition)
 		if err != nil {
-			log.Printf("Failed to create table %s: %v", table.Name, err)
+			slog.Error("Failed to create table", "table", table.Name, "error", err)
 			return
 		}
 	}
-	log.Println("All tables created successfully.")
+	if err := Migrate(Db); err != nil {
+		slog.Error("Failed to migrate database", "error", err)
+		return
+	}
+	slog.Info("All tables created successfully")
 }
 
 func Clear(filePath string) {
@@ -59,11 +65,11 @@ func Clear(filePath string) {
 	for _, table := range schema.Tables {
 		_, err := Db.Exec("DELETE FROM " + table.Name)
 		if err != nil {
-			log.Printf("Failed to clear table %s: %v", table.Name, err)
+			slog.Error("Failed to clear table", "table", table.Name, "error", err)
 			return
 		}
 	}
-	log.Println("All data cleared from the database.")
+	slog.Info("All data cleared from the database")
 }
 
 func Seed(filePath string) {
@@ -88,7 +94,7 @@ func Seed(filePath string) {
 	for _, point := range data.Points {
 		timeStamp := currentTime.Unix()
 		_, err = Db.Exec("INSERT INTO events(tripId, imei, messageCode, timeStamp, latitude, longitude, altitude, gpsFix, course, speed, autonomous, lowBattery, intervalChange, resetDetected) VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?)",
-			1, "fake-imei", 0, timeStamp, point.Latitude, point.Longitude, point.Altitude, 0, 0, 0, 0, 0, 0, 0)
+			1, "fake-imei", 0, timeStamp, point.Latitude, point.Longitude, point.Altitude, 3, 0, 0, 0, 0, 0, 0)
 		if err != nil {
 			log.Fatal("Failed to insert into events table:", err)
 		}
@@ -0,0 +1,75 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/janschill/track-me/internal/repository"
+)
+
+// CSV exports every column of the events table, one row per event
+type CSV struct{}
+
+var csvHeader = []string{
+	"id", "tripId", "imei", "messageCode", "freeText", "timeStamp", "time",
+	"latitude", "longitude", "altitude", "gpsFix", "course", "speed",
+	"autonomous", "lowBattery", "intervalChange", "resetDetected", "source", "correctedAltitude",
+}
+
+func (c *CSV) ContentType() string {
+	return "text/csv"
+}
+
+func (c *CSV) Extension() string {
+	return "csv"
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func formatOptionalFloat(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return formatFloat(*f)
+}
+
+func (c *CSV) Export(w io.Writer, events []repository.Event) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		record := []string{
+			strconv.FormatInt(e.ID, 10),
+			strconv.FormatInt(e.TripID, 10),
+			e.Imei,
+			strconv.Itoa(e.MessageCode),
+			e.FreeText,
+			strconv.FormatInt(e.TimeStamp, 10),
+			time.Unix(e.TimeStamp, 0).UTC().Format(time.RFC3339),
+			formatFloat(e.Latitude),
+			formatFloat(e.Longitude),
+			formatFloat(e.Altitude),
+			strconv.Itoa(e.GpsFix),
+			formatFloat(e.Course),
+			formatFloat(e.Speed),
+			strconv.Itoa(e.Status.Autonomous),
+			strconv.Itoa(e.Status.LowBattery),
+			strconv.Itoa(e.Status.IntervalChange),
+			strconv.Itoa(e.Status.ResetDetected),
+			e.Source,
+			formatOptionalFloat(e.CorrectedAltitude),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
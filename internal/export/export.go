@@ -0,0 +1,118 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/internal/utils"
+)
+
+// Exporter writes the events of a trip in a specific file format
+type Exporter interface {
+	ContentType() string
+	Extension() string
+	Export(w io.Writer, events []repository.Event) error
+}
+
+// Stops shorter than this are considered traffic lights, photo breaks etc.
+const (
+	stopRadiusInMeters  = 50
+	stopMinimumDuration = 15 * 60
+)
+
+var exporters = map[string]func(loc *time.Location) Exporter{
+	"geojson":  func(loc *time.Location) Exporter { return &GeoJSON{Location: loc} },
+	"kml":      func(loc *time.Location) Exporter { return &KML{Location: loc} },
+	"csv":      func(loc *time.Location) Exporter { return &CSV{} },
+	"polyline": func(loc *time.Location) Exporter { return &Polyline{} },
+}
+
+// New returns the exporter registered for format. Days are split in loc.
+func New(format string, loc *time.Location) (Exporter, error) {
+	newExporter, ok := exporters[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	return newExporter(loc), nil
+}
+
+func Formats() []string {
+	formats := make([]string, 0, len(exporters))
+	for format := range exporters {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// ParseDateRange turns two yyyy-mm-dd dates into an inclusive unix range.
+// Empty dates leave that side of the range open (0).
+func ParseDateRange(from, to string, loc *time.Location) (int64, int64, error) {
+	var start, end int64
+	if from != "" {
+		t, err := time.ParseInLocation("2006-01-02", from, loc)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid from date: %w", err)
+		}
+		start = t.Unix()
+	}
+	if to != "" {
+		t, err := time.ParseInLocation("2006-01-02", to, loc)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid to date: %w", err)
+		}
+		end = t.AddDate(0, 0, 1).Unix() - 1
+	}
+	if start != 0 && end != 0 && end < start {
+		return 0, 0, fmt.Errorf("from date is after to date")
+	}
+	return start, end, nil
+}
+
+type day struct {
+	Date   string
+	Events []repository.Event
+}
+
+// trackEvents drops events that carry messages or have no position
+func trackEvents(events []repository.Event) []repository.Event {
+	var track []repository.Event
+	for _, e := range events {
+		if utils.HasMessage(e) || (e.Latitude == 0 && e.Longitude == 0) {
+			continue
+		}
+		track = append(track, e)
+	}
+	return track
+}
+
+func messageEvents(events []repository.Event) []repository.Event {
+	var messages []repository.Event
+	for _, e := range events {
+		if utils.HasMessage(e) && (e.Latitude != 0 || e.Longitude != 0) {
+			messages = append(messages, e)
+		}
+	}
+	return messages
+}
+
+func groupByDay(events []repository.Event, loc *time.Location) []day {
+	var days []day
+	for _, e := range events {
+		date := time.Unix(e.TimeStamp, 0).In(loc).Format("2006-01-02")
+		i := slices.IndexFunc(days, func(d day) bool { return d.Date == date })
+		if i == -1 {
+			days = append(days, day{Date: date})
+			i = len(days) - 1
+		}
+		days[i].Events = append(days[i].Events, e)
+	}
+	return days
+}
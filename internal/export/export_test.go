@@ -0,0 +1,120 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/janschill/track-me/internal/repository"
+)
+
+var testEvents = []repository.Event{
+	{ID: 1, TripID: 1, Imei: "123", TimeStamp: 1725840000, Latitude: 48.99, Longitude: -115.05, Altitude: 825},
+	{ID: 2, TripID: 1, Imei: "123", TimeStamp: 1725843600, Latitude: 48.98, Longitude: -115.04, Altitude: 830},
+	{ID: 3, TripID: 1, Imei: "123", MessageCode: 3, FreeText: "Hello, world", TimeStamp: 1725845000, Latitude: 48.97, Longitude: -115.03, Altitude: 831},
+	{ID: 4, TripID: 1, Imei: "123", TimeStamp: 1725926400, Latitude: 48.90, Longitude: -115.00, Altitude: 900},
+	{ID: 5, TripID: 1, Imei: "123", TimeStamp: 1725930000, Latitude: 48.80, Longitude: -114.90, Altitude: 950},
+}
+
+func TestNew(t *testing.T) {
+	for _, format := range Formats() {
+		if _, err := New(format, nil); err != nil {
+			t.Errorf("New(%q) error = %v", format, err)
+		}
+	}
+
+	if _, err := New("shp", nil); err == nil {
+		t.Errorf("New(\"shp\") expected an error, got nil")
+	}
+}
+
+func TestParseDateRange(t *testing.T) {
+	from, to, err := ParseDateRange("2024-09-09", "2024-09-09", time.UTC)
+	if err != nil {
+		t.Fatalf("ParseDateRange() error = %v", err)
+	}
+	if from != 1725840000 || to != 1725926399 {
+		t.Errorf("ParseDateRange() = %d, %d; expected 1725840000, 1725926399", from, to)
+	}
+
+	from, to, err = ParseDateRange("", "", time.UTC)
+	if err != nil || from != 0 || to != 0 {
+		t.Errorf("ParseDateRange(\"\", \"\") = %d, %d, %v; expected open range", from, to, err)
+	}
+
+	if _, _, err := ParseDateRange("2024-09-10", "2024-09-09", time.UTC); err == nil {
+		t.Errorf("ParseDateRange() expected an error for inverted range")
+	}
+}
+
+func TestGeoJSONExport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (&GeoJSON{Location: time.UTC}).Export(&buf, testEvents); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	var collection featureCollection
+	if err := json.Unmarshal(buf.Bytes(), &collection); err != nil {
+		t.Fatalf("Failed to decode GeoJSON: %v", err)
+	}
+
+	kinds := map[string]int{}
+	for _, f := range collection.Features {
+		kinds[f.Properties["kind"].(string)]++
+	}
+	if kinds["day"] != 2 || kinds["message"] != 1 {
+		t.Errorf("Export() feature kinds = %v; expected 2 days and 1 message", kinds)
+	}
+}
+
+func TestKMLExport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (&KML{Location: time.UTC}).Export(&buf, testEvents); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	var root kmlRoot
+	if err := xml.Unmarshal(buf.Bytes(), &root); err != nil {
+		t.Fatalf("Failed to decode KML: %v", err)
+	}
+	if len(root.Document.Placemarks) != 3 {
+		t.Fatalf("Export() returned %d placemarks; expected 3", len(root.Document.Placemarks))
+	}
+	if span := root.Document.Placemarks[0].TimeSpan; span == nil || span.Begin != "2024-09-09T00:00:00Z" {
+		t.Errorf("Export() first placemark time span = %+v; expected begin 2024-09-09T00:00:00Z", span)
+	}
+}
+
+func TestCSVExport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (&CSV{}).Export(&buf, testEvents); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	records, err := csv.NewReader(strings.NewReader(buf.String())).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to decode CSV: %v", err)
+	}
+	if len(records) != len(testEvents)+1 {
+		t.Fatalf("Export() returned %d rows; expected %d", len(records), len(testEvents)+1)
+	}
+	if records[3][4] != "Hello, world" {
+		t.Errorf("Export() freeText = %q; expected %q", records[3][4], "Hello, world")
+	}
+}
+
+func TestPolylineExport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (&Polyline{}).Export(&buf, testEvents[:2]); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	expected := "oj_jHnue}Tn}@o}@\n"
+	if buf.String() != expected {
+		t.Errorf("Export() = %q; expected %q", buf.String(), expected)
+	}
+}
@@ -0,0 +1,93 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/internal/utils"
+)
+
+// GeoJSON exports a LineString per day and Points for messages and stops
+type GeoJSON struct {
+	Location *time.Location
+}
+
+type featureCollection struct {
+	Type     string    `json:"type"`
+	Features []feature `json:"features"`
+}
+
+type feature struct {
+	Type       string                 `json:"type"`
+	Geometry   geometry               `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+func (g *GeoJSON) ContentType() string {
+	return "application/geo+json"
+}
+
+func (g *GeoJSON) Extension() string {
+	return "geojson"
+}
+
+func (g *GeoJSON) Export(w io.Writer, events []repository.Event) error {
+	collection := featureCollection{
+		Type:     "FeatureCollection",
+		Features: []feature{},
+	}
+
+	for _, d := range groupByDay(trackEvents(events), g.Location) {
+		coordinates := make([][]float64, len(d.Events))
+		for i, e := range d.Events {
+			coordinates[i] = []float64{e.Longitude, e.Latitude, e.Altitude}
+		}
+		collection.Features = append(collection.Features, feature{
+			Type:     "Feature",
+			Geometry: geometry{Type: "LineString", Coordinates: coordinates},
+			Properties: map[string]interface{}{
+				"kind":             "day",
+				"date":             d.Date,
+				"start":            d.Events[0].TimeStamp,
+				"end":              d.Events[len(d.Events)-1].TimeStamp,
+				"distanceInMeters": utils.DistanceInMeters(d.Events),
+			},
+		})
+
+		for _, stop := range utils.CalculateStops(d.Events, stopRadiusInMeters, stopMinimumDuration) {
+			collection.Features = append(collection.Features, feature{
+				Type:     "Feature",
+				Geometry: geometry{Type: "Point", Coordinates: []float64{stop.Longitude, stop.Latitude}},
+				Properties: map[string]interface{}{
+					"kind":              "stop",
+					"start":             stop.Start,
+					"end":               stop.End,
+					"durationInSeconds": stop.End - stop.Start,
+				},
+			})
+		}
+	}
+
+	for _, e := range messageEvents(events) {
+		collection.Features = append(collection.Features, feature{
+			Type:     "Feature",
+			Geometry: geometry{Type: "Point", Coordinates: []float64{e.Longitude, e.Latitude, e.Altitude}},
+			Properties: map[string]interface{}{
+				"kind":        "message",
+				"messageCode": e.MessageCode,
+				"message":     e.FreeText,
+				"timeStamp":   e.TimeStamp,
+			},
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	return encoder.Encode(collection)
+}
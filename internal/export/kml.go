@@ -0,0 +1,110 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/internal/utils"
+)
+
+// KML exports a Placemark with a TimeSpan per day and one for every message
+type KML struct {
+	Location *time.Location
+}
+
+type kmlRoot struct {
+	XMLName  xml.Name    `xml:"kml"`
+	Xmlns    string      `xml:"xmlns,attr"`
+	Document kmlDocument `xml:"Document"`
+}
+
+type kmlDocument struct {
+	Name       string         `xml:"name"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name        string         `xml:"name"`
+	Description string         `xml:"description,omitempty"`
+	TimeSpan    *kmlTimeSpan   `xml:"TimeSpan,omitempty"`
+	TimeStamp   *kmlTimeStamp  `xml:"TimeStamp,omitempty"`
+	LineString  *kmlLineString `xml:"LineString,omitempty"`
+	Point       *kmlPoint      `xml:"Point,omitempty"`
+}
+
+type kmlTimeSpan struct {
+	Begin string `xml:"begin"`
+	End   string `xml:"end"`
+}
+
+type kmlTimeStamp struct {
+	When string `xml:"when"`
+}
+
+type kmlLineString struct {
+	Tessellate  int    `xml:"tessellate"`
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+func (k *KML) ContentType() string {
+	return "application/vnd.google-earth.kml+xml"
+}
+
+func (k *KML) Extension() string {
+	return "kml"
+}
+
+func kmlTime(ts int64) string {
+	return time.Unix(ts, 0).UTC().Format(time.RFC3339)
+}
+
+func kmlCoordinate(e repository.Event) string {
+	return fmt.Sprintf("%f,%f,%f", e.Longitude, e.Latitude, e.Altitude)
+}
+
+func (k *KML) Export(w io.Writer, events []repository.Event) error {
+	root := kmlRoot{
+		Xmlns:    "http://www.opengis.net/kml/2.2",
+		Document: kmlDocument{Name: "Track Me"},
+	}
+
+	for _, d := range groupByDay(trackEvents(events), k.Location) {
+		coordinates := make([]string, len(d.Events))
+		for i, e := range d.Events {
+			coordinates[i] = kmlCoordinate(e)
+		}
+		root.Document.Placemarks = append(root.Document.Placemarks, kmlPlacemark{
+			Name:        d.Date,
+			Description: fmt.Sprintf("%.1f km", utils.InKm(utils.DistanceInMeters(d.Events))),
+			TimeSpan: &kmlTimeSpan{
+				Begin: kmlTime(d.Events[0].TimeStamp),
+				End:   kmlTime(d.Events[len(d.Events)-1].TimeStamp),
+			},
+			LineString: &kmlLineString{Tessellate: 1, Coordinates: strings.Join(coordinates, " ")},
+		})
+	}
+
+	for _, e := range messageEvents(events) {
+		root.Document.Placemarks = append(root.Document.Placemarks, kmlPlacemark{
+			Name:        "Message",
+			Description: e.FreeText,
+			TimeStamp:   &kmlTimeStamp{When: kmlTime(e.TimeStamp)},
+			Point:       &kmlPoint{Coordinates: kmlCoordinate(e)},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(root)
+}
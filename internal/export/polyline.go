@@ -0,0 +1,33 @@
+package export
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/pkg/polyline"
+)
+
+// Polyline exports the whole track as a single Google encoded polyline
+type Polyline struct{}
+
+func (p *Polyline) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+func (p *Polyline) Extension() string {
+	return "polyline"
+}
+
+func EncodePolyline(events []repository.Event) string {
+	points := make([][2]float64, len(events))
+	for i, e := range events {
+		points[i] = [2]float64{e.Latitude, e.Longitude}
+	}
+	return polyline.Encode(points)
+}
+
+func (p *Polyline) Export(w io.Writer, events []repository.Event) error {
+	_, err := fmt.Fprintln(w, EncodePolyline(trackEvents(events)))
+	return err
+}
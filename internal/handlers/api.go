@@ -0,0 +1,422 @@
+package handlers
+
+import (
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/internal/service"
+	"github.com/janschill/track-me/internal/utils"
+)
+
+//go:embed openapi.json
+var openAPI []byte
+
+const (
+	defaultEventLimit = 500
+	maxEventLimit     = 5000
+)
+
+// APIHandler serves the read-only JSON API under /api/v1. Distances are in
+// meters, speeds in km/h, durations in seconds and times in unix seconds.
+type APIHandler struct {
+	repo        *repository.Repository
+	dayService  *service.DayService
+	tripService *service.TripService
+}
+
+type apiError struct {
+	Error apiErrorBody `json:"error"`
+}
+
+type apiErrorBody struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+type apiTrip struct {
+	ID          int64  `json:"id"`
+	Description string `json:"description"`
+	Timezone    string `json:"timezone"`
+	Units       string `json:"units"`
+}
+
+type apiRide struct {
+	Distance      float64 `json:"distance"`
+	Progress      float64 `json:"progress"`
+	ElevationGain int64   `json:"elevationGain"`
+	ElevationLoss int64   `json:"elevationLoss"`
+	MovingTime    int64   `json:"movingTime"`
+	RestingTime   int64   `json:"restingTime"`
+	ElapsedDays   int     `json:"elapsedDays"`
+	RemainingDays int     `json:"remainingDays"`
+}
+
+type apiDay struct {
+	Date            string  `json:"date"`
+	StartTime       int64   `json:"startTime"`
+	EndTime         int64   `json:"endTime"`
+	Distance        float64 `json:"distance"`
+	AverageSpeed    float64 `json:"averageSpeed"`
+	MaxSpeed        float64 `json:"maxSpeed"`
+	ElevationGain   int64   `json:"elevationGain"`
+	ElevationLoss   int64   `json:"elevationLoss"`
+	AverageAltitude float64 `json:"averageAltitude"`
+	MaxAltitude     float64 `json:"maxAltitude"`
+	MinAltitude     float64 `json:"minAltitude"`
+	MovingTime      int64   `json:"movingTime"`
+	Kudos           int     `json:"kudos"`
+}
+
+type apiEvent struct {
+	ID                int64    `json:"id"`
+	TimeStamp         int64    `json:"timeStamp"`
+	Latitude          float64  `json:"latitude"`
+	Longitude         float64  `json:"longitude"`
+	Altitude          float64  `json:"altitude"`
+	CorrectedAltitude *float64 `json:"correctedAltitude"`
+	Speed             float64  `json:"speed"`
+	Course            float64  `json:"course"`
+	GpsFix            int      `json:"gpsFix"`
+	MessageCode       int      `json:"messageCode"`
+	FreeText          string   `json:"freeText"`
+	Source            string   `json:"source"`
+}
+
+type apiEventPage struct {
+	Events []apiEvent `json:"events"`
+	// Passed as ?cursor= to get the next page, empty on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+type apiMessage struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	Message      string `json:"message"`
+	TimeStamp    int64  `json:"timeStamp"`
+	SentToGarmin bool   `json:"sentToGarmin"`
+	FromGarmin   bool   `json:"fromGarmin"`
+}
+
+type apiKudos struct {
+	Day   string `json:"day"`
+	Count int    `json:"count"`
+}
+
+func NewAPIHandler(repo *repository.Repository, dayService *service.DayService, tripService *service.TripService) *APIHandler {
+	return &APIHandler{
+		repo:        repo,
+		dayService:  dayService,
+		tripService: tripService,
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(apiError{Error: apiErrorBody{Status: status, Message: message}}); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
+
+func (h *APIHandler) allowGet(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAPIError(w, http.StatusMethodNotAllowed, "Method is not supported.")
+		return false
+	}
+	return true
+}
+
+// trip reads the trip of the {id} path segment
+func (h *APIHandler) trip(w http.ResponseWriter, r *http.Request) (repository.Trip, bool) {
+	if !h.allowGet(w, r) {
+		return repository.Trip{}, false
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "Invalid trip id.")
+		return repository.Trip{}, false
+	}
+	trip, err := h.repo.Trips.Get(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeAPIError(w, http.StatusNotFound, fmt.Sprintf("Trip %d does not exist.", id))
+		return repository.Trip{}, false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving trip", "trip", id, "error", err)
+		writeAPIError(w, http.StatusInternalServerError, "An unexpected error happened.")
+		return repository.Trip{}, false
+	}
+	return trip, true
+}
+
+// days splits the events of a trip into days in the trip's timezone
+func (h *APIHandler) days(w http.ResponseWriter, trip repository.Trip) ([]service.Day, service.Ride, bool) {
+	events, err := h.repo.Events.Track(trip.ID)
+	if err != nil {
+		slog.Error("Error retrieving events", "error", err)
+		writeAPIError(w, http.StatusInternalServerError, "An unexpected error happened.")
+		return nil, service.Ride{}, false
+	}
+	days, ride := h.dayService.GetDays(trip.ID, events, h.tripService.Location(trip.ID))
+	return days, ride, true
+}
+
+func newAPITrip(t repository.Trip) apiTrip {
+	return apiTrip{ID: t.ID, Description: t.Description, Timezone: t.Timezone, Units: t.Units}
+}
+
+func newAPIEvent(e repository.Event) apiEvent {
+	return apiEvent{
+		ID:                e.ID,
+		TimeStamp:         e.TimeStamp,
+		Latitude:          e.Latitude,
+		Longitude:         e.Longitude,
+		Altitude:          e.Altitude,
+		CorrectedAltitude: e.CorrectedAltitude,
+		Speed:             e.Speed,
+		Course:            e.Course,
+		GpsFix:            e.GpsFix,
+		MessageCode:       e.MessageCode,
+		FreeText:          e.FreeText,
+		Source:            e.Source,
+	}
+}
+
+// GetOpenAPI serves the OpenAPI document of the API
+func (h *APIHandler) GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if !h.allowGet(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPI)
+}
+
+// NotFound answers every unknown path under /api/v1
+func (h *APIHandler) NotFound(w http.ResponseWriter, r *http.Request) {
+	writeAPIError(w, http.StatusNotFound, "Not found.")
+}
+
+func (h *APIHandler) GetTrips(w http.ResponseWriter, r *http.Request) {
+	if !h.allowGet(w, r) {
+		return
+	}
+	trips, err := h.repo.Trips.All()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "An unexpected error happened.")
+		return
+	}
+	response := make([]apiTrip, len(trips))
+	for i, t := range trips {
+		response[i] = newAPITrip(t)
+	}
+	writeJSON(w, response)
+}
+
+func (h *APIHandler) GetTrip(w http.ResponseWriter, r *http.Request) {
+	trip, ok := h.trip(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, newAPITrip(trip))
+}
+
+func (h *APIHandler) GetRide(w http.ResponseWriter, r *http.Request) {
+	trip, ok := h.trip(w, r)
+	if !ok {
+		return
+	}
+	_, ride, ok := h.days(w, trip)
+	if !ok {
+		return
+	}
+	writeJSON(w, apiRide{
+		Distance:      ride.Distance,
+		Progress:      ride.Progress,
+		ElevationGain: ride.ElevationGain,
+		ElevationLoss: ride.ElevationLoss,
+		MovingTime:    ride.MovingTime,
+		RestingTime:   ride.RestingTime,
+		ElapsedDays:   ride.ElapsedDays,
+		RemainingDays: ride.RemainingDays,
+	})
+}
+
+func (h *APIHandler) GetDays(w http.ResponseWriter, r *http.Request) {
+	trip, ok := h.trip(w, r)
+	if !ok {
+		return
+	}
+	days, _, ok := h.days(w, trip)
+	if !ok {
+		return
+	}
+	kudos, err := h.repo.Kudos.All()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving kudos", "error", err)
+	}
+
+	response := make([]apiDay, len(days))
+	for i, d := range days {
+		response[i] = apiDay{
+			Date:            d.Date,
+			StartTime:       d.StartTime,
+			EndTime:         d.EndTime,
+			Distance:        d.DistanceInMeters,
+			AverageSpeed:    d.AverageSpeed,
+			MaxSpeed:        d.MaxSpeed,
+			ElevationGain:   d.ElevationGain,
+			ElevationLoss:   d.ElevationLoss,
+			AverageAltitude: d.AverageAltitude,
+			MaxAltitude:     d.MaxAltitude,
+			MinAltitude:     d.MinAltitude,
+			MovingTime:      d.MovingTimeInSeconds,
+			Kudos:           utils.FindKudos(kudos, d.Date),
+		}
+	}
+	writeJSON(w, response)
+}
+
+// parseCursor reads a cursor of the form <timeStamp>.<id>
+func parseCursor(cursor string) (afterTime, afterID int64, err error) {
+	if cursor == "" {
+		return 0, 0, nil
+	}
+	if _, err := fmt.Sscanf(cursor, "%d.%d", &afterTime, &afterID); err != nil || afterID <= 0 {
+		return 0, 0, errors.New("invalid cursor")
+	}
+	return afterTime, afterID, nil
+}
+
+// GetEvents serves the events of a trip in pages ordered by time. ?from= and ?to=
+// limit them to a range of unix seconds, ?limit= sets the page size.
+func (h *APIHandler) GetEvents(w http.ResponseWriter, r *http.Request) {
+	trip, ok := h.trip(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	var from, to int64
+	bounds := []struct {
+		name  string
+		value *int64
+	}{{"from", &from}, {"to", &to}}
+	for _, bound := range bounds {
+		if query.Get(bound.name) == "" {
+			continue
+		}
+		parsed, err := strconv.ParseInt(query.Get(bound.name), 10, 64)
+		if err != nil || parsed < 0 {
+			writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s, expected unix seconds.", bound.name))
+			return
+		}
+		*bound.value = parsed
+	}
+	limit := defaultEventLimit
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxEventLimit {
+			writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("Invalid limit, expected 1 to %d.", maxEventLimit))
+			return
+		}
+		limit = parsed
+	}
+	afterTime, afterID, err := parseCursor(query.Get("cursor"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "Invalid cursor.")
+		return
+	}
+
+	// One more than requested tells whether there is a next page
+	events, err := h.repo.Events.Page(trip.ID, from, to, afterTime, afterID, limit+1)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "An unexpected error happened.")
+		return
+	}
+
+	page := apiEventPage{Events: make([]apiEvent, 0, limit)}
+	if len(events) > limit {
+		events = events[:limit]
+		last := events[len(events)-1]
+		page.NextCursor = fmt.Sprintf("%d.%d", last.TimeStamp, last.ID)
+	}
+	for _, e := range events {
+		page.Events = append(page.Events, newAPIEvent(e))
+	}
+	writeJSON(w, page)
+}
+
+func (h *APIHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
+	trip, ok := h.trip(w, r)
+	if !ok {
+		return
+	}
+	messages, err := h.repo.Messages.AllByTrip(trip.ID)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "An unexpected error happened.")
+		return
+	}
+	response := make([]apiMessage, len(messages))
+	for i, m := range messages {
+		response[i] = apiMessage{
+			ID:           m.ID,
+			Name:         m.Name,
+			Message:      m.Message,
+			TimeStamp:    m.TimeStamp,
+			SentToGarmin: m.SentToGarmin,
+			FromGarmin:   m.FromGarmin,
+		}
+	}
+	writeJSON(w, response)
+}
+
+// GetKudos serves the kudos of the days of a trip. Kudos are stored per day, not per trip.
+func (h *APIHandler) GetKudos(w http.ResponseWriter, r *http.Request) {
+	trip, ok := h.trip(w, r)
+	if !ok {
+		return
+	}
+	days, _, ok := h.days(w, trip)
+	if !ok {
+		return
+	}
+	kudos, err := h.repo.Kudos.All()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "An unexpected error happened.")
+		return
+	}
+
+	response := []apiKudos{}
+	for _, d := range days {
+		if count := utils.FindKudos(kudos, d.Date); count > 0 {
+			response = append(response, apiKudos{Day: d.Date, Count: count})
+		}
+	}
+	writeJSON(w, response)
+}
+
+// GetPosition serves the latest event of a trip with a position
+func (h *APIHandler) GetPosition(w http.ResponseWriter, r *http.Request) {
+	trip, ok := h.trip(w, r)
+	if !ok {
+		return
+	}
+	event, err := h.repo.Events.Last(trip.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeAPIError(w, http.StatusNotFound, "The trip has no position yet.")
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "An unexpected error happened.")
+		return
+	}
+	writeJSON(w, newAPIEvent(event))
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/janschill/track-me/internal/export"
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/internal/service"
+)
+
+type ExportHandler struct {
+	repo        *repository.Repository
+	tripService *service.TripService
+}
+
+func NewExportHandler(repo *repository.Repository, tripService *service.TripService) *ExportHandler {
+	return &ExportHandler{
+		repo:        repo,
+		tripService: tripService,
+	}
+}
+
+// GetExport serves the events of a trip as a file download.
+// Query parameters: format (geojson, kml, csv), trip, from and to (yyyy-mm-dd).
+func (h *ExportHandler) GetExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method is not supported.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "geojson"
+	}
+
+	tripID := int64(1)
+	if trip := query.Get("trip"); trip != "" {
+		var err error
+		tripID, err = strconv.ParseInt(trip, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid trip", http.StatusBadRequest)
+			return
+		}
+	}
+	// Days in exports and the date range follow the trip's timezone
+	loc := h.tripService.Location(tripID)
+
+	exporter, err := export.New(format, loc)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	from, to, err := export.ParseDateRange(query.Get("from"), query.Get("to"), loc)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, err := h.repo.Events.AllInRange(tripID, from, to)
+	if err != nil {
+		http.Error(w, "An unexpected error happened.", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error retrieving events for export", "error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", exporter.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"trip-%d.%s\"", tripID, exporter.Extension()))
+	if err := exporter.Export(w, events); err != nil {
+		slog.ErrorContext(r.Context(), "Error writing export", "format", format, "error", err)
+	}
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/janschill/track-me/internal/db"
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/internal/service"
+)
+
+// Readiness checks give up on a database that is locked for longer
+const readyTimeout = 2 * time.Second
+
+// HealthHandler answers the deploy script, uptime checks and load balancers
+type HealthHandler struct {
+	database      *sql.DB
+	repo          *repository.Repository
+	templates     *Templates
+	garminService *service.GarminService
+}
+
+func NewHealthHandler(database *sql.DB, repo *repository.Repository, templates *Templates, garminService *service.GarminService) *HealthHandler {
+	return &HealthHandler{
+		database:      database,
+		repo:          repo,
+		templates:     templates,
+		garminService: garminService,
+	}
+}
+
+type ReadyResponse struct {
+	Status string `json:"status"`
+	// Result of each check, "ok" or what failed
+	Checks map[string]string `json:"checks"`
+}
+
+type StatusResponse struct {
+	TripID int64 `json:"tripId"`
+	// Unix seconds, omitted until a payload arrives after a restart
+	LastPayloadAt int64 `json:"lastPayloadAt,omitempty"`
+	// Unix seconds of the last position and how many seconds ago it was recorded
+	LastPositionAt  int64 `json:"lastPositionAt,omitempty"`
+	LastPositionAge int64 `json:"lastPositionAge,omitempty"`
+	// "ok" or "low" as reported by the inReach's last event, "unknown" without one
+	Battery string `json:"battery"`
+}
+
+func getOnly(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method is not supported.", http.StatusMethodNotAllowed)
+		return false
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	return true
+}
+
+// GetHealth answers as long as the process serves requests
+func (h *HealthHandler) GetHealth(w http.ResponseWriter, r *http.Request) {
+	if !getOnly(w, r) {
+		return
+	}
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+// Check runs the readiness checks: the database answers, all migrations are
+// applied and the templates are loaded
+func (h *HealthHandler) Check(ctx context.Context) map[string]string {
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+
+	checks := map[string]string{"database": "ok", "migrations": "ok", "templates": "ok"}
+	if err := h.database.PingContext(ctx); err != nil {
+		checks["database"] = err.Error()
+	}
+	if pending, err := db.Pending(h.database); err != nil {
+		checks["migrations"] = err.Error()
+	} else if len(pending) > 0 {
+		checks["migrations"] = fmt.Sprintf("pending migrations %v", pending)
+	}
+	if err := h.templates.Check(); err != nil {
+		checks["templates"] = err.Error()
+	}
+	return checks
+}
+
+// GetReady answers 503 Service Unavailable while a check fails
+func (h *HealthHandler) GetReady(w http.ResponseWriter, r *http.Request) {
+	if !getOnly(w, r) {
+		return
+	}
+
+	response := ReadyResponse{Status: "ok", Checks: h.Check(r.Context())}
+	status := http.StatusOK
+	for check, result := range response.Checks {
+		if result != "ok" {
+			slog.WarnContext(r.Context(), "Readiness check failed", "check", check, "error", result)
+			response.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+	}
+}
+
+// GetStatus reports how fresh the tracking data is
+func (h *HealthHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	if !getOnly(w, r) {
+		return
+	}
+
+	response := StatusResponse{TripID: 1, Battery: "unknown"}
+	if last := h.garminService.LastPayload(); !last.IsZero() {
+		response.LastPayloadAt = last.Unix()
+	}
+
+	position, err := h.repo.Events.Last(1)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "An unexpected error happened.", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error retrieving last position", "error", err)
+		return
+	}
+	if err == nil {
+		response.LastPositionAt = position.TimeStamp
+		response.LastPositionAge = int64(time.Since(time.Unix(position.TimeStamp, 0)).Seconds())
+	}
+
+	event, err := h.repo.Events.LastFromSource(1, repository.SourceInReach)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "An unexpected error happened.", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error retrieving last inReach event", "error", err)
+		return
+	}
+	if err == nil {
+		response.Battery = "ok"
+		if event.Status.LowBattery != 0 {
+			response.Battery = "low"
+		}
+	}
+
+	writeJSON(w, response)
+}
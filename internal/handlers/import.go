@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/janschill/track-me/internal/importer"
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/internal/service"
+)
+
+const maxImportSize = 32 << 20
+
+type ImportHandler struct {
+	repo       *repository.Repository
+	dayService *service.DayService
+	importer   *importer.Importer
+}
+
+func NewImportHandler(repo *repository.Repository, dayService *service.DayService) *ImportHandler {
+	return &ImportHandler{
+		repo:       repo,
+		dayService: dayService,
+		importer:   importer.New(repo.Events),
+	}
+}
+
+// CreateImport accepts a GPX or FIT file as multipart form field "file"
+// and merges its points into the events of "trip"
+func (h *ImportHandler) CreateImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method is not supported.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	if err := r.ParseMultipartForm(maxImportSize); err != nil {
+		http.Error(w, "Error parsing form", http.StatusBadRequest)
+		return
+	}
+
+	tripID := int64(1)
+	if trip := r.FormValue("trip"); trip != "" {
+		var err error
+		tripID, err = strconv.ParseInt(trip, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid trip", http.StatusBadRequest)
+			return
+		}
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "File cannot be blank", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	points, source, err := importer.Parse(header.Filename, file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.importer.Import(tripID, source, points)
+	if err != nil {
+		http.Error(w, "Failed to import file", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error importing", "file", header.Filename, "error", err)
+		return
+	}
+	if result.Imported > 0 {
+		h.dayService.InvalidateRange(result.From, result.To)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(result)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -1,19 +1,35 @@
 package handlers
 
 import (
-	"encoding/json"
 	"html/template"
-	"log"
+	"log/slog"
 	"net/http"
+	"time"
 
+	"github.com/janschill/track-me/internal/i18n"
 	"github.com/janschill/track-me/internal/repository"
 	"github.com/janschill/track-me/internal/service"
 	"github.com/janschill/track-me/internal/utils"
+	"github.com/janschill/track-me/pkg/httpcache"
 )
 
 type IndexHandler struct {
-	repo       *repository.Repository
-	dayService *service.DayService
+	repo          *repository.Repository
+	dayService    *service.DayService
+	recordService *service.RecordService
+	tripService   *service.TripService
+	placeService  *service.PlaceService
+	routeService  *service.RouteService
+	templates     *Templates
+	static        *StaticHandler
+}
+
+// Overnight is where a day ended and the next one started
+type Overnight struct {
+	Date      string
+	Latitude  float64
+	Longitude float64
+	Place     string
 }
 
 type IndexPageData struct {
@@ -22,61 +38,132 @@ type IndexPageData struct {
 	LastEvent  repository.Event
 	Ride       service.Ride
 	Days       []service.Day
-	EventsJSON template.JS
+	Climbs     []utils.Climb
+	Records    []service.TripRecord
+	Place      string
+	Upcoming   []service.UpcomingPOI
+	Overnights []Overnight
+	Timezone   string
+	Units      utils.Units
+	Locale     string
+	Strings    map[string]string
+	ImportMap  template.HTML
 }
 
-func NewIndexHandler(repo *repository.Repository, service *service.DayService) *IndexHandler {
+func NewIndexHandler(repo *repository.Repository, dayService *service.DayService, recordService *service.RecordService, tripService *service.TripService, placeService *service.PlaceService, routeService *service.RouteService, templates *Templates, static *StaticHandler) *IndexHandler {
 	return &IndexHandler{
-		repo:       repo,
-		dayService: service,
+		repo:          repo,
+		dayService:    dayService,
+		recordService: recordService,
+		tripService:   tripService,
+		placeService:  placeService,
+		routeService:  routeService,
+		templates:     templates,
+		static:        static,
 	}
 }
 
-func (h *IndexHandler) GetIndex(w http.ResponseWriter, r *http.Request) {
-	funcMap := template.FuncMap{
-		"wroteOnTime":     utils.WroteOnTime,
-		"onDay":           utils.OnDay,
-		"onDayFromString": utils.OnDayFromString,
+// pageFuncs are the template functions for a request's timezone, units and language
+func pageFuncs(loc *time.Location, units utils.UnitSystem, l *i18n.Locale, placeService *service.PlaceService, static *StaticHandler) template.FuncMap {
+	return template.FuncMap{
+		"wroteOnTime":     func(ts int64) string { return utils.WroteOnTime(ts, loc, l) },
+		"onDay":           func(ts int64) string { return utils.OnDay(ts, loc, l) },
+		"onDayFromString": func(date string) (string, error) { return utils.OnDayFromString(date, l) },
 		"time":            utils.FormatTime,
 		"oneDecimal":      utils.OneDecimal,
 		"inKm":            utils.InKm,
 		"addOne":          func(i int) int { return i + 1 },
 		"findKudos":       utils.FindKudos,
+		"distance":        func(meters any) string { return units.FormatDistance(l, number(meters)) },
+		"speed":           func(kmh any) string { return units.FormatSpeed(l, number(kmh)) },
+		"elevation":       func(meters any) string { return units.FormatElevation(l, number(meters)) },
+		"percent":         func(v any) string { return l.Number(number(v), 1) + " %" },
+		"near":            func(lat, lon float64) string { return placeService.Label(lat, lon, units, l) },
+		"t":               l.T,
+		"asset":           static.URL,
+	}
+}
+
+func (h *IndexHandler) GetIndex(w http.ResponseWriter, r *http.Request) {
+	loc := h.tripService.Location(1)
+	units := unitSystem(w, r, h.tripService.Units(1))
+	l := locale(w, r)
+	// The page depends on the trip's data, the visitor's units and language and
+	// the day. Dev mode always renders.
+	if version := h.templates.Version(); version != "" {
+		changes, err := h.repo.Trips.Changes(1)
+		if err == nil {
+			etag := httpcache.ETag(version, changes, units, l.Tag, loc, time.Now().In(loc).Format("2006-01-02"))
+			// Imported points keep their own time, the entity tag catches them
+			modified := time.Unix(changes.Modified, 0)
+			if h.templates.ParsedAt().After(modified) {
+				modified = h.templates.ParsedAt()
+			}
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Add("Vary", "Cookie, Accept-Language")
+			if httpcache.NotModified(w, r, etag, modified) {
+				return
+			}
+		}
+	}
+
+	tmpl, err := h.templates.Page("index", pageFuncs(loc, units, l, h.placeService, h.static))
+	if err != nil {
+		http.Error(w, "An unexpected error happened.", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error loading template", "error", err)
+		return
 	}
-	tmpl := template.Must(template.New("layout.html").Funcs(funcMap).ParseFiles("web/templates/layout.html", "web/templates/index.html"))
 
 	messages, err := h.repo.Messages.All()
 	if err != nil {
 		http.Error(w, "An unexpected error happened.", http.StatusBadGateway)
-		log.Printf("Error retrieving messages: %v", err)
+		slog.ErrorContext(r.Context(), "Error retrieving messages", "error", err)
 		return
 	}
-	log.Printf("Retrieved %d messages", len(messages))
 
-	events, err := h.repo.Events.All()
+	events, err := h.repo.Events.Track(1)
 	if err != nil {
 		http.Error(w, "An unexpected error happened.", http.StatusBadGateway)
-		log.Printf("Error retrieving events: %v", err)
+		slog.ErrorContext(r.Context(), "Error retrieving events", "error", err)
 		return
 	}
-	log.Printf("Retrieved %d events", len(events))
 
-	days, ride := h.dayService.GetDays(events)
+	days, ride := h.dayService.GetDays(1, events, loc)
 
 	var lastEvent repository.Event
 	if len(events) > 0 {
 		lastEvent = events[len(events)-1]
 	}
 
-	eventsJSON, _ := json.Marshal(events)
-
 	kudos, err := h.repo.Kudos.All()
 	if err != nil {
-		log.Printf("Error retrieving kudos: %v", err)
+		slog.ErrorContext(r.Context(), "Error retrieving kudos", "error", err)
 	}
 
+	var climbs []utils.Climb
+	var overnights []Overnight
 	for i := range days {
 		days[i].KudosCount = utils.FindKudos(kudos, days[i].Date)
+		climbs = append(climbs, days[i].Climbs...)
+		// The last day has not ended in an overnight spot yet
+		if i < len(days)-1 {
+			overnights = append(overnights, Overnight{
+				Date:      days[i].Date,
+				Latitude:  days[i].EndLatitude,
+				Longitude: days[i].EndLongitude,
+				Place:     h.placeService.Label(days[i].EndLatitude, days[i].EndLongitude, units, l),
+			})
+		}
+	}
+
+	upcoming, err := h.routeService.Upcoming(1, lastEvent.Latitude, lastEvent.Longitude, service.EstimatedSpeed(ride), "", 3)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving pois", "error", err)
+	}
+
+	records, err := h.recordService.Records(1, units, l)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving records", "error", err)
 	}
 
 	data := IndexPageData{
@@ -85,11 +172,20 @@ func (h *IndexHandler) GetIndex(w http.ResponseWriter, r *http.Request) {
 		LastEvent:  lastEvent,
 		Ride:       ride,
 		Days:       days,
-		EventsJSON: template.JS(eventsJSON),
+		Climbs:     climbs,
+		Records:    records,
+		Place:      h.placeService.Label(lastEvent.Latitude, lastEvent.Longitude, units, l),
+		Overnights: overnights,
+		Upcoming:   upcoming,
+		Timezone:   loc.String(),
+		Units:      units.Units(),
+		Locale:     l.Tag,
+		Strings:    l.Messages(),
+		ImportMap:  h.static.ImportMap(),
 	}
 
 	err = tmpl.Execute(w, data)
 	if err != nil {
-		log.Printf("Error executing template: %v", err)
+		slog.ErrorContext(r.Context(), "Error executing template", "error", err)
 	}
 }
@@ -2,27 +2,30 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/internal/service"
 )
 
 type KudosHandler struct {
-	repo *repository.Repository
+	repo           *repository.Repository
+	liveService    *service.LiveService
+	webhookService *service.WebhookService
 }
 
-func NewKudosHandler(repo *repository.Repository) *KudosHandler {
+func NewKudosHandler(repo *repository.Repository, liveService *service.LiveService, webhookService *service.WebhookService) *KudosHandler {
 	return &KudosHandler{
-		repo: repo,
+		repo:           repo,
+		liveService:    liveService,
+		webhookService: webhookService,
 	}
 }
 
 func (h *KudosHandler) CreateKudos(w http.ResponseWriter, r *http.Request) {
-	log.Print("kudos")
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method is not supported.", http.StatusMethodNotAllowed)
-		log.Print("method")
 		return
 	}
 
@@ -32,14 +35,13 @@ func (h *KudosHandler) CreateKudos(w http.ResponseWriter, r *http.Request) {
 	err := json.NewDecoder(r.Body).Decode(&requestData)
 	if err != nil || requestData.Day == "" {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		log.Print("requestdata")
 		return
 	}
 
-	err = h.repo.Kudos.Increment(requestData.Day)
+	err = giveKudos(h.repo, h.liveService, h.webhookService, requestData.Day)
 	if err != nil {
 		http.Error(w, "Failed to update kudos", http.StatusInternalServerError)
-		log.Print("repo")
+		slog.ErrorContext(r.Context(), "Error giving kudos", "day", requestData.Day, "error", err)
 		return
 	}
 
@@ -50,3 +52,16 @@ func (h *KudosHandler) CreateKudos(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// giveKudos counts a kudos for a day, pushes the new count to the followers and
+// announces milestones to the webhooks
+func giveKudos(repo *repository.Repository, liveService *service.LiveService, webhookService *service.WebhookService, day string) error {
+	if err := repo.Kudos.Increment(day); err != nil {
+		return err
+	}
+	if count, err := repo.Kudos.Count(day); err == nil {
+		liveService.PublishKudos(1, day, count)
+		webhookService.PublishKudos(1, day, count)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/janschill/track-me/internal/service"
+	"github.com/janschill/track-me/pkg/pubsub"
+)
+
+const (
+	// Comment lines keep proxies from closing idle streams
+	liveHeartbeat = 25 * time.Second
+	// Milliseconds the browser waits before reconnecting
+	liveRetry = 5000
+)
+
+type LiveHandler struct {
+	liveService *service.LiveService
+}
+
+func NewLiveHandler(liveService *service.LiveService) *LiveHandler {
+	return &LiveHandler{
+		liveService: liveService,
+	}
+}
+
+func writeLiveMessage(w http.ResponseWriter, m pubsub.Message) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", m.ID, m.Type, m.Data)
+	return err
+}
+
+// GetLive streams the updates of a trip as Server-Sent Events. Browsers that
+// reconnect send the Last-Event-ID header and get the updates they missed, or a
+// reset event when those are no longer kept.
+func (h *LiveHandler) GetLive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method is not supported.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tripID := int64(1)
+	if value := r.URL.Query().Get("trip"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid trip", http.StatusBadRequest)
+			return
+		}
+		tripID = id
+	}
+	var lastID uint64
+	if value := r.Header.Get("Last-Event-ID"); value != "" {
+		// An unreadable id is treated like an id of a previous process
+		lastID, _ = strconv.ParseUint(value, 10, 64)
+		if lastID == 0 {
+			lastID = 1
+		}
+	}
+
+	rc := http.NewResponseController(w)
+	// The stream outlives the server's write timeout
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		slog.ErrorContext(r.Context(), "Live updates need a connection without write deadline", "error", err)
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	sub, missed, complete := h.liveService.Subscribe(tripID, lastID)
+	defer h.liveService.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Keeps nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", liveRetry)
+	if !complete {
+		// The page has to be reloaded, replaying part of the gap would not help
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+		missed = nil
+	}
+	for _, m := range missed {
+		if err := writeLiveMessage(w, m); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case m, ok := <-sub.C:
+			// Dropped for falling behind, the browser reconnects with its last id
+			if !ok {
+				return
+			}
+			if err := writeLiveMessage(w, m); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/janschill/track-me/internal/i18n"
+)
+
+const localeCookie = "lang"
+
+// locale picks the locale of ?lang=, then of the lang cookie, then the best
+// match for Accept-Language. A valid ?lang= is remembered in the cookie.
+func locale(w http.ResponseWriter, r *http.Request) *i18n.Locale {
+	if l, ok := i18n.Get(r.URL.Query().Get("lang")); ok {
+		http.SetCookie(w, &http.Cookie{
+			Name:     localeCookie,
+			Value:    l.Tag,
+			Path:     "/",
+			MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+			SameSite: http.SameSiteLaxMode,
+		})
+		return l
+	}
+	if cookie, err := r.Cookie(localeCookie); err == nil {
+		if l, ok := i18n.Get(cookie.Value); ok {
+			return l
+		}
+	}
+	return i18n.Match(r.Header.Get("Accept-Language"))
+}
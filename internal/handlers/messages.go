@@ -2,24 +2,29 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/internal/service"
 	garmin "github.com/janschill/track-me/pkg/garmin"
 )
 
 type MessageHandler struct {
-	repo   *repository.Repository
-	client *garmin.Client
+	repo           *repository.Repository
+	client         *garmin.Client
+	liveService    *service.LiveService
+	webhookService *service.WebhookService
 }
 
-func NewMessageHandler(repo *repository.Repository, client *garmin.Client) *MessageHandler {
+func NewMessageHandler(repo *repository.Repository, client *garmin.Client, liveService *service.LiveService, webhookService *service.WebhookService) *MessageHandler {
 	return &MessageHandler{
-		repo:   repo,
-		client: client,
+		repo:           repo,
+		client:         client,
+		liveService:    liveService,
+		webhookService: webhookService,
 	}
 }
 
@@ -39,9 +44,6 @@ func (h *MessageHandler) CreateMessage(w http.ResponseWriter, r *http.Request) {
 	name := r.FormValue("name")
 	sender := r.FormValue("email")
 
-	log.Printf("Message received: %s\n", message)
-	log.Printf("Name: %s\n", name)
-
 	if message == "" || name == "" {
 		http.Error(w, "Name or message cannot be blank", http.StatusBadRequest)
 		return
@@ -51,7 +53,6 @@ func (h *MessageHandler) CreateMessage(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		sentToGarmin = false
 	}
-	log.Printf("Sent to Garmin: %v\n", sentToGarmin)
 
 	if sentToGarmin && r.FormValue("email") == "" {
 		http.Error(w, "Email cannot be blank when sending to Garmin", http.StatusBadRequest)
@@ -72,7 +73,7 @@ func (h *MessageHandler) CreateMessage(w http.ResponseWriter, r *http.Request) {
 	if m.SentToGarmin {
 		err = h.client.SendMessage(sender, message)
 		if err != nil {
-			log.Printf("Sent to Garmin failed %v", err)
+			slog.ErrorContext(r.Context(), "Sending message to Garmin failed", "error", err)
 		}
 	}
 
@@ -81,6 +82,8 @@ func (h *MessageHandler) CreateMessage(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Something went wrong", http.StatusInternalServerError)
 		return
 	}
+	h.liveService.PublishMessage(m)
+	h.webhookService.PublishMessage(m)
 
 	w.WriteHeader(http.StatusOK)
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/internal/service"
+	"github.com/janschill/track-me/internal/utils"
+)
+
+const (
+	defaultPOILimit = 5
+	maxPOILimit     = 50
+)
+
+type POIHandler struct {
+	repo         *repository.Repository
+	dayService   *service.DayService
+	tripService  *service.TripService
+	routeService *service.RouteService
+}
+
+type upcomingPOI struct {
+	Name        string  `json:"name"`
+	Kind        string  `json:"kind"`
+	Description string  `json:"description"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	// Along the route from the latest position
+	Distance float64 `json:"distance"`
+	Seconds  int64   `json:"seconds"`
+}
+
+type upcomingPOIs struct {
+	Units utils.Units `json:"units"`
+	// Average moving speed the times are estimated with
+	Speed float64       `json:"speed"`
+	POIs  []upcomingPOI `json:"pois"`
+}
+
+func NewPOIHandler(repo *repository.Repository, dayService *service.DayService, tripService *service.TripService, routeService *service.RouteService) *POIHandler {
+	return &POIHandler{
+		repo:         repo,
+		dayService:   dayService,
+		tripService:  tripService,
+		routeService: routeService,
+	}
+}
+
+// GetPOIs serves the next points of interest along the planned route with their
+// distance and estimated moving time from the latest position. ?kind= limits them
+// to resupply, water, lodging or other and ?limit= sets how many are returned.
+func (h *POIHandler) GetPOIs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method is not supported.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := defaultPOILimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxPOILimit {
+			http.Error(w, "Invalid limit, expected 1 to 50", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	events, err := h.repo.Events.Track(1)
+	if err != nil {
+		http.Error(w, "An unexpected error happened.", http.StatusBadGateway)
+		slog.ErrorContext(r.Context(), "Error retrieving events", "error", err)
+		return
+	}
+	_, ride := h.dayService.GetDays(1, events, h.tripService.Location(1))
+	var lastEvent repository.Event
+	if len(events) > 0 {
+		lastEvent = events[len(events)-1]
+	}
+
+	speed := service.EstimatedSpeed(ride)
+	pois, err := h.routeService.Upcoming(1, lastEvent.Latitude, lastEvent.Longitude, speed, r.URL.Query().Get("kind"), limit)
+	if err != nil {
+		http.Error(w, "An unexpected error happened.", http.StatusBadGateway)
+		slog.ErrorContext(r.Context(), "Error retrieving pois", "error", err)
+		return
+	}
+
+	units := unitSystem(w, r, h.tripService.Units(1))
+	response := upcomingPOIs{
+		Units: units.Units(),
+		Speed: units.Speed(speed),
+		POIs:  make([]upcomingPOI, len(pois)),
+	}
+	for i, poi := range pois {
+		response.POIs[i] = upcomingPOI{
+			Name:        poi.Name,
+			Kind:        poi.Kind,
+			Description: poi.Description,
+			Latitude:    poi.Latitude,
+			Longitude:   poi.Longitude,
+			Distance:    units.Distance(poi.Distance),
+			Seconds:     poi.Seconds,
+		}
+	}
+	writeJSON(w, response)
+}
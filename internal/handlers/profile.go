@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/internal/service"
+)
+
+type ProfileHandler struct {
+	repo        *repository.Repository
+	dayService  *service.DayService
+	tripService *service.TripService
+}
+
+func NewProfileHandler(repo *repository.Repository, dayService *service.DayService, tripService *service.TripService) *ProfileHandler {
+	return &ProfileHandler{
+		repo:        repo,
+		dayService:  dayService,
+		tripService: tripService,
+	}
+}
+
+func (h *ProfileHandler) profile(w http.ResponseWriter, r *http.Request) (service.DayProfile, bool) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method is not supported.", http.StatusMethodNotAllowed)
+		return service.DayProfile{}, false
+	}
+
+	date := r.URL.Query().Get("date")
+	loc := h.tripService.Location(1)
+	if _, err := time.ParseInLocation("2006-01-02", date, loc); err != nil {
+		http.Error(w, "Invalid date, expected yyyy-mm-dd", http.StatusBadRequest)
+		return service.DayProfile{}, false
+	}
+	events, err := h.repo.Events.AllByDay(1, date, loc)
+	if err != nil {
+		http.Error(w, "An unexpected error happened.", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error retrieving events of day", "day", date, "error", err)
+		return service.DayProfile{}, false
+	}
+	if len(events) == 0 {
+		http.Error(w, "No events on this day", http.StatusNotFound)
+		return service.DayProfile{}, false
+	}
+
+	units := unitSystem(w, r, h.tripService.Units(1))
+	return h.dayService.GetProfile(date, events, units), true
+}
+
+// GetProfile serves the distance indexed altitude, speed and grade of the day in ?date=.
+// Values are in the units of ?units= (metric or imperial), the units cookie or the trip.
+func (h *ProfileHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	profile, ok := h.profile(w, r)
+	if !ok {
+		return
+	}
+	profile.SpeedBands = nil
+	profile.GradeBands = nil
+	writeJSON(w, profile)
+}
+
+// GetProfileSummary serves the moving time per speed and grade band of the day in ?date=
+func (h *ProfileHandler) GetProfileSummary(w http.ResponseWriter, r *http.Request) {
+	profile, ok := h.profile(w, r)
+	if !ok {
+		return
+	}
+	profile.Points = nil
+	writeJSON(w, profile)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
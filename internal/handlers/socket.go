@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/internal/service"
+	garmin "github.com/janschill/track-me/pkg/garmin"
+	"github.com/janschill/track-me/pkg/pubsub"
+	"github.com/janschill/track-me/pkg/websocket"
+)
+
+const (
+	socketPingInterval = 30 * time.Second
+	// Connections without any frame, pongs included, for this long are closed
+	socketIdleTimeout  = 2 * socketPingInterval
+	socketWriteTimeout = 10 * time.Second
+	// Messages a visitor may send per interval, more are answered with an error
+	socketMessageLimit    = 10
+	socketMessageInterval = 10 * time.Second
+	// Rejected messages after which the connection is closed
+	socketMaxRejected    = 20
+	socketMaxMessageSize = 4096
+)
+
+// SocketHandler lets the map page follow a trip and give kudos over a
+// WebSocket. Visitors send
+//
+//	{"type": "subscribe", "trip": 1, "lastId": "..."}
+//	{"type": "kudos", "day": "2024-06-14"}
+//
+// and receive the live updates of the trip as {"type", "id", "data"} with the
+// types of LiveService, plus "subscribed", "reset" and "error".
+type SocketHandler struct {
+	repo           *repository.Repository
+	liveService    *service.LiveService
+	webhookService *service.WebhookService
+
+	connections sync.WaitGroup
+	// Closed on shutdown to close all connections
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type socketRequest struct {
+	Type   string `json:"type"`
+	Trip   int64  `json:"trip"`
+	LastID string `json:"lastId"`
+	Day    string `json:"day"`
+}
+
+type socketMessage struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Trip    int64           `json:"trip,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Message string          `json:"message,omitempty"`
+}
+
+func NewSocketHandler(repo *repository.Repository, liveService *service.LiveService, webhookService *service.WebhookService) *SocketHandler {
+	return &SocketHandler{
+		repo:           repo,
+		liveService:    liveService,
+		webhookService: webhookService,
+		done:           make(chan struct{}),
+	}
+}
+
+// Shutdown closes all connections with "going away" and waits for them to end
+func (h *SocketHandler) Shutdown(ctx context.Context) error {
+	h.closeOnce.Do(func() { close(h.done) })
+	ended := make(chan struct{})
+	go func() {
+		h.connections.Wait()
+		close(ended)
+	}()
+	select {
+	case <-ended:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (h *SocketHandler) GetSocket(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-h.done:
+		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	default:
+	}
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "WebSocket upgrade failed", "error", err)
+		return
+	}
+	h.connections.Add(1)
+	defer h.connections.Done()
+	conn.MaxMessageSize = socketMaxMessageSize
+	conn.IdleTimeout = socketIdleTimeout
+
+	c := &socketConnection{
+		handler: h,
+		conn:    conn,
+		limiter: garmin.NewRateLimiter(socketMessageLimit, socketMessageInterval),
+		kudos:   make(map[string]bool),
+	}
+	c.serve(r.Context())
+}
+
+type socketConnection struct {
+	handler  *SocketHandler
+	conn     *websocket.Conn
+	sub      *pubsub.Subscription
+	trip     int64
+	lastID   uint64
+	limiter  *garmin.RateLimiter
+	rejected int
+	// Days this connection gave kudos to
+	kudos map[string]bool
+}
+
+func (c *socketConnection) send(m socketMessage) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return c.conn.WriteMessage(websocket.TextMessage, data, time.Now().Add(socketWriteTimeout))
+}
+
+func (c *socketConnection) sendError(message string) error {
+	return c.send(socketMessage{Type: "error", Message: message})
+}
+
+func (c *socketConnection) sendUpdate(m pubsub.Message) error {
+	if err := c.send(socketMessage{Type: m.Type, ID: strconv.FormatUint(m.ID, 10), Data: m.Data}); err != nil {
+		return err
+	}
+	c.lastID = m.ID
+	return nil
+}
+
+func (c *socketConnection) unsubscribe() {
+	if c.sub != nil {
+		c.handler.liveService.Unsubscribe(c.sub)
+		c.sub = nil
+	}
+}
+
+// serve writes from a single goroutine, a second one reads the visitor's messages
+func (c *socketConnection) serve(ctx context.Context) {
+	defer c.unsubscribe()
+
+	requests := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			_, data, err := c.conn.ReadMessage()
+			if err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case requests <- data:
+			case <-ctx.Done():
+				return
+			case <-c.handler.done:
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(socketPingInterval)
+	defer ping.Stop()
+	for {
+		// A nil channel blocks until the visitor subscribed
+		var updates <-chan pubsub.Message
+		if c.sub != nil {
+			updates = c.sub.C
+		}
+
+		var err error
+		select {
+		case <-ctx.Done():
+			c.conn.Close(websocket.CloseGoingAway, "server shutting down", time.Now().Add(time.Second))
+			return
+		case <-c.handler.done:
+			c.conn.Close(websocket.CloseGoingAway, "server shutting down", time.Now().Add(time.Second))
+			return
+		case <-readErr:
+			c.conn.Close(websocket.CloseNormal, "", time.Now().Add(time.Second))
+			return
+		case data := <-requests:
+			err = c.handle(data)
+		case m, ok := <-updates:
+			if !ok {
+				// Dropped for falling behind, catch up from the kept updates
+				c.sub = nil
+				err = c.subscribe(c.trip, c.lastID)
+				break
+			}
+			err = c.sendUpdate(m)
+		case <-ping.C:
+			err = c.conn.Ping(time.Now().Add(socketWriteTimeout))
+		}
+		if err != nil {
+			c.conn.Close(websocket.CloseNormal, "", time.Now().Add(time.Second))
+			return
+		}
+	}
+}
+
+func (c *socketConnection) handle(data []byte) error {
+	if !c.limiter.Allow("messages") {
+		c.rejected++
+		if c.rejected > socketMaxRejected {
+			c.conn.Close(websocket.ClosePolicyViolation, "too many messages", time.Now().Add(time.Second))
+			return websocket.ErrClosed
+		}
+		return c.sendError("Too many messages, slow down")
+	}
+
+	var request socketRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return c.sendError("Invalid message")
+	}
+
+	switch request.Type {
+	case "subscribe":
+		trip := request.Trip
+		if trip == 0 {
+			trip = 1
+		}
+		var lastID uint64
+		if request.LastID != "" {
+			// An unreadable id is treated like an id of a previous process
+			lastID, _ = strconv.ParseUint(request.LastID, 10, 64)
+			if lastID == 0 {
+				lastID = 1
+			}
+		}
+		return c.subscribe(trip, lastID)
+	case "kudos":
+		return c.giveKudos(request.Day)
+	default:
+		return c.sendError("Unknown message type")
+	}
+}
+
+// subscribe follows a trip, replaying the updates after lastID unless it is 0
+func (c *socketConnection) subscribe(trip int64, lastID uint64) error {
+	c.unsubscribe()
+	sub, missed, complete := c.handler.liveService.Subscribe(trip, lastID)
+	c.sub, c.trip, c.lastID = sub, trip, lastID
+	if err := c.send(socketMessage{Type: "subscribed", Trip: trip}); err != nil {
+		return err
+	}
+	if !complete {
+		return c.send(socketMessage{Type: "reset"})
+	}
+	for _, m := range missed {
+		if err := c.sendUpdate(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *socketConnection) giveKudos(day string) error {
+	if _, err := time.Parse("2006-01-02", day); err != nil {
+		return c.sendError("Invalid day")
+	}
+	// The new count reaches this connection like every other follower
+	if c.kudos[day] {
+		return nil
+	}
+	if err := giveKudos(c.handler.repo, c.handler.liveService, c.handler.webhookService, day); err != nil {
+		slog.Error("Failed to give kudos over WebSocket", "error", err)
+		return c.sendError("Failed to update kudos")
+	}
+	c.kudos[day] = true
+	return nil
+}
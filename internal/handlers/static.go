@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Long enough to not collide between versions of a file
+const fingerprintLength = 12
+
+// StaticHandler serves the files below assets/ under /static/. Asset URLs carry
+// a fingerprint of the file's content so they can be cached for a year.
+type StaticHandler struct {
+	fsys  fs.FS
+	files http.Handler
+	// Fingerprint per path relative to assets/, empty in dev mode
+	fingerprints map[string]string
+}
+
+// NewStaticHandler fingerprints all assets, unless dev mode reads them from disk
+// where they change
+func NewStaticHandler(fsys fs.FS, dev bool) (*StaticHandler, error) {
+	assets, err := fs.Sub(fsys, "assets")
+	if err != nil {
+		return nil, err
+	}
+	h := &StaticHandler{
+		fsys:         assets,
+		files:        http.StripPrefix("/static/", http.FileServerFS(assets)),
+		fingerprints: make(map[string]string),
+	}
+	if dev {
+		return h, nil
+	}
+
+	err = fs.WalkDir(assets, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(assets, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		h.fingerprints[name] = hex.EncodeToString(sum[:])[:fingerprintLength]
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fingerprinting assets: %w", err)
+	}
+	return h, nil
+}
+
+// URL returns the address of an asset, e.g. css/main.css
+func (h *StaticHandler) URL(name string) string {
+	url := "/static/" + name
+	if fingerprint, ok := h.fingerprints[name]; ok {
+		url += "?v=" + fingerprint
+	}
+	return url
+}
+
+// ImportMap is a script element mapping the JavaScript modules to their
+// fingerprinted URLs, so imports between modules get the current version as well.
+// html/template would escape the JSON inside a script of type importmap.
+func (h *StaticHandler) ImportMap() template.HTML {
+	imports := make(map[string]string)
+	for name := range h.fingerprints {
+		if path.Ext(name) == ".js" {
+			imports["/static/"+name] = h.URL(name)
+		}
+	}
+	data, err := json.Marshal(map[string]map[string]string{"imports": imports})
+	if err != nil {
+		slog.Error("Error encoding import map", "error", err)
+		return ""
+	}
+	// json.Marshal escapes <, > and &, the JSON cannot end the script early
+	return template.HTML(`<script type="importmap">` + string(data) + `</script>`)
+}
+
+func (h *StaticHandler) GetStatic(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method is not supported.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/static/")
+	fingerprint, ok := h.fingerprints[name]
+	if ok && r.URL.Query().Get("v") == fingerprint {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		// Unversioned URLs have to check for a newer file
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	// Embedded files have no modification time, the file server answers
+	// If-None-Match with this tag instead
+	if ok {
+		w.Header().Set("ETag", `"`+fingerprint+`"`)
+	}
+	h.files.ServeHTTP(w, r)
+}
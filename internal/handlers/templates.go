@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/janschill/track-me/internal/i18n"
+	"github.com/janschill/track-me/internal/utils"
+	"github.com/janschill/track-me/pkg/httpcache"
+)
+
+// Pages and the templates they are made of, the first one is executed
+var pages = map[string][]string{
+	"index": {"templates/layout.html", "templates/index.html"},
+}
+
+// Templates parses the pages once at startup. In dev mode they are parsed again
+// on every use so edits show up on reload.
+type Templates struct {
+	fsys   fs.FS
+	dev    bool
+	static *StaticHandler
+	parsed map[string]*template.Template
+	// Changes with the templates and assets, empty in dev mode
+	version  string
+	parsedAt time.Time
+}
+
+func NewTemplates(fsys fs.FS, static *StaticHandler, dev bool) (*Templates, error) {
+	t := &Templates{fsys: fsys, dev: dev, static: static, parsed: make(map[string]*template.Template), parsedAt: time.Now()}
+	var sources []any
+	for _, name := range slices.Sorted(maps.Keys(pages)) {
+		files := pages[name]
+		tmpl, err := t.parse(name)
+		if err != nil {
+			return nil, err
+		}
+		t.parsed[name] = tmpl
+		for _, file := range files {
+			source, err := fs.ReadFile(fsys, file)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, file, source)
+		}
+	}
+	if !dev {
+		// Maps print sorted by key, the fingerprints give the asset URLs of the pages
+		t.version = httpcache.ETag(append(sources, static.fingerprints)...)
+	}
+	return t, nil
+}
+
+func (t *Templates) parse(name string) (*template.Template, error) {
+	files := pages[name]
+	// The functions depend on the request, these only make their names known
+	funcs := pageFuncs(time.UTC, utils.Metric, i18n.English, nil, t.static)
+	tmpl, err := template.New(path.Base(files[0])).Funcs(funcs).ParseFS(t.fsys, files...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// Version identifies the templates and assets for entity tags of pages. In dev
+// mode it is empty as they change on disk.
+func (t *Templates) Version() string {
+	return t.version
+}
+
+// ParsedAt is when the templates were parsed, pages are not older
+func (t *Templates) ParsedAt() time.Time {
+	return t.parsedAt
+}
+
+// Check reports whether all pages are ready to execute. In dev mode they are
+// parsed again from disk, where an edit may have broken them.
+func (t *Templates) Check() error {
+	for _, name := range slices.Sorted(maps.Keys(pages)) {
+		if t.dev {
+			if _, err := t.parse(name); err != nil {
+				return err
+			}
+		} else if _, ok := t.parsed[name]; !ok {
+			return fmt.Errorf("page %s is not parsed", name)
+		}
+	}
+	return nil
+}
+
+// Page returns a page ready to execute with the functions of a request
+func (t *Templates) Page(name string, funcs template.FuncMap) (*template.Template, error) {
+	var tmpl *template.Template
+	var err error
+	if t.dev {
+		tmpl, err = t.parse(name)
+	} else if parsed, ok := t.parsed[name]; ok {
+		tmpl, err = parsed.Clone()
+	} else {
+		err = fmt.Errorf("unknown page %s", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tmpl.Funcs(funcs), nil
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/janschill/track-me/internal/export"
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/internal/utils"
+	"github.com/janschill/track-me/pkg/httpcache"
+)
+
+type trackLevel struct {
+	MaxZoom int
+	// Maximum distance in meters a hidden event may have from the drawn track
+	Epsilon float64
+}
+
+// Coarser tracks for zoomed out maps, a pixel covers ~150 m at zoom 10
+var trackLevels = []trackLevel{
+	{MaxZoom: 5, Epsilon: 2000},
+	{MaxZoom: 8, Epsilon: 500},
+	{MaxZoom: 11, Epsilon: 100},
+	{MaxZoom: 14, Epsilon: 20},
+	{MaxZoom: 99, Epsilon: 5},
+}
+
+type TrackResponse struct {
+	Level    int     `json:"level"`
+	MaxZoom  int     `json:"maxZoom"`
+	Epsilon  float64 `json:"epsilon"`
+	Points   int     `json:"points"`
+	Polyline string  `json:"polyline"`
+}
+
+type TrackHandler struct {
+	repo *repository.Repository
+
+	mu      sync.Mutex
+	version string
+	cache   map[int]TrackResponse
+}
+
+func NewTrackHandler(repo *repository.Repository) *TrackHandler {
+	return &TrackHandler{
+		repo:  repo,
+		cache: make(map[int]TrackResponse),
+	}
+}
+
+func levelForZoom(zoom int) int {
+	for i, level := range trackLevels {
+		if zoom <= level.MaxZoom {
+			return i
+		}
+	}
+	return len(trackLevels) - 1
+}
+
+// track returns the encoded track of a level, computed once per events version
+func (h *TrackHandler) track(level int, version string) (TrackResponse, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.version != version {
+		h.version = version
+		h.cache = make(map[int]TrackResponse)
+	}
+	if response, ok := h.cache[level]; ok {
+		return response, nil
+	}
+
+	events, err := h.repo.Events.All()
+	if err != nil {
+		return TrackResponse{}, err
+	}
+
+	simplified := utils.Simplify(events, trackLevels[level].Epsilon)
+	response := TrackResponse{
+		Level:    level,
+		MaxZoom:  trackLevels[level].MaxZoom,
+		Epsilon:  trackLevels[level].Epsilon,
+		Points:   len(simplified),
+		Polyline: export.EncodePolyline(simplified),
+	}
+	h.cache[level] = response
+	slog.Debug("Simplified track", "level", level, "events", len(events), "simplified", len(simplified))
+	return response, nil
+}
+
+// GetTrack serves the traveled path as an encoded polyline for the map zoom in ?zoom=.
+// The ETag changes when new events arrive, so browsers revalidate instead of downloading again.
+func (h *TrackHandler) GetTrack(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method is not supported.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	zoom, err := strconv.Atoi(r.URL.Query().Get("zoom"))
+	if err != nil {
+		zoom = trackLevels[len(trackLevels)-1].MaxZoom
+	}
+	level := levelForZoom(zoom)
+
+	version, err := h.repo.Events.Version()
+	if err != nil {
+		http.Error(w, "An unexpected error happened.", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error retrieving events version", "error", err)
+		return
+	}
+
+	// Compress weakens the tag of a gzipped polyline, NotModified compares weakly
+	etag := fmt.Sprintf("\"track-%d-%s\"", level, version)
+	w.Header().Set("Cache-Control", "no-cache")
+	if httpcache.NotModified(w, r, etag, time.Time{}) {
+		return
+	}
+
+	response, err := h.track(level, version)
+	if err != nil {
+		http.Error(w, "An unexpected error happened.", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error retrieving events", "error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
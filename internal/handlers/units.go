@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/janschill/track-me/internal/utils"
+)
+
+const unitsCookie = "units"
+
+// unitSystem picks the units of ?units=, then of the units cookie, then the
+// trip's default. A valid ?units= is remembered in the cookie.
+func unitSystem(w http.ResponseWriter, r *http.Request, fallback utils.UnitSystem) utils.UnitSystem {
+	if units, ok := utils.ParseUnitSystem(r.URL.Query().Get("units")); ok {
+		http.SetCookie(w, &http.Cookie{
+			Name:     unitsCookie,
+			Value:    string(units),
+			Path:     "/",
+			MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+			SameSite: http.SameSiteLaxMode,
+		})
+		return units
+	}
+	if cookie, err := r.Cookie(unitsCookie); err == nil {
+		if units, ok := utils.ParseUnitSystem(cookie.Value); ok {
+			return units
+		}
+	}
+	return fallback
+}
+
+// number lets template helpers take the int and float fields of the page data alike
+func number(v any) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/internal/service"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 500
+)
+
+// WebhookHandler lets admins register webhooks and read their delivery log
+type WebhookHandler struct {
+	repo           *repository.Repository
+	webhookService *service.WebhookService
+}
+
+type webhookResponse struct {
+	ID        int64    `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	CreatedAt int64    `json:"createdAt"`
+	// Only returned when the webhook is registered
+	Secret string `json:"secret,omitempty"`
+}
+
+type deliveryResponse struct {
+	ID     int64  `json:"id"`
+	Event  string `json:"event"`
+	Status string `json:"status"`
+	// Unix seconds of the next attempt, only while pending
+	NextAttemptAt  int64           `json:"nextAttemptAt,omitempty"`
+	Attempts       int             `json:"attempts"`
+	LastAttemptAt  int64           `json:"lastAttemptAt,omitempty"`
+	ResponseStatus int             `json:"responseStatus,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	CreatedAt      int64           `json:"createdAt"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+func NewWebhookHandler(repo *repository.Repository, webhookService *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		repo:           repo,
+		webhookService: webhookService,
+	}
+}
+
+func newWebhookResponse(wh repository.Webhook) webhookResponse {
+	return webhookResponse{ID: wh.ID, URL: wh.URL, Events: wh.Events, CreatedAt: wh.CreatedAt}
+}
+
+// Webhooks lists the webhooks on GET and registers one on POST with a JSON body
+// {"url", "events"}. The response of the registration carries the secret the
+// deliveries are signed with.
+func (h *WebhookHandler) Webhooks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.getWebhooks(w, r)
+	case http.MethodPost:
+		h.createWebhook(w, r)
+	default:
+		http.Error(w, "Method is not supported.", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *WebhookHandler) getWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.repo.Webhooks.All()
+	if err != nil {
+		http.Error(w, "An unexpected error happened.", http.StatusInternalServerError)
+		return
+	}
+	response := make([]webhookResponse, len(webhooks))
+	for i, wh := range webhooks {
+		response[i] = newWebhookResponse(wh)
+	}
+	writeJSON(w, response)
+}
+
+func (h *WebhookHandler) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var requestData struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&requestData); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	webhook, err := h.webhookService.NewWebhook(requestData.URL, requestData.Events)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	webhook.ID, err = h.repo.Webhooks.Create(webhook)
+	if err != nil {
+		http.Error(w, "Failed to register webhook", http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(r.Context(), "Registered webhook", "webhook", webhook.ID, "events", webhook.Events)
+
+	response := newWebhookResponse(webhook)
+	response.Secret = webhook.Secret
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+	}
+}
+
+// webhook reads the webhook of the {id} path segment
+func (h *WebhookHandler) webhook(w http.ResponseWriter, r *http.Request) (repository.Webhook, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid webhook id", http.StatusBadRequest)
+		return repository.Webhook{}, false
+	}
+	webhook, err := h.repo.Webhooks.Get(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Webhook does not exist", http.StatusNotFound)
+		return repository.Webhook{}, false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrieving webhook", "webhook", id, "error", err)
+		http.Error(w, "An unexpected error happened.", http.StatusInternalServerError)
+		return repository.Webhook{}, false
+	}
+	return webhook, true
+}
+
+// Webhook serves a webhook on GET and removes it with its deliveries on DELETE
+func (h *WebhookHandler) Webhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		http.Error(w, "Method is not supported.", http.StatusMethodNotAllowed)
+		return
+	}
+	webhook, ok := h.webhook(w, r)
+	if !ok {
+		return
+	}
+	if r.Method == http.MethodGet {
+		writeJSON(w, newWebhookResponse(webhook))
+		return
+	}
+
+	if err := h.repo.Webhooks.Delete(webhook.ID); err != nil {
+		http.Error(w, "Failed to remove webhook", http.StatusInternalServerError)
+		return
+	}
+	slog.InfoContext(r.Context(), "Removed webhook", "webhook", webhook.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDeliveries serves the delivery log of a webhook, newest first. ?limit= sets
+// how many deliveries are returned.
+func (h *WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method is not supported.", http.StatusMethodNotAllowed)
+		return
+	}
+	limit := defaultDeliveryLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(parsed, maxDeliveryLimit)
+	}
+	webhook, ok := h.webhook(w, r)
+	if !ok {
+		return
+	}
+
+	deliveries, err := h.repo.Webhooks.Deliveries(webhook.ID, limit)
+	if err != nil {
+		http.Error(w, "An unexpected error happened.", http.StatusInternalServerError)
+		return
+	}
+	response := make([]deliveryResponse, len(deliveries))
+	for i, d := range deliveries {
+		response[i] = deliveryResponse{
+			ID:             d.ID,
+			Event:          d.Event,
+			Status:         d.Status,
+			Attempts:       d.Attempts,
+			LastAttemptAt:  d.LastAttemptAt,
+			ResponseStatus: d.ResponseStatus,
+			Error:          d.Error,
+			CreatedAt:      d.CreatedAt,
+			Payload:        json.RawMessage(d.Payload),
+		}
+		if d.Status == repository.DeliveryPending {
+			response[i].NextAttemptAt = d.NextAttemptAt
+		}
+	}
+	writeJSON(w, response)
+}
@@ -0,0 +1,189 @@
+// Package i18n holds the message catalogues of the website and formats numbers
+// and dates the way a locale expects them.
+//
+// Every locale is a flat JSON object of keys to messages in locales/<tag>.json.
+// Messages may contain fmt verbs which are filled in by T. Keys starting with
+// "format." and "month." describe how numbers and dates are written.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed locales/*.json
+var files embed.FS
+
+type Locale struct {
+	Tag      string
+	messages map[string]string
+}
+
+var (
+	locales = map[string]*Locale{}
+	// Tags sorted alphabetically
+	tags []string
+)
+
+// English is the default locale and the fallback for missing messages
+var English *Locale
+
+func init() {
+	entries, err := files.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	for _, entry := range entries {
+		data, err := files.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		l := &Locale{Tag: strings.TrimSuffix(entry.Name(), ".json")}
+		if err := json.Unmarshal(data, &l.messages); err != nil {
+			panic(fmt.Sprintf("i18n: invalid catalogue %s: %v", entry.Name(), err))
+		}
+		locales[l.Tag] = l
+		tags = append(tags, l.Tag)
+	}
+	sort.Strings(tags)
+	English = locales["en"]
+}
+
+// Tags returns the tags of all locales with a catalogue
+func Tags() []string {
+	return tags
+}
+
+// Get returns the locale of a tag like "de" or "de-AT", ok is false when
+// there is no catalogue for its language
+func Get(tag string) (*Locale, bool) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if l, ok := locales[tag]; ok {
+		return l, true
+	}
+	if base, _, found := strings.Cut(tag, "-"); found {
+		l, ok := locales[base]
+		return l, ok
+	}
+	return nil, false
+}
+
+// Match picks the locale for an Accept-Language header, e.g.
+// "de-CH, de;q=0.9, en;q=0.8". It falls back to English.
+func Match(acceptLanguage string) *Locale {
+	best, bestQ := English, 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		// Ties go to the earlier language
+		if l, ok := Get(tag); ok && q > bestQ {
+			best, bestQ = l, q
+		}
+	}
+	return best
+}
+
+// Has reports whether the locale's own catalogue contains a key
+func (l *Locale) Has(key string) bool {
+	_, ok := l.messages[key]
+	return ok
+}
+
+// Keys returns the keys of the locale's catalogue
+func (l *Locale) Keys() []string {
+	keys := make([]string, 0, len(l.messages))
+	for key := range l.messages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Messages returns the catalogue for clients that translate themselves.
+// Missing messages are filled in from English.
+func (l *Locale) Messages() map[string]string {
+	messages := make(map[string]string, len(English.messages))
+	for key, message := range English.messages {
+		messages[key] = message
+	}
+	for key, message := range l.messages {
+		messages[key] = message
+	}
+	return messages
+}
+
+// T translates a key and formats the message with args. Messages missing in the
+// locale fall back to English, unknown keys are returned as is.
+func (l *Locale) T(key string, args ...any) string {
+	message, ok := l.messages[key]
+	if !ok {
+		if message, ok = English.messages[key]; !ok {
+			return key
+		}
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// Number formats a number with a fixed number of decimals and the locale's
+// decimal and digit group separators, e.g. 3,895.5 or 3.895,5
+func (l *Locale) Number(v float64, decimals int) string {
+	s := strconv.FormatFloat(v, 'f', decimals, 64)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	integer, fraction, _ := strings.Cut(s, ".")
+
+	group := l.T("format.group")
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteString(group)
+		}
+		b.WriteRune(digit)
+	}
+	if fraction != "" {
+		b.WriteString(l.T("format.decimal"))
+		b.WriteString(fraction)
+	}
+	return b.String()
+}
+
+// Month returns the name of a month in the locale
+func (l *Locale) Month(m time.Month) string {
+	return l.T(fmt.Sprintf("month.%d", int(m)))
+}
+
+// Format formats a time with a Go layout from the catalogue and replaces the
+// English month name with the locale's
+func (l *Locale) Format(t time.Time, layoutKey string) string {
+	s := t.Format(l.T(layoutKey))
+	return strings.Replace(s, t.Month().String(), l.Month(t.Month()), 1)
+}
+
+// Day formats the date of a day, e.g. 09 September or 09. September
+func (l *Locale) Day(t time.Time) string {
+	return l.Format(t, "format.day")
+}
+
+// DateTime formats a point in time on a day, e.g. on 09 September at 08:15
+func (l *Locale) DateTime(t time.Time) string {
+	return l.T("format.on-day-at-time", l.Day(t), t.Format(l.T("format.time")))
+}
@@ -0,0 +1,150 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var (
+	templateKey = regexp.MustCompile(`\bt "([^"]+)"`)
+	scriptKey   = regexp.MustCompile(`\bt\('([^']+)'`)
+)
+
+// usedKeys collects the keys translated in the files matching a glob
+func usedKeys(t *testing.T, glob string, pattern *regexp.Regexp) map[string]string {
+	files, err := filepath.Glob(glob)
+	if err != nil || len(files) == 0 {
+		t.Fatalf("No files match %s: %v", glob, err)
+	}
+	keys := map[string]string{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, match := range pattern.FindAllStringSubmatch(string(data), -1) {
+			keys[match[1]] = file
+		}
+	}
+	return keys
+}
+
+func TestLocalesHaveUsedKeys(t *testing.T) {
+	keys := usedKeys(t, "../../web/templates/*.html", templateKey)
+	for key, file := range usedKeys(t, "../../web/assets/js/modules/*.js", scriptKey) {
+		keys[key] = file
+	}
+
+	for _, tag := range Tags() {
+		l, _ := Get(tag)
+		for key, file := range keys {
+			if !l.Has(key) {
+				t.Errorf("Locale %s is missing %q used in %s", tag, key, file)
+			}
+		}
+	}
+}
+
+func TestLocalesHaveSameKeys(t *testing.T) {
+	for _, tag := range Tags() {
+		l, _ := Get(tag)
+		for _, key := range English.Keys() {
+			if !l.Has(key) {
+				t.Errorf("Locale %s is missing %q", tag, key)
+			}
+		}
+		for _, key := range l.Keys() {
+			if !English.Has(key) {
+				t.Errorf("Locale %s has %q which English does not have", tag, key)
+			}
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{"", "en"},
+		{"de", "de"},
+		{"de-CH, de;q=0.9, en;q=0.8", "de"},
+		{"fr-FR, fr;q=0.9, de;q=0.7, en;q=0.8", "en"},
+		{"fr, de;q=0.5", "de"},
+		{"fr", "en"},
+		{"en-US,en;q=0.9", "en"},
+		{"DE-at", "de"},
+	}
+
+	for _, tt := range tests {
+		if result := Match(tt.header); result.Tag != tt.expected {
+			t.Errorf("Match(%q) = %s; expected %s", tt.header, result.Tag, tt.expected)
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	german, _ := Get("de")
+	tests := []struct {
+		name     string
+		result   string
+		expected string
+	}{
+		{"message", german.T("days.title"), "Tage"},
+		{"arguments", german.T("route.days", 30), "30 Tage"},
+		{"unknown key", german.T("does.not.exist"), "does.not.exist"},
+		{"english", English.T("message.wrote", "Pat"), "Pat wrote"},
+	}
+
+	for _, tt := range tests {
+		if tt.result != tt.expected {
+			t.Errorf("%s = %q; expected %q", tt.name, tt.result, tt.expected)
+		}
+	}
+}
+
+func TestNumber(t *testing.T) {
+	german, _ := Get("de")
+	tests := []struct {
+		locale   *Locale
+		value    float64
+		decimals int
+		expected string
+	}{
+		{English, 3895.04, 1, "3,895.0"},
+		{English, 1234567, 0, "1,234,567"},
+		{English, 999.96, 1, "1,000.0"},
+		{English, -27.4, 1, "-27.4"},
+		{English, -1234.5, 1, "-1,234.5"},
+		{german, 3895.04, 1, "3.895,0"},
+		{german, 0.5, 2, "0,50"},
+		{german, 123, 0, "123"},
+	}
+
+	for _, tt := range tests {
+		if result := tt.locale.Number(tt.value, tt.decimals); result != tt.expected {
+			t.Errorf("%s.Number(%v, %d) = %q; expected %q", tt.locale.Tag, tt.value, tt.decimals, result, tt.expected)
+		}
+	}
+}
+
+func TestDateTime(t *testing.T) {
+	german, _ := Get("de")
+	ts := time.Date(2024, time.March, 5, 8, 15, 0, 0, time.UTC)
+	tests := []struct {
+		locale   *Locale
+		expected string
+	}{
+		{English, "on 05 March at 08:15"},
+		{german, "am 05. März um 08:15"},
+	}
+
+	for _, tt := range tests {
+		if result := tt.locale.DateTime(ts); result != tt.expected {
+			t.Errorf("%s.DateTime(%v) = %q; expected %q", tt.locale.Tag, ts, result, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/pkg/fit"
+	"github.com/janschill/track-me/pkg/gpx"
+)
+
+// inReach tracks every 10 minutes, a recorded point closer than this
+// to an inReach event is already covered by satellite data
+const coverageWindowInSeconds = 15 * 60
+
+// Bike computers record every second, thin out to keep the events table small
+const minPointIntervalInSeconds = 30
+
+type Point struct {
+	TimeStamp int64
+	Latitude  float64
+	Longitude float64
+	Altitude  float64
+	Speed     float64 // m/s
+}
+
+type Result struct {
+	Source   string
+	Total    int
+	Imported int
+	Skipped  int
+	// Range of the imported events in unix seconds
+	From int64
+	To   int64
+}
+
+type Importer struct {
+	events *repository.EventRepository
+}
+
+func New(events *repository.EventRepository) *Importer {
+	return &Importer{events: events}
+}
+
+// Parse reads a GPX or FIT file, the format is picked by the file extension
+func Parse(name string, r io.Reader) ([]Point, string, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".gpx":
+		points, err := ParseGPX(r)
+		return points, repository.SourceGPX, err
+	case ".fit":
+		points, err := ParseFIT(r)
+		return points, repository.SourceFIT, err
+	default:
+		return nil, "", fmt.Errorf("unsupported file type %q, expected .gpx or .fit", filepath.Ext(name))
+	}
+}
+
+func ParseGPX(r io.Reader) ([]Point, error) {
+	g, err := gpx.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var points []Point
+	for _, p := range g.Points() {
+		if p.Time.IsZero() {
+			continue
+		}
+		points = append(points, Point{
+			TimeStamp: p.Time.Unix(),
+			Latitude:  p.Latitude,
+			Longitude: p.Longitude,
+			Altitude:  p.Elevation,
+		})
+	}
+	return points, nil
+}
+
+func ParseFIT(r io.Reader) ([]Point, error) {
+	records, err := fit.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var points []Point
+	for _, record := range records {
+		if !record.HasPosition {
+			continue
+		}
+		points = append(points, Point{
+			TimeStamp: record.Time.Unix(),
+			Latitude:  record.Latitude,
+			Longitude: record.Longitude,
+			Altitude:  record.Altitude,
+			Speed:     record.Speed,
+		})
+	}
+	return points, nil
+}
+
+// Import merges points into the events of a trip. Points already covered by
+// inReach events or by an earlier import are skipped.
+func (i *Importer) Import(tripID int64, source string, points []Point) (Result, error) {
+	result := Result{Source: source, Total: len(points)}
+	if len(points) == 0 {
+		return result, nil
+	}
+
+	sort.Slice(points, func(a, b int) bool { return points[a].TimeStamp < points[b].TimeStamp })
+	from := points[0].TimeStamp - coverageWindowInSeconds
+	to := points[len(points)-1].TimeStamp + coverageWindowInSeconds
+	existing, err := i.events.AllInRange(tripID, max(from, 1), to)
+	if err != nil {
+		return result, err
+	}
+
+	events := merge(tripID, source, points, existing)
+	result.Imported = len(events)
+	result.Skipped = result.Total - result.Imported
+	if len(events) == 0 {
+		return result, nil
+	}
+
+	if err := i.events.CreateMany(events); err != nil {
+		return result, err
+	}
+	result.From = events[0].TimeStamp
+	result.To = events[len(events)-1].TimeStamp
+
+	slog.Info("Imported points", "imported", result.Imported, "total", result.Total, "source", source, "trip", tripID)
+	return result, nil
+}
+
+// merge turns sorted points into events, leaving out points that are
+// covered by existing events
+func merge(tripID int64, source string, points []Point, existing []repository.Event) []repository.Event {
+	var inReach []int64
+	imported := make(map[int64]bool)
+	for _, e := range existing {
+		if e.Source == repository.SourceInReach {
+			inReach = append(inReach, e.TimeStamp)
+		} else {
+			imported[e.TimeStamp] = true
+		}
+	}
+	sort.Slice(inReach, func(a, b int) bool { return inReach[a] < inReach[b] })
+
+	var events []repository.Event
+	var last int64
+	for _, p := range points {
+		if imported[p.TimeStamp] || covered(inReach, p.TimeStamp) {
+			continue
+		}
+		if len(events) > 0 && p.TimeStamp-last < minPointIntervalInSeconds {
+			continue
+		}
+
+		events = append(events, repository.Event{
+			TripID:    tripID,
+			TimeStamp: p.TimeStamp,
+			Latitude:  p.Latitude,
+			Longitude: p.Longitude,
+			Altitude:  p.Altitude,
+			GpsFix:    3,             // recordings only contain points with a 3D fix
+			Speed:     p.Speed * 3.6, // km/h like the inReach
+			Source:    source,
+		})
+		last = p.TimeStamp
+	}
+	return events
+}
+
+func covered(sorted []int64, ts int64) bool {
+	i := sort.Search(len(sorted), func(i int) bool { return sorted[i] >= ts })
+	if i < len(sorted) && sorted[i]-ts <= coverageWindowInSeconds {
+		return true
+	}
+	return i > 0 && ts-sorted[i-1] <= coverageWindowInSeconds
+}
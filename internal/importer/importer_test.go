@@ -0,0 +1,69 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/janschill/track-me/internal/repository"
+)
+
+func TestParse(t *testing.T) {
+	gpxFile := `<gpx><trk><trkseg>
+		<trkpt lat="48.1" lon="-115.1"><ele>800</ele><time>2024-09-09T14:00:00Z</time></trkpt>
+		<trkpt lat="48.2" lon="-115.2"><ele>810</ele></trkpt>
+	</trkseg></trk></gpx>`
+
+	points, source, err := Parse("ride.GPX", strings.NewReader(gpxFile))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if source != repository.SourceGPX {
+		t.Errorf("Parse() source = %q; expected %q", source, repository.SourceGPX)
+	}
+	if len(points) != 1 || points[0].TimeStamp != 1725890400 {
+		t.Errorf("Parse() points = %+v; expected one point at 1725890400", points)
+	}
+
+	if _, _, err := Parse("ride.tcx", strings.NewReader("")); err == nil {
+		t.Errorf("Parse() expected an error for unsupported file types")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	existing := []repository.Event{
+		{TimeStamp: 10000, Source: repository.SourceInReach},
+		{TimeStamp: 10600, Source: repository.SourceInReach},
+		{TimeStamp: 20000, Source: repository.SourceGPX},
+	}
+	points := []Point{
+		{TimeStamp: 9500},  // covered by inReach
+		{TimeStamp: 10300}, // covered by inReach
+		{TimeStamp: 12000},
+		{TimeStamp: 12010}, // too close to the previous point
+		{TimeStamp: 12100, Speed: 5},
+		{TimeStamp: 20000}, // imported before
+		{TimeStamp: 20100},
+	}
+
+	events := merge(1, repository.SourceFIT, points, existing)
+
+	var timestamps []int64
+	for _, e := range events {
+		timestamps = append(timestamps, e.TimeStamp)
+		if e.Source != repository.SourceFIT || e.TripID != 1 {
+			t.Errorf("merge() event = %+v; expected trip 1 with source %q", e, repository.SourceFIT)
+		}
+	}
+	expected := []int64{12000, 12100, 20100}
+	if len(timestamps) != len(expected) {
+		t.Fatalf("merge() timestamps = %v; expected %v", timestamps, expected)
+	}
+	for i := range expected {
+		if timestamps[i] != expected[i] {
+			t.Fatalf("merge() timestamps = %v; expected %v", timestamps, expected)
+		}
+	}
+	if events[1].Speed != 18 {
+		t.Errorf("merge() speed = %v; expected 18 km/h", events[1].Speed)
+	}
+}
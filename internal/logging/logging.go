@@ -0,0 +1,93 @@
+// Package logging writes structured JSON logs that carry the ID of the request
+// they belong to and leave out secrets and what visitors wrote.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Redacted replaces the value of sensitive attributes
+const Redacted = "[redacted]"
+
+type key int
+
+const requestIDKey key = 0
+
+// Attribute keys whose values never end up in the logs, in any group
+var sensitive = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"email":         true,
+	"message":       true,
+	"name":          true,
+	"password":      true,
+	"secret":        true,
+	"sender":        true,
+	"text":          true,
+	"token":         true,
+}
+
+// WithRequestID returns a context whose log lines carry the request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID returns the request ID of a context, empty outside of requests
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// ParseLevel reads a level like debug, info, warn or error, empty is info
+func ParseLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if level == "" {
+		return slog.LevelInfo, nil
+	}
+	err := l.UnmarshalText([]byte(level))
+	return l, err
+}
+
+// New returns a logger writing JSON lines of the given level and above
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	})
+	return slog.New(contextHandler{handler})
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	// The built-in attributes are at the top level
+	if len(groups) == 0 && (a.Key == slog.MessageKey || a.Key == slog.TimeKey || a.Key == slog.LevelKey) {
+		return a
+	}
+	k := strings.ToLower(a.Key)
+	if sensitive[k] || strings.HasSuffix(k, "token") || strings.HasSuffix(k, "password") {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
+
+// contextHandler adds the request ID of the context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if requestID := RequestID(ctx); requestID != "" {
+		r.AddAttrs(slog.String("request_id", requestID))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
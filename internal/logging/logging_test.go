@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestRedaction(t *testing.T) {
+	tests := []struct {
+		name     string
+		attrs    []any
+		key      string
+		expected any
+	}{
+		{"token", []any{"token", "s3cret"}, "token", Redacted},
+		{"suffix", []any{"albumToken", "abc"}, "albumToken", Redacted},
+		{"case", []any{"Authorization", "Bearer abc"}, "Authorization", Redacted},
+		{"visitor message", []any{"message", "Go Jan!"}, "message", Redacted},
+		{"visitor name", []any{"name", "Anna"}, "name", Redacted},
+		{"kept", []any{"trip", 1}, "trip", float64(1)},
+		{"error", []any{"error", "no such table"}, "error", "no such table"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			New(&buf, slog.LevelInfo).Info("Something happened", tt.attrs...)
+			var line map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if line[tt.key] != tt.expected {
+				t.Errorf("%s = %v; expected %v", tt.key, line[tt.key], tt.expected)
+			}
+			if line["msg"] != "Something happened" {
+				t.Errorf("msg = %v; expected the message to be kept", line["msg"])
+			}
+		})
+	}
+}
+
+func TestRedactionInGroups(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, slog.LevelInfo).WithGroup("form").Info("Form", "name", "Anna", "day", "2024-06-14")
+	var line struct {
+		Form map[string]string `json:"form"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if line.Form["name"] != Redacted || line.Form["day"] != "2024-06-14" {
+		t.Errorf("form = %v; expected only the name to be redacted", line.Form)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo).With("component", "test")
+	logger.InfoContext(WithRequestID(context.Background(), "abc123"), "Handled")
+	logger.InfoContext(context.Background(), "Outside")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines; expected 2", len(lines))
+	}
+	expected := []string{"abc123", ""}
+	for i, data := range lines {
+		var line map[string]any
+		if err := json.Unmarshal(data, &line); err != nil {
+			t.Fatalf("json.Unmarshal() error = %v", err)
+		}
+		requestID, _ := line["request_id"].(string)
+		if requestID != expected[i] {
+			t.Errorf("line %d request_id = %q; expected %q", i, requestID, expected[i])
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		level    string
+		expected slog.Level
+		err      bool
+	}{
+		{"", slog.LevelInfo, false},
+		{"debug", slog.LevelDebug, false},
+		{"WARN", slog.LevelWarn, false},
+		{"loud", 0, true},
+	}
+	for _, tt := range tests {
+		level, err := ParseLevel(tt.level)
+		if (err != nil) != tt.err || (!tt.err && level != tt.expected) {
+			t.Errorf("ParseLevel(%q) = %v, %v; expected %v", tt.level, level, err, tt.expected)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"log"
 	"net/http"
 	"os"
 	"strings"
@@ -17,14 +16,12 @@ func Authorize(next http.HandlerFunc) http.HandlerFunc {
 
 		token := strings.TrimPrefix(authHeader, "Bearer ")
 		expectedToken := os.Getenv("AUTHORIZATION_TOKEN")
-		log.Printf("token: %v", token)
 
-		if token == expectedToken {
+		if expectedToken != "" && token == expectedToken {
 			next.ServeHTTP(w, r)
 			return
 		}
 
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		next.ServeHTTP(w, r)
 	})
 }
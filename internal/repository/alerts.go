@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"database/sql"
+	"log/slog"
+)
+
+// Alert is the open watchdog alert of a trip whose tracker went silent
+type Alert struct {
+	TripID int64
+	// Escalation level last notified, from 1
+	Level int
+	// Unix seconds of the last position before the silence
+	LastPositionAt int64
+	// Unix seconds of the last notification
+	NotifiedAt int64
+}
+
+type AlertRepository struct {
+	db *sql.DB
+}
+
+func NewAlertRepository(db *sql.DB) *AlertRepository {
+	return &AlertRepository{db: db}
+}
+
+// Get returns sql.ErrNoRows when the trip has no open alert
+func (r *AlertRepository) Get(tripID int64) (Alert, error) {
+	var a Alert
+	err := r.db.QueryRow(`SELECT tripId, level, lastPositionAt, notifiedAt FROM alerts WHERE tripId = ?`, tripID).
+		Scan(&a.TripID, &a.Level, &a.LastPositionAt, &a.NotifiedAt)
+	if err != nil {
+		return Alert{}, err
+	}
+	return a, nil
+}
+
+// Save opens an alert or replaces the open one of the trip
+func (r *AlertRepository) Save(a Alert) error {
+	_, err := r.db.Exec(`
+		INSERT INTO alerts(tripId, level, lastPositionAt, notifiedAt) VALUES(?,?,?,?)
+		ON CONFLICT(tripId) DO UPDATE SET level = excluded.level, lastPositionAt = excluded.lastPositionAt, notifiedAt = excluded.notifiedAt
+	`, a.TripID, a.Level, a.LastPositionAt, a.NotifiedAt)
+	if err != nil {
+		slog.Error("Error saving alert", "trip", a.TripID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// Resolve closes the open alert of a trip
+func (r *AlertRepository) Resolve(tripID int64) error {
+	_, err := r.db.Exec(`DELETE FROM alerts WHERE tripId = ?`, tripID)
+	if err != nil {
+		slog.Error("Error resolving alert", "trip", tripID, "error", err)
+		return err
+	}
+	return nil
+}
@@ -6,6 +6,11 @@ type Repository struct {
 	Messages *MessageRepository
 	Events   *EventRepository
 	Kudos    *KudosRepository
+	Records  *RecordRepository
+	Trips    *TripRepository
+	POIs     *POIRepository
+	Alerts   *AlertRepository
+	Webhooks *WebhookRepository
 }
 
 func NewRepository(db *sql.DB) *Repository {
@@ -13,5 +18,10 @@ func NewRepository(db *sql.DB) *Repository {
 		Messages: NewMessageRepository(db),
 		Events:   NewEventRepository(db),
 		Kudos:    NewKudosRepository(db),
+		Records:  NewRecordRepository(db),
+		Trips:    NewTripRepository(db),
+		POIs:     NewPOIRepository(db),
+		Alerts:   NewAlertRepository(db),
+		Webhooks: NewWebhookRepository(db),
 	}
 }
@@ -2,7 +2,8 @@ package repository
 
 import (
 	"database/sql"
-	"log"
+	"fmt"
+	"log/slog"
 	"time"
 )
 
@@ -21,8 +22,18 @@ type Event struct {
 	GpsFix      int
 	Course      float64
 	Speed       float64
+	Source      string
+	// Terrain height from a DEM at the position, nil if not corrected
+	CorrectedAltitude *float64
 }
 
+// Where an event came from. Everything pushed by Garmin Outbound is from the inReach.
+const (
+	SourceInReach = "inreach"
+	SourceGPX     = "gpx"
+	SourceFIT     = "fit"
+)
+
 type Address struct {
 	Address string
 }
@@ -45,15 +56,43 @@ func NewEventRepository(db *sql.DB) *EventRepository {
 func (r *EventRepository) Create(e Event) error {
 	tx, err := r.db.Begin()
 	if err != nil {
-		log.Fatal("Couldn't begin save transaction for Event")
+		slog.Error("Couldn't begin save transaction for Event", "error", err)
+		return err
+	}
+
+	if err := insertEvent(tx, e); err != nil {
+		tx.Rollback()
 		return err
 	}
-	stmt, err := tx.Prepare("INSERT INTO events(tripId, imei, messageCode, freeText, timeStamp, latitude, longitude, altitude, gpsFix, course, speed, autonomous, lowBattery, intervalChange, resetDetected) VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)")
+
+	slog.Debug("Saving new event to database")
+	return tx.Commit()
+}
+
+// CreateMany saves all events in a single transaction
+func (r *EventRepository) CreateMany(events []Event) error {
+	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
 
-	res, err := stmt.Exec(e.TripID, e.Imei, e.MessageCode, e.FreeText, e.TimeStamp, e.Latitude, e.Longitude, e.Altitude, e.GpsFix, e.Course, e.Speed, e.Status.Autonomous, e.Status.LowBattery, e.Status.IntervalChange, e.Status.ResetDetected)
+	for _, e := range events {
+		if err := insertEvent(tx, e); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	slog.Debug("Saving new events to database", "count", len(events))
+	return tx.Commit()
+}
+
+func insertEvent(tx *sql.Tx, e Event) error {
+	if e.Source == "" {
+		e.Source = SourceInReach
+	}
+	res, err := tx.Exec("INSERT INTO events(tripId, imei, messageCode, freeText, timeStamp, latitude, longitude, altitude, gpsFix, course, speed, autonomous, lowBattery, intervalChange, resetDetected, source, correctedAltitude) VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)",
+		e.TripID, e.Imei, e.MessageCode, e.FreeText, e.TimeStamp, e.Latitude, e.Longitude, e.Altitude, e.GpsFix, e.Course, e.Speed, e.Status.Autonomous, e.Status.LowBattery, e.Status.IntervalChange, e.Status.ResetDetected, e.Source, e.CorrectedAltitude)
 	if err != nil {
 		return err
 	}
@@ -70,13 +109,12 @@ func (r *EventRepository) Create(e Event) error {
 		}
 	}
 
-	log.Printf("Saving new event to database")
-	return tx.Commit()
+	return nil
 }
 
 func (r *EventRepository) All() ([]Event, error) {
 	rows, err := r.db.Query(`
-		SELECT id, messageCode, latitude, longitude, altitude, speed, course, gpsFix, timeStamp
+		SELECT id, messageCode, latitude, longitude, altitude, correctedAltitude, speed, course, gpsFix, timeStamp
 		FROM events
 		WHERE messageCode NOT IN (3, 14, 15, 16, 66, 67)
 		AND messageCode NOT BETWEEN 24 AND 63
@@ -84,7 +122,7 @@ func (r *EventRepository) All() ([]Event, error) {
 		ORDER BY timeStamp
 	`)
 	if err != nil {
-		log.Printf("Error querying events: %v", err)
+		slog.Error("Error querying events", "error", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -93,42 +131,165 @@ func (r *EventRepository) All() ([]Event, error) {
 	for rows.Next() {
 		var e Event
 
-		err := rows.Scan(&e.ID, &e.MessageCode, &e.Latitude, &e.Longitude, &e.Altitude, &e.Speed, &e.Course, &e.GpsFix, &e.TimeStamp)
+		err := rows.Scan(&e.ID, &e.MessageCode, &e.Latitude, &e.Longitude, &e.Altitude, &e.CorrectedAltitude, &e.Speed, &e.Course, &e.GpsFix, &e.TimeStamp)
 		if err != nil {
-			log.Printf("Error scanning event row: %v", err)
+			slog.Error("Error scanning event row", "error", err)
 		}
 		events = append(events, e)
 	}
 	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating event rows: %v", err)
+		slog.Error("Error iterating event rows", "error", err)
 	}
 
 	return events, nil
 }
 
-func (r *EventRepository) Last() (Event, error) {
-	var e Event
-	row := r.db.QueryRow(`
-		SELECT id, messageCode, latitude, longitude, altitude, speed, course, gpsFix, timeStamp
+// Track returns every event of a trip with all columns that has a position and
+// is not a message, the same events as All, ordered by time
+func (r *EventRepository) Track(tripID int64) ([]Event, error) {
+	query := `
+		SELECT ` + eventColumns + `
 		FROM events
-		WHERE messageCode NOT IN (3, 14, 15, 16, 66, 67)
+		WHERE tripId = ?
+		AND messageCode NOT IN (3, 14, 15, 16, 66, 67)
 		AND messageCode NOT BETWEEN 24 AND 63
+		AND (latitude != 0.0 OR longitude != 0.0)
 		ORDER BY timeStamp
-		DESC LIMIT 1
-	`)
-	err := row.Scan(&e.ID, &e.Latitude, &e.Longitude, &e.Altitude, &e.Speed, &e.Course, &e.GpsFix, &e.TimeStamp)
+	`
+	return r.query(query, tripID)
+}
+
+// First returns the earliest event of a trip with a position, sql.ErrNoRows if there is none
+func (r *EventRepository) First(tripID int64) (Event, error) {
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE tripId = ?
+		AND messageCode NOT IN (3, 14, 15, 16, 66, 67)
+		AND messageCode NOT BETWEEN 24 AND 63
+		AND (latitude != 0.0 OR longitude != 0.0)
+		ORDER BY timeStamp
+		LIMIT 1
+	`
+	events, err := r.query(query, tripID)
+	if err != nil {
+		return Event{}, err
+	}
+	if len(events) == 0 {
+		return Event{}, sql.ErrNoRows
+	}
+	return events[0], nil
+}
+
+// Last returns the latest event of a trip with a position, sql.ErrNoRows if there is none
+func (r *EventRepository) Last(tripID int64) (Event, error) {
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE tripId = ?
+		AND messageCode NOT IN (3, 14, 15, 16, 66, 67)
+		AND messageCode NOT BETWEEN 24 AND 63
+		AND (latitude != 0.0 OR longitude != 0.0)
+		ORDER BY timeStamp DESC
+		LIMIT 1
+	`
+	events, err := r.query(query, tripID)
 	if err != nil {
-		log.Fatal(err)
 		return Event{}, err
 	}
-	return e, nil
+	if len(events) == 0 {
+		return Event{}, sql.ErrNoRows
+	}
+	return events[0], nil
 }
 
-func (r *EventRepository) AllByDay(day string) ([]Event, error) {
-	query := "SELECT id, latitude, longitude, altitude, timeStamp FROM events WHERE DATE(timeStamp, 'unixepoch') = ?"
-	rows, err := r.db.Query(query, day)
+// LastFromSource returns the latest event of a trip recorded by a source, with
+// or without a position, sql.ErrNoRows if there is none
+func (r *EventRepository) LastFromSource(tripID int64, source string) (Event, error) {
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE tripId = ? AND source = ?
+		ORDER BY timeStamp DESC
+		LIMIT 1
+	`
+	events, err := r.query(query, tripID, source)
+	if err != nil {
+		return Event{}, err
+	}
+	if len(events) == 0 {
+		return Event{}, sql.ErrNoRows
+	}
+	return events[0], nil
+}
+
+// AllByDay returns the track of a trip on a calendar day in loc, the events
+// with all columns that have a position and are not messages like Track, ordered
+// by time
+func (r *EventRepository) AllByDay(tripID int64, day string, loc *time.Location) ([]Event, error) {
+	date, err := time.ParseInLocation("2006-01-02", day, loc)
 	if err != nil {
-		log.Printf("Error querying events: %v", err)
+		return nil, err
+	}
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE tripId = ? AND timeStamp >= ? AND timeStamp < ?
+		AND messageCode NOT IN (3, 14, 15, 16, 66, 67)
+		AND messageCode NOT BETWEEN 24 AND 63
+		AND (latitude != 0.0 OR longitude != 0.0)
+		ORDER BY timeStamp
+	`
+	return r.query(query, tripID, date.Unix(), date.AddDate(0, 0, 1).Unix())
+}
+
+func (r *EventRepository) Today(tripID int64, loc *time.Location) ([]Event, error) {
+	today := time.Now().In(loc).Format("2006-01-02")
+	slog.Debug("Getting records from today", "day", today)
+	return r.AllByDay(tripID, today, loc)
+}
+
+// AllInRange returns every event of a trip with all columns between from and to
+// (unix seconds, inclusive). A zero bound leaves that side of the range open.
+func (r *EventRepository) AllInRange(tripID, from, to int64) ([]Event, error) {
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE tripId = ?
+		AND (? = 0 OR timeStamp >= ?)
+		AND (? = 0 OR timeStamp <= ?)
+		ORDER BY timeStamp
+	`
+	return r.query(query, tripID, from, from, to, to)
+}
+
+// Page returns up to limit events of a trip between from and to like AllInRange,
+// continuing after the event afterID at afterTime. An afterID of 0 starts at the beginning.
+func (r *EventRepository) Page(tripID, from, to, afterTime, afterID int64, limit int) ([]Event, error) {
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE tripId = ?
+		AND (? = 0 OR timeStamp >= ?)
+		AND (? = 0 OR timeStamp <= ?)
+		AND (? = 0 OR timeStamp > ? OR (timeStamp = ? AND id > ?))
+		ORDER BY timeStamp, id
+		LIMIT ?
+	`
+	return r.query(query, tripID, from, from, to, to, afterID, afterTime, afterTime, afterID, limit)
+}
+
+// Columns scanned by query, NULLs are read as zero values
+const eventColumns = `id, tripId, imei, messageCode, COALESCE(freeText, ''), timeStamp,
+			COALESCE(latitude, 0), COALESCE(longitude, 0), COALESCE(altitude, 0),
+			COALESCE(gpsFix, 0), COALESCE(course, 0), COALESCE(speed, 0),
+			COALESCE(autonomous, 0), COALESCE(lowBattery, 0), COALESCE(intervalChange, 0), COALESCE(resetDetected, 0),
+			source, correctedAltitude`
+
+func (r *EventRepository) query(query string, args ...any) ([]Event, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		slog.Error("Error querying events", "error", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -137,21 +298,48 @@ func (r *EventRepository) AllByDay(day string) ([]Event, error) {
 	for rows.Next() {
 		var e Event
 
-		err := rows.Scan(&e.ID, &e.Latitude, &e.Longitude, &e.Altitude, &e.TimeStamp)
+		err := rows.Scan(&e.ID, &e.TripID, &e.Imei, &e.MessageCode, &e.FreeText, &e.TimeStamp,
+			&e.Latitude, &e.Longitude, &e.Altitude, &e.GpsFix, &e.Course, &e.Speed,
+			&e.Status.Autonomous, &e.Status.LowBattery, &e.Status.IntervalChange, &e.Status.ResetDetected,
+			&e.Source, &e.CorrectedAltitude)
 		if err != nil {
-			log.Printf("Error scanning event row: %v", err)
+			slog.Error("Error scanning event row", "error", err)
+			return nil, err
 		}
 		events = append(events, e)
 	}
 	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating event rows: %v", err)
+		slog.Error("Error iterating event rows", "error", err)
+		return nil, err
 	}
 
 	return events, nil
 }
 
-func (r *EventRepository) Today() ([]Event, error) {
-	today := time.Now().Format("2006-01-02")
-	log.Printf("getting records from today: %v", today)
-	return r.AllByDay(today)
+// UpdateCorrectedAltitudes stores DEM altitudes by event ID in a single transaction
+func (r *EventRepository) UpdateCorrectedAltitudes(altitudes map[int64]float64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for id, altitude := range altitudes {
+		if _, err := tx.Exec("UPDATE events SET correctedAltitude = ? WHERE id = ?", altitude, id); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	slog.Debug("Saving corrected altitudes to database", "count", len(altitudes))
+	return tx.Commit()
+}
+
+// Version changes whenever events are added, it is cheap enough to check on every request
+func (r *EventRepository) Version() (string, error) {
+	var count, lastID int64
+	err := r.db.QueryRow("SELECT COUNT(*), COALESCE(MAX(id), 0) FROM events").Scan(&count, &lastID)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-%d", count, lastID), nil
 }
@@ -2,7 +2,9 @@ package repository
 
 import (
 	"database/sql"
-	"log"
+	"errors"
+	"log/slog"
+	"time"
 )
 
 type Kudos struct {
@@ -21,29 +23,43 @@ func NewKudosRepository(db *sql.DB) *KudosRepository {
 func (r *KudosRepository) Increment(day string) error {
 	tx, err := r.db.Begin()
 	if err != nil {
-		log.Fatal("Couldn't begin save transaction for Kudos")
+		slog.Error("Couldn't begin save transaction for Kudos", "error", err)
 		return err
 	}
-	stmt, err := tx.Prepare("INSERT INTO kudos(day, count) VALUES (?, 1) ON CONFLICT(day) DO UPDATE SET count = count + 1")
+	stmt, err := tx.Prepare("INSERT INTO kudos(day, count, updatedAt) VALUES (?, 1, ?) ON CONFLICT(day) DO UPDATE SET count = count + 1, updatedAt = excluded.updatedAt")
 	if err != nil {
-		log.Print("stmt", err)
+		slog.Error("Error preparing statement", "error", err)
 		return err
 	}
 
-	_, err = stmt.Exec(day)
+	_, err = stmt.Exec(day, time.Now().Unix())
 	if err != nil {
-		log.Print("exec", err)
+		slog.Error("Error incrementing kudos", "day", day, "error", err)
 		return err
 	}
 
-	log.Printf("Saving new kudos to database")
+	slog.Debug("Saving new kudos to database")
 	return tx.Commit()
 }
 
+// Count returns the kudos of a day, 0 if it has none
+func (r *KudosRepository) Count(day string) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT count FROM kudos WHERE day = ?`, day).Scan(&count)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		slog.Error("Error querying kudos", "day", day, "error", err)
+		return 0, err
+	}
+	return count, nil
+}
+
 func (r *KudosRepository) All() ([]Kudos, error) {
 	rows, err := r.db.Query(`SELECT day, count FROM kudos ORDER BY day DESC`)
 	if err != nil {
-		log.Printf("Error querying kudos: %v", err)
+		slog.Error("Error querying kudos", "error", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -54,12 +70,12 @@ func (r *KudosRepository) All() ([]Kudos, error) {
 
 		err := rows.Scan(&k.Day, &k.Count)
 		if err != nil {
-			log.Printf("Error scanning kudos row: %v", err)
+			slog.Error("Error scanning kudos row", "error", err)
 		}
 		kudos = append(kudos, k)
 	}
 	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating kudos rows: %v", err)
+		slog.Error("Error iterating kudos rows", "error", err)
 	}
 
 	return kudos, nil
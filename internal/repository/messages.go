@@ -2,7 +2,7 @@ package repository
 
 import (
 	"database/sql"
-	"log"
+	"log/slog"
 )
 
 type Message struct {
@@ -26,7 +26,7 @@ func NewMessageRepository(db *sql.DB) *MessageRepository {
 func (r *MessageRepository) Create(m Message) error {
 	tx, err := r.db.Begin()
 	if err != nil {
-		log.Fatal("Couldn't begin save transaction for Message")
+		slog.Error("Couldn't begin save transaction for Message", "error", err)
 		return err
 	}
 	stmt, err := tx.Prepare("INSERT INTO messages(tripId, message, name, timeStamp, sentToGarmin, fromGarmin) VALUES(?,?,?,?,?,?)")
@@ -39,14 +39,14 @@ func (r *MessageRepository) Create(m Message) error {
 		return err
 	}
 
-	log.Printf("Saving new message to database")
+	slog.Debug("Saving new message to database")
 	return tx.Commit()
 }
 
 func (r *MessageRepository) All() ([]Message, error) {
 	rows, err := r.db.Query(`SELECT id, tripId, message, name, timeStamp, sentToGarmin, fromGarmin FROM messages ORDER BY timeStamp DESC`)
 	if err != nil {
-		log.Printf("Error querying messages: %v", err)
+		slog.Error("Error querying messages", "error", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -57,12 +57,41 @@ func (r *MessageRepository) All() ([]Message, error) {
 
 		err := rows.Scan(&m.ID, &m.TripID, &m.Message, &m.Name, &m.TimeStamp, &m.SentToGarmin, &m.FromGarmin)
 		if err != nil {
-			log.Printf("Error scanning message row: %v", err)
+			slog.Error("Error scanning message row", "error", err)
 		}
 		messages = append(messages, m)
 	}
 	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating message rows: %v", err)
+		slog.Error("Error iterating message rows", "error", err)
+	}
+
+	return messages, nil
+}
+
+// AllByTrip returns the messages of a trip, newest first
+func (r *MessageRepository) AllByTrip(tripID int64) ([]Message, error) {
+	rows, err := r.db.Query(`
+		SELECT id, tripId, COALESCE(message, ''), COALESCE(name, ''), timeStamp, COALESCE(sentToGarmin, 0), COALESCE(fromGarmin, 0)
+		FROM messages WHERE tripId = ? ORDER BY timeStamp DESC
+	`, tripID)
+	if err != nil {
+		slog.Error("Error querying messages", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		var m Message
+		if err := rows.Scan(&m.ID, &m.TripID, &m.Message, &m.Name, &m.TimeStamp, &m.SentToGarmin, &m.FromGarmin); err != nil {
+			slog.Error("Error scanning message row", "error", err)
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("Error iterating message rows", "error", err)
+		return nil, err
 	}
 
 	return messages, nil
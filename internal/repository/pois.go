@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"database/sql"
+	"log/slog"
+)
+
+// Kinds of points of interest
+const (
+	POIResupply = "resupply"
+	POIWater    = "water"
+	POILodging  = "lodging"
+	POIOther    = "other"
+)
+
+// POI is a point of interest along the planned route of a trip
+type POI struct {
+	ID          int64
+	TripID      int64
+	Name        string
+	Kind        string
+	Description string
+	Latitude    float64
+	Longitude   float64
+	// Meters from the start of the planned route
+	RouteDistance float64
+}
+
+type POIRepository struct {
+	db *sql.DB
+}
+
+func NewPOIRepository(db *sql.DB) *POIRepository {
+	return &POIRepository{db: db}
+}
+
+// ReplaceForTrip swaps all points of interest of a trip in one transaction
+func (r *POIRepository) ReplaceForTrip(tripID int64, pois []POI) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		slog.Error("Error starting transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM pois WHERE tripId = ?`, tripID); err != nil {
+		slog.Error("Error deleting pois", "error", err)
+		return err
+	}
+	stmt, err := tx.Prepare(`INSERT INTO pois(tripId, name, kind, description, latitude, longitude, routeDistance) VALUES(?,?,?,?,?,?,?)`)
+	if err != nil {
+		slog.Error("Error preparing statement", "error", err)
+		return err
+	}
+	defer stmt.Close()
+
+	for _, poi := range pois {
+		if _, err := stmt.Exec(tripID, poi.Name, poi.Kind, poi.Description, poi.Latitude, poi.Longitude, poi.RouteDistance); err != nil {
+			slog.Error("Error inserting poi", "poi", poi.Name, "error", err)
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// AllByTrip returns the points of interest of a trip in route order
+func (r *POIRepository) AllByTrip(tripID int64) ([]POI, error) {
+	rows, err := r.db.Query(`
+		SELECT id, tripId, name, kind, description, latitude, longitude, routeDistance
+		FROM pois WHERE tripId = ? ORDER BY routeDistance ASC
+	`, tripID)
+	if err != nil {
+		slog.Error("Error querying pois", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pois []POI
+	for rows.Next() {
+		var poi POI
+		if err := rows.Scan(&poi.ID, &poi.TripID, &poi.Name, &poi.Kind, &poi.Description, &poi.Latitude, &poi.Longitude, &poi.RouteDistance); err != nil {
+			slog.Error("Error scanning poi row", "error", err)
+			return nil, err
+		}
+		pois = append(pois, poi)
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("Error iterating poi rows", "error", err)
+		return nil, err
+	}
+
+	return pois, nil
+}
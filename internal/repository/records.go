@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"database/sql"
+	"log/slog"
+)
+
+// Record is the best value of a trip in one category, e.g. the longest day
+type Record struct {
+	TripID int64
+	Name   string
+	Value  float64
+	// Day the record was set on as yyyy-mm-dd
+	Day string
+	// When the record was set
+	TimeStamp int64
+}
+
+type RecordRepository struct {
+	db *sql.DB
+}
+
+func NewRecordRepository(db *sql.DB) *RecordRepository {
+	return &RecordRepository{db: db}
+}
+
+// Save inserts a record or replaces the one of the same trip and name
+func (r *RecordRepository) Save(record Record) error {
+	_, err := r.db.Exec(`
+		INSERT INTO records(tripId, name, value, day, timeStamp) VALUES(?,?,?,?,?)
+		ON CONFLICT(tripId, name) DO UPDATE SET value = excluded.value, day = excluded.day, timeStamp = excluded.timeStamp
+	`, record.TripID, record.Name, record.Value, record.Day, record.TimeStamp)
+	if err != nil {
+		slog.Error("Error saving record", "record", record.Name, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (r *RecordRepository) AllByTrip(tripID int64) ([]Record, error) {
+	rows, err := r.db.Query(`SELECT tripId, name, value, day, timeStamp FROM records WHERE tripId = ?`, tripID)
+	if err != nil {
+		slog.Error("Error querying records", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var record Record
+		if err := rows.Scan(&record.TripID, &record.Name, &record.Value, &record.Day, &record.TimeStamp); err != nil {
+			slog.Error("Error scanning record row", "error", err)
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("Error iterating record rows", "error", err)
+		return nil, err
+	}
+
+	return records, nil
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"database/sql"
+	"log/slog"
+)
+
+// Trips with this timezone use the zone of the rider's first position
+const TimezoneAuto = "auto"
+
+type Trip struct {
+	ID          int64
+	Description string
+	// IANA zone name like America/Denver or TimezoneAuto
+	Timezone string
+	// Default unit system of visitors, metric or imperial
+	Units string
+}
+
+// Changes summarizes the data the pages of a trip are built from. It differs as
+// soon as events, messages, kudos or points of interest are added or removed.
+type Changes struct {
+	Events        int64
+	LastEventID   int64
+	Messages      int64
+	LastMessageID int64
+	Kudos         int64
+	LastPOIID     int64
+	// Unix seconds of the latest event, message or kudos
+	Modified int64
+}
+
+type TripRepository struct {
+	db *sql.DB
+}
+
+func NewTripRepository(db *sql.DB) *TripRepository {
+	return &TripRepository{db: db}
+}
+
+// Get returns sql.ErrNoRows for unknown trips
+func (r *TripRepository) Get(id int64) (Trip, error) {
+	var t Trip
+	err := r.db.QueryRow(`SELECT id, COALESCE(description, ''), timezone, units FROM trips WHERE id = ?`, id).
+		Scan(&t.ID, &t.Description, &t.Timezone, &t.Units)
+	if err != nil {
+		return Trip{}, err
+	}
+	return t, nil
+}
+
+// Active returns the trips that have started and not ended at now, in unix
+// seconds. Trips without a start time are never active, trips without an end
+// time stay active. The times may be stored as unix seconds or as dates.
+func (r *TripRepository) Active(now int64) ([]Trip, error) {
+	rows, err := r.db.Query(`
+		SELECT id, COALESCE(description, ''), timezone, units
+		FROM trips
+		WHERE COALESCE(startTime, '') != '' AND unixepoch(startTime, 'auto') <= ?1
+		AND (COALESCE(endTime, '') = '' OR unixepoch(endTime, 'auto') > ?1)
+		ORDER BY id
+	`, now)
+	if err != nil {
+		slog.Error("Error querying active trips", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trips []Trip
+	for rows.Next() {
+		var t Trip
+		if err := rows.Scan(&t.ID, &t.Description, &t.Timezone, &t.Units); err != nil {
+			slog.Error("Error scanning trip row", "error", err)
+			return nil, err
+		}
+		trips = append(trips, t)
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("Error iterating trip rows", "error", err)
+		return nil, err
+	}
+
+	return trips, nil
+}
+
+// Changes reads the summary of a trip's data. Kudos are not stored per trip and
+// count for every trip.
+func (r *TripRepository) Changes(id int64) (Changes, error) {
+	var c Changes
+	var eventsModified, messagesModified, kudosModified int64
+	err := r.db.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM events WHERE tripId = ?1),
+			(SELECT COALESCE(MAX(id), 0) FROM events WHERE tripId = ?1),
+			(SELECT COALESCE(MAX(timeStamp), 0) FROM events WHERE tripId = ?1),
+			(SELECT COUNT(*) FROM messages WHERE tripId = ?1),
+			(SELECT COALESCE(MAX(id), 0) FROM messages WHERE tripId = ?1),
+			(SELECT COALESCE(MAX(timeStamp), 0) FROM messages WHERE tripId = ?1),
+			(SELECT COALESCE(SUM(count), 0) FROM kudos),
+			(SELECT COALESCE(MAX(updatedAt), 0) FROM kudos),
+			(SELECT COALESCE(MAX(id), 0) FROM pois WHERE tripId = ?1)
+	`, id).Scan(&c.Events, &c.LastEventID, &eventsModified, &c.Messages, &c.LastMessageID, &messagesModified, &c.Kudos, &kudosModified, &c.LastPOIID)
+	if err != nil {
+		slog.Error("Error querying changes of trip", "trip", id, "error", err)
+		return Changes{}, err
+	}
+	c.Modified = max(eventsModified, messagesModified, kudosModified)
+	return c, nil
+}
+
+func (r *TripRepository) All() ([]Trip, error) {
+	rows, err := r.db.Query(`SELECT id, COALESCE(description, ''), timezone, units FROM trips ORDER BY id`)
+	if err != nil {
+		slog.Error("Error querying trips", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trips []Trip
+	for rows.Next() {
+		var t Trip
+		if err := rows.Scan(&t.ID, &t.Description, &t.Timezone, &t.Units); err != nil {
+			slog.Error("Error scanning trip row", "error", err)
+			return nil, err
+		}
+		trips = append(trips, t)
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("Error iterating trip rows", "error", err)
+		return nil, err
+	}
+
+	return trips, nil
+}
+
+// UpdateTimezone creates the trip if it does not exist yet
+func (r *TripRepository) UpdateTimezone(id int64, timezone string) error {
+	_, err := r.db.Exec(`
+		INSERT INTO trips(id, timezone) VALUES(?, ?)
+		ON CONFLICT(id) DO UPDATE SET timezone = excluded.timezone
+	`, id, timezone)
+	if err != nil {
+		slog.Error("Error updating timezone of trip", "trip", id, "error", err)
+		return err
+	}
+	return nil
+}
+
+// UpdateTimes sets when a trip starts and ends in unix seconds, a zero end
+// leaves it open. It creates the trip if it does not exist yet.
+func (r *TripRepository) UpdateTimes(id, start, end int64) error {
+	var endTime any
+	if end != 0 {
+		endTime = end
+	}
+	_, err := r.db.Exec(`
+		INSERT INTO trips(id, startTime, endTime) VALUES(?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET startTime = excluded.startTime, endTime = excluded.endTime
+	`, id, start, endTime)
+	if err != nil {
+		slog.Error("Error updating times of trip", "trip", id, "error", err)
+		return err
+	}
+	return nil
+}
+
+// UpdateUnits creates the trip if it does not exist yet
+func (r *TripRepository) UpdateUnits(id int64, units string) error {
+	_, err := r.db.Exec(`
+		INSERT INTO trips(id, units) VALUES(?, ?)
+		ON CONFLICT(id) DO UPDATE SET units = excluded.units
+	`, id, units)
+	if err != nil {
+		slog.Error("Error updating units of trip", "trip", id, "error", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"database/sql"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// States of a webhook delivery
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is an endpoint that trip events are posted to
+type Webhook struct {
+	ID     int64
+	URL    string
+	Secret string
+	// Types of the events posted to the endpoint
+	Events    []string
+	CreatedAt int64
+}
+
+// Subscribes tells whether events of a type are posted to the webhook
+func (w Webhook) Subscribes(event string) bool {
+	return slices.Contains(w.Events, event)
+}
+
+// WebhookDelivery is an event queued for or posted to a webhook
+type WebhookDelivery struct {
+	ID        int64
+	WebhookID int64
+	Event     string
+	// JSON body of the request
+	Payload  string
+	Status   string
+	Attempts int
+	// Unix seconds, the pending delivery is not sent before
+	NextAttemptAt int64
+	LastAttemptAt int64
+	// Answer and error of the last attempt
+	ResponseStatus int
+	Error          string
+	CreatedAt      int64
+	// URL and Secret of the webhook, only set on due deliveries
+	URL    string
+	Secret string
+}
+
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+const deliveryColumns = `d.id, d.webhookId, d.event, d.payload, d.status, d.attempts, d.nextAttemptAt, d.lastAttemptAt, d.responseStatus, d.error, d.createdAt`
+
+func (r *WebhookRepository) Create(w Webhook) (int64, error) {
+	result, err := r.db.Exec(`INSERT INTO webhooks(url, secret, events, createdAt) VALUES(?,?,?,?)`,
+		w.URL, w.Secret, strings.Join(w.Events, ","), w.CreatedAt)
+	if err != nil {
+		slog.Error("Error inserting webhook", "error", err)
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// All returns the webhooks in the order they were registered
+func (r *WebhookRepository) All() ([]Webhook, error) {
+	rows, err := r.db.Query(`SELECT id, url, secret, events, createdAt FROM webhooks ORDER BY id`)
+	if err != nil {
+		slog.Error("Error querying webhooks", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []Webhook
+	for rows.Next() {
+		var w Webhook
+		var events string
+		if err := rows.Scan(&w.ID, &w.URL, &w.Secret, &events, &w.CreatedAt); err != nil {
+			slog.Error("Error scanning webhook row", "error", err)
+			return nil, err
+		}
+		w.Events = strings.Split(events, ",")
+		webhooks = append(webhooks, w)
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("Error iterating webhook rows", "error", err)
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// Get returns sql.ErrNoRows when there is no webhook with the id
+func (r *WebhookRepository) Get(id int64) (Webhook, error) {
+	var w Webhook
+	var events string
+	err := r.db.QueryRow(`SELECT id, url, secret, events, createdAt FROM webhooks WHERE id = ?`, id).
+		Scan(&w.ID, &w.URL, &w.Secret, &events, &w.CreatedAt)
+	if err != nil {
+		return Webhook{}, err
+	}
+	w.Events = strings.Split(events, ",")
+	return w, nil
+}
+
+// Delete removes a webhook with its queue and delivery log, sql.ErrNoRows when
+// there is no webhook with the id
+func (r *WebhookRepository) Delete(id int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		slog.Error("Error starting transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM webhook_deliveries WHERE webhookId = ?`, id); err != nil {
+		slog.Error("Error deleting webhook deliveries", "webhook", id, "error", err)
+		return err
+	}
+	result, err := tx.Exec(`DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		slog.Error("Error deleting webhook", "webhook", id, "error", err)
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// Enqueue queues an event for each of the webhooks, due right away
+func (r *WebhookRepository) Enqueue(webhookIDs []int64, event, payload string, now int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		slog.Error("Error starting transaction", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO webhook_deliveries(webhookId, event, payload, status, nextAttemptAt, createdAt) VALUES(?,?,?,?,?,?)`)
+	if err != nil {
+		slog.Error("Error preparing statement", "error", err)
+		return err
+	}
+	defer stmt.Close()
+
+	for _, id := range webhookIDs {
+		if _, err := stmt.Exec(id, event, payload, DeliveryPending, now, now); err != nil {
+			slog.Error("Error queueing webhook delivery", "webhook", id, "error", err)
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Due returns the pending deliveries of a webhook whose next attempt is due at
+// now, oldest first, with the URL and secret of the webhook
+func (r *WebhookRepository) Due(webhookID, now int64, limit int) ([]WebhookDelivery, error) {
+	rows, err := r.db.Query(`
+		SELECT `+deliveryColumns+`, w.url, w.secret
+		FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhookId
+		WHERE d.webhookId = ? AND d.status = ? AND d.nextAttemptAt <= ?
+		ORDER BY d.nextAttemptAt, d.id
+		LIMIT ?
+	`, webhookID, DeliveryPending, now, limit)
+	if err != nil {
+		slog.Error("Error querying due webhook deliveries", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastAttemptAt, &d.ResponseStatus, &d.Error, &d.CreatedAt, &d.URL, &d.Secret); err != nil {
+			slog.Error("Error scanning webhook delivery row", "error", err)
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("Error iterating webhook delivery rows", "error", err)
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// UpdateDelivery stores the outcome of an attempt
+func (r *WebhookRepository) UpdateDelivery(d WebhookDelivery) error {
+	_, err := r.db.Exec(`
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, nextAttemptAt = ?, lastAttemptAt = ?, responseStatus = ?, error = ?
+		WHERE id = ?
+	`, d.Status, d.Attempts, d.NextAttemptAt, d.LastAttemptAt, d.ResponseStatus, d.Error, d.ID)
+	if err != nil {
+		slog.Error("Error updating webhook delivery", "delivery", d.ID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// Deliveries returns the latest deliveries of a webhook, newest first
+func (r *WebhookRepository) Deliveries(webhookID int64, limit int) ([]WebhookDelivery, error) {
+	rows, err := r.db.Query(`
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries d
+		WHERE d.webhookId = ?
+		ORDER BY d.id DESC
+		LIMIT ?
+	`, webhookID, limit)
+	if err != nil {
+		slog.Error("Error querying webhook deliveries", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastAttemptAt, &d.ResponseStatus, &d.Error, &d.CreatedAt); err != nil {
+			slog.Error("Error scanning webhook delivery row", "error", err)
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("Error iterating webhook delivery rows", "error", err)
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Prune drops delivered and failed deliveries created before the unix time
+func (r *WebhookRepository) Prune(before int64) error {
+	_, err := r.db.Exec(`DELETE FROM webhook_deliveries WHERE status != ? AND createdAt < ?`, DeliveryPending, before)
+	if err != nil {
+		slog.Error("Error pruning webhook deliveries", "error", err)
+		return err
+	}
+	return nil
+}
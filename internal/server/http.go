@@ -2,9 +2,16 @@ package server
 
 import (
 	"context"
-	"log"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/getsentry/sentry-go"
@@ -12,24 +19,66 @@ import (
 	"github.com/janschill/track-me/internal/config"
 	"github.com/janschill/track-me/internal/db"
 	"github.com/janschill/track-me/internal/handlers"
+	"github.com/janschill/track-me/internal/logging"
+	"github.com/janschill/track-me/internal/middleware"
 	"github.com/janschill/track-me/internal/repository"
 	"github.com/janschill/track-me/internal/service"
 	garmin "github.com/janschill/track-me/pkg/garmin"
+	"github.com/janschill/track-me/pkg/httpcache"
 	icloud "github.com/janschill/track-me/pkg/icloud"
+	"github.com/janschill/track-me/pkg/metrics"
+	"github.com/janschill/track-me/pkg/notify"
+	"github.com/janschill/track-me/web"
 )
 
 var conf *config.Config
 
-func newHTTPHandler(repo *repository.Repository, dayService *service.DayService, garminService *service.GarminService, garminClient *garmin.Client) http.Handler {
+// Raised or lowered by LOG_LEVEL once the configuration is loaded
+var logLevel slog.LevelVar
+
+// fatal logs an error the server cannot start without and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+func newHTTPHandler(repo *repository.Repository, dayService *service.DayService, recordService *service.RecordService, tripService *service.TripService, placeService *service.PlaceService, routeService *service.RouteService, liveService *service.LiveService, socketHandler *handlers.SocketHandler, healthHandler *handlers.HealthHandler, templates *handlers.Templates, staticHandler *handlers.StaticHandler, garminService *service.GarminService, webhookService *service.WebhookService, garminClient *garmin.Client) http.Handler {
 	mux := http.NewServeMux()
 	sentryHandler := sentryhttp.New(sentryhttp.Options{})
 
-	fs := http.FileServer(http.Dir("web/assets/"))
-	mux.Handle("/static/", http.StripPrefix("/static/", fs))
+	mux.Handle("/static/", http.HandlerFunc(staticHandler.GetStatic))
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/healthz", http.HandlerFunc(healthHandler.GetHealth))
+	mux.Handle("/readyz", http.HandlerFunc(healthHandler.GetReady))
+	mux.Handle("/status", sentryHandler.Handle(http.HandlerFunc(healthHandler.GetStatus)))
 
-	mux.Handle("/", sentryHandler.Handle(http.HandlerFunc(handlers.NewIndexHandler(repo, dayService).GetIndex)))
-	mux.Handle("/messages", sentryHandler.Handle(http.HandlerFunc(handlers.NewMessageHandler(repo, garminClient).CreateMessage)))
-	mux.Handle("/kudos", sentryHandler.Handle(http.HandlerFunc(handlers.NewKudosHandler(repo).CreateKudos)))
+	mux.Handle("/", sentryHandler.Handle(http.HandlerFunc(handlers.NewIndexHandler(repo, dayService, recordService, tripService, placeService, routeService, templates, staticHandler).GetIndex)))
+	mux.Handle("/track", sentryHandler.Handle(http.HandlerFunc(handlers.NewTrackHandler(repo).GetTrack)))
+	profileHandler := handlers.NewProfileHandler(repo, dayService, tripService)
+	mux.Handle("/profile", sentryHandler.Handle(http.HandlerFunc(profileHandler.GetProfile)))
+	mux.Handle("/profile/summary", sentryHandler.Handle(http.HandlerFunc(profileHandler.GetProfileSummary)))
+	mux.Handle("/pois", sentryHandler.Handle(http.HandlerFunc(handlers.NewPOIHandler(repo, dayService, tripService, routeService).GetPOIs)))
+	apiHandler := handlers.NewAPIHandler(repo, dayService, tripService)
+	mux.Handle("/api/v1/", sentryHandler.Handle(http.HandlerFunc(apiHandler.NotFound)))
+	mux.Handle("/api/v1/openapi.json", sentryHandler.Handle(http.HandlerFunc(apiHandler.GetOpenAPI)))
+	mux.Handle("/api/v1/trips", sentryHandler.Handle(http.HandlerFunc(apiHandler.GetTrips)))
+	mux.Handle("/api/v1/trips/{id}", sentryHandler.Handle(http.HandlerFunc(apiHandler.GetTrip)))
+	mux.Handle("/api/v1/trips/{id}/ride", sentryHandler.Handle(http.HandlerFunc(apiHandler.GetRide)))
+	mux.Handle("/api/v1/trips/{id}/days", sentryHandler.Handle(http.HandlerFunc(apiHandler.GetDays)))
+	mux.Handle("/api/v1/trips/{id}/events", sentryHandler.Handle(http.HandlerFunc(apiHandler.GetEvents)))
+	mux.Handle("/api/v1/trips/{id}/messages", sentryHandler.Handle(http.HandlerFunc(apiHandler.GetMessages)))
+	mux.Handle("/api/v1/trips/{id}/kudos", sentryHandler.Handle(http.HandlerFunc(apiHandler.GetKudos)))
+	mux.Handle("/api/v1/trips/{id}/position", sentryHandler.Handle(http.HandlerFunc(apiHandler.GetPosition)))
+	mux.Handle("/live", sentryHandler.Handle(http.HandlerFunc(handlers.NewLiveHandler(liveService).GetLive)))
+	mux.Handle("/ws", sentryHandler.Handle(http.HandlerFunc(socketHandler.GetSocket)))
+	mux.Handle("/messages", sentryHandler.Handle(http.HandlerFunc(handlers.NewMessageHandler(repo, garminClient, liveService, webhookService).CreateMessage)))
+	mux.Handle("/kudos", sentryHandler.Handle(http.HandlerFunc(handlers.NewKudosHandler(repo, liveService, webhookService).CreateKudos)))
+	mux.Handle("/export", sentryHandler.Handle(middleware.Authorize(handlers.NewExportHandler(repo, tripService).GetExport)))
+	mux.Handle("/import", sentryHandler.Handle(middleware.Authorize(handlers.NewImportHandler(repo, dayService).CreateImport)))
+	webhookHandler := handlers.NewWebhookHandler(repo, webhookService)
+	mux.Handle("/webhooks", sentryHandler.Handle(middleware.Authorize(webhookHandler.Webhooks)))
+	mux.Handle("/webhooks/{id}", sentryHandler.Handle(middleware.Authorize(webhookHandler.Webhook)))
+	mux.Handle("/webhooks/{id}/deliveries", sentryHandler.Handle(middleware.Authorize(webhookHandler.GetDeliveries)))
 	mux.Handle("/garmin-outbound", sentryHandler.Handle(http.HandlerFunc(garmin.NewOutboundHandler(garminService.ProcessPayload).CreateOutboundEvent)))
 	iCloudConf := icloud.Config{
 		Token: conf.ICloudAlbumToken,
@@ -39,35 +88,94 @@ func newHTTPHandler(repo *repository.Repository, dayService *service.DayService,
 		panic("Test error for Sentry")
 	})))
 
-	return mux
+	return chain(mux,
+		Tracing(newRequestID),
+		Logging(slog.Default()),
+		Metrics,
+		httpcache.Compress,
+	)
 }
 
 func init() {
+	slog.SetDefault(logging.New(os.Stdout, &logLevel))
+
 	var err error
 	conf, err = config.LoadConfig()
 	if err != nil {
-		log.Fatalf("Couldnt load config %v", err)
+		fatal("Couldnt load config", "error", err)
 	}
+	logLevel.Set(conf.LogLevel)
 
 	if err := sentry.Init(sentry.ClientOptions{
 		Dsn:              conf.SentryDsn,
 		TracesSampleRate: 1.0,
 	}); err != nil {
-		log.Fatalf("Sentry initialization failed: %v\n", err)
+		fatal("Sentry initialization failed", "error", err)
 	}
 }
 
-func HttpServer(addr string, ctx context.Context) *http.Server {
-	if conf.DatabaseURL == "" {
-		log.Fatal("DB_PATH environment variable is not set")
+// Server closes the WebSocket connections, which http.Server does not track
+// after the upgrade, when shutting down
+type Server struct {
+	*http.Server
+	sockets *handlers.SocketHandler
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.Server.Shutdown(ctx)
+	if socketErr := s.sockets.Shutdown(ctx); err == nil {
+		err = socketErr
 	}
-	db, err := db.InitializeDB(conf.DatabaseURL)
-	if err != nil {
-		log.Fatal(err)
+	return err
+}
+
+// app is what the server runs on top of a database
+type app struct {
+	handler  http.Handler
+	sockets  *handlers.SocketHandler
+	webhooks *service.WebhookService
+	records  *service.RecordService
+	// Nil without a notifier to alert through
+	watchdog *service.WatchdogService
+}
+
+// alertNotifiers are the configured ways of sending watchdog alerts
+func alertNotifiers() []notify.Notifier {
+	var notifiers []notify.Notifier
+	if conf.AlertSMTPAddr != "" {
+		if conf.AlertSMTPFrom == "" || len(conf.AlertSMTPTo) == 0 {
+			slog.Warn("ALERT_SMTP_ADDR is set without ALERT_SMTP_FROM and ALERT_SMTP_TO, alerts are not mailed")
+		} else {
+			notifiers = append(notifiers, &notify.SMTP{
+				Addr:     conf.AlertSMTPAddr,
+				From:     conf.AlertSMTPFrom,
+				To:       conf.AlertSMTPTo,
+				Username: conf.AlertSMTPUsername,
+				Password: conf.AlertSMTPPassword,
+			})
+		}
+	}
+	if conf.AlertWebhookURL != "" {
+		notifiers = append(notifiers, &notify.Webhook{URL: conf.AlertWebhookURL})
 	}
-	repo := repository.NewRepository(db)
-	dayService := service.NewDayService()
-	garminService := service.NewGarminService(repo)
+	return notifiers
+}
+
+// newApp wires the services and handlers on top of a migrated database
+func newApp(database *sql.DB, dev bool) (*app, error) {
+	repo := repository.NewRepository(database)
+	registerTripMetrics(repo)
+	dayService := service.NewDayService(service.DayConfig{
+		ElevationThreshold: conf.ElevationThreshold,
+	})
+	elevationService := service.NewElevationService(repo, conf.DemPath)
+	tripService := service.NewTripService(repo)
+	placeService := service.NewPlaceService(conf.PlacesPath)
+	routeService := service.NewRouteService(repo, conf.RoutePath)
+	liveService := service.NewLiveService()
+	recordService := service.NewRecordService(repo, dayService, tripService, liveService)
+	webhookService := service.NewWebhookService(repo, dayService, tripService)
+	garminService := service.NewGarminService(repo, elevationService, recordService, liveService, webhookService)
 	garminClient := garmin.NewClient(garmin.Config{
 		Address:  conf.GarminIpcInbound,
 		Imei:     conf.GarminDeviceIMEI,
@@ -77,11 +185,115 @@ func HttpServer(addr string, ctx context.Context) *http.Server {
 		Interval: time.Hour,
 	})
 
-	return &http.Server{
-		Addr:         ":" + addr,
-		Handler:      newHTTPHandler(repo, dayService, garminService, garminClient),
-		BaseContext:  func(_ net.Listener) context.Context { return ctx },
-		ReadTimeout:  time.Second,
-		WriteTimeout: 10 * time.Second,
+	socketHandler := handlers.NewSocketHandler(repo, liveService, webhookService)
+	staticHandler, err := handlers.NewStaticHandler(web.FS(dev), dev)
+	if err != nil {
+		return nil, fmt.Errorf("loading assets: %w", err)
+	}
+	templates, err := handlers.NewTemplates(web.FS(dev), staticHandler, dev)
+	if err != nil {
+		return nil, fmt.Errorf("parsing templates: %w", err)
+	}
+	healthHandler := handlers.NewHealthHandler(database, repo, templates, garminService)
+
+	a := &app{
+		handler:  newHTTPHandler(repo, dayService, recordService, tripService, placeService, routeService, liveService, socketHandler, healthHandler, templates, staticHandler, garminService, webhookService, garminClient),
+		sockets:  socketHandler,
+		webhooks: webhookService,
+		records:  recordService,
+	}
+	if notifiers := alertNotifiers(); len(notifiers) > 0 {
+		a.watchdog = service.NewWatchdogService(repo, tripService, service.WatchdogConfig{
+			Thresholds: conf.WatchdogThresholds,
+			Interval:   conf.WatchdogInterval,
+		}, notifiers...)
+	}
+	return a, nil
+}
+
+// HttpServer serves the embedded templates and assets, or those below web/ in
+// dev mode
+func HttpServer(addr string, ctx context.Context, dev bool) *Server {
+	if conf.DatabaseURL == "" {
+		fatal("DB_PATH environment variable is not set")
+	}
+	database, err := db.InitializeDB(conf.DatabaseURL)
+	if err != nil {
+		fatal("Failed to open database", "error", err)
+	}
+	if err := db.Migrate(database); err != nil {
+		fatal("Failed to migrate database", "error", err)
+	}
+	a, err := newApp(database, dev)
+	if err != nil {
+		fatal("Failed to start", "error", err)
+	}
+	if a.watchdog != nil {
+		go a.watchdog.Run(ctx)
+	}
+	go a.webhooks.Run(ctx)
+	go a.records.Run(ctx)
+
+	return &Server{
+		Server: &http.Server{
+			Addr:         ":" + addr,
+			Handler:      a.handler,
+			BaseContext:  func(_ net.Listener) context.Context { return ctx },
+			ReadTimeout:  time.Second,
+			WriteTimeout: 10 * time.Second,
+			ErrorLog:     slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+		},
+		sockets: a.sockets,
+	}
+}
+
+// SelfTest checks a new binary before it replaces the running one. It migrates
+// a copy of the database, so the running server keeps its schema, and requests
+// the readiness check and the map page from it.
+func SelfTest() error {
+	if conf.DatabaseURL == "" {
+		return errors.New("DB_PATH environment variable is not set")
+	}
+	// Opening a missing file would create an empty database
+	if _, err := os.Stat(conf.DatabaseURL); err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp("", "trackme-ping")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	database, err := db.InitializeDB(conf.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	copyPath := filepath.Join(dir, "trackme.db")
+	_, err = database.Exec("VACUUM INTO ?", copyPath)
+	database.Close()
+	if err != nil {
+		return fmt.Errorf("copying database: %w", err)
+	}
+
+	database, err = db.InitializeDB(copyPath)
+	if err != nil {
+		return fmt.Errorf("opening database copy: %w", err)
+	}
+	defer database.Close()
+	if err := db.Migrate(database); err != nil {
+		return fmt.Errorf("migrating database copy: %w", err)
+	}
+	a, err := newApp(database, false)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range []string{"/readyz", "/", "/status"} {
+		w := httptest.NewRecorder()
+		a.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			return fmt.Errorf("GET %s answered %d: %s", path, w.Code, strings.TrimSpace(w.Body.String()))
+		}
 	}
+	return nil
 }
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/pkg/metrics"
+)
+
+var (
+	httpRequests = metrics.NewCounterVec(
+		"http_requests_total",
+		"HTTP requests by route, method and status.",
+		"route", "method", "status",
+	)
+	httpDuration = metrics.NewHistogramVec(
+		"http_request_duration_seconds",
+		"Time to answer HTTP requests by route, without live streams and WebSockets.",
+		metrics.DefaultBuckets,
+		"route",
+	)
+)
+
+// Metrics counts requests and times them per route. Routes are the mux
+// patterns, which keeps the number of series small whatever paths are requested.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := r.Pattern
+		if route == "" {
+			route = "none"
+		}
+		httpRequests.Inc(route, r.Method, strconv.Itoa(status))
+		// Streams last as long as the visitor stays
+		if status != http.StatusSwitchingProtocols && !strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+			httpDuration.Observe(time.Since(start).Seconds(), route)
+		}
+	})
+}
+
+// registerTripMetrics adds the gauges read from the database when scraped
+func registerTripMetrics(repo *repository.Repository) {
+	metrics.NewGaugeFunc(
+		"trackme_last_event_age_seconds",
+		"Seconds since the last position of the trip was recorded.",
+		func() float64 {
+			event, err := repo.Events.Last(1)
+			if err != nil {
+				return math.NaN()
+			}
+			return time.Since(time.Unix(event.TimeStamp, 0)).Seconds()
+		},
+	)
+}
@@ -1,41 +1,142 @@
 package server
 
 import (
-	"context"
-	"log"
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net"
 	"net/http"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/janschill/track-me/internal/logging"
 )
 
-type key int
+// Longer request IDs of clients are replaced
+const maxRequestIDLength = 64
 
-const (
-	requestIDKey key = 0
-)
+// chain wraps a handler in middleware, the first one runs first
+func chain(h http.Handler, middleware ...func(http.Handler) http.Handler) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
+
+// statusWriter captures the status and size of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += n
+	return n, err
+}
+
+// Hijack records the switch to a WebSocket, the response is written to the connection
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap lets http.ResponseController reach flushing and deadlines
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
 
-func Logging(logger *log.Logger) func(http.Handler) http.Handler {
+// Logging logs every request with its route, status and latency once it is
+// answered. Query strings and headers are left out, they may hold tokens.
+func Logging(logger *slog.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
 			defer func() {
-				requestID, ok := r.Context().Value(requestIDKey).(string)
-				if !ok {
-					requestID = "unknown"
+				status := sw.status
+				if status == 0 {
+					status = http.StatusOK
+				}
+				level := slog.LevelInfo
+				switch {
+				case status >= 500:
+					level = slog.LevelError
+				case status >= 400:
+					level = slog.LevelWarn
 				}
-				logger.Println(requestID, r.Method, r.URL.Path, r.RemoteAddr, r.UserAgent())
+				logger.LogAttrs(r.Context(), level, "Request",
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					// Set by the mux on the same request
+					slog.String("route", r.Pattern),
+					slog.Int("status", status),
+					slog.Int("bytes", sw.bytes),
+					slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+				)
 			}()
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(sw, r)
 		})
 	}
 }
+
+// Tracing gives every request an ID, taken from X-Request-Id when a proxy set
+// one. It is returned in the response, added to the log lines of the request and
+// tagged on the Sentry scope of its events.
 func Tracing(nextRequestID func() string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			requestID := r.Header.Get("X-Request-Id")
-			if requestID == "" {
+			if !validRequestID(requestID) {
 				requestID = nextRequestID()
 			}
-			ctx := context.WithValue(r.Context(), requestIDKey, requestID)
+			ctx := logging.WithRequestID(r.Context(), requestID)
+
+			// sentryhttp uses the hub of the context instead of cloning its own
+			hub := sentry.GetHubFromContext(ctx)
+			if hub == nil {
+				hub = sentry.CurrentHub().Clone()
+				ctx = sentry.SetHubOnContext(ctx, hub)
+			}
+			hub.Scope().SetTag("request_id", requestID)
+
 			w.Header().Set("X-Request-Id", requestID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// validRequestID accepts IDs that are safe to log and send back
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range requestID {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns 16 random hex characters
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -2,9 +2,10 @@ package service
 
 import (
 	"cmp"
-	"log"
+	"log/slog"
 	"math"
 	"slices"
+	"sync"
 	"time"
 
 	"github.com/janschill/track-me/internal/repository"
@@ -13,6 +14,12 @@ import (
 
 type Day struct {
 	Date                   string
+	StartTime              int64
+	EndTime                int64
+	StartLatitude          float64
+	StartLongitude         float64
+	EndLatitude            float64
+	EndLongitude           float64
 	AverageSpeed           float64
 	MaxSpeed               float64
 	DistanceInMeters       float64
@@ -25,6 +32,8 @@ type Day struct {
 	NumberOfStops          int64
 	TotalStopTimeInSeconds int64
 	KudosCount             int
+	Climbs                 []utils.Climb
+	eventCount             int
 }
 
 type Ride struct {
@@ -38,24 +47,61 @@ type Ride struct {
 	RemainingDays int
 }
 
+// AverageMovingSpeed returns the speed of the ride in km/h
+func (r Ride) AverageMovingSpeed() float64 {
+	if r.MovingTime == 0 {
+		return 0
+	}
+	return r.Distance / float64(r.MovingTime) * 3.6
+}
+
+type DayConfig struct {
+	// Altitude changes below this many meters do not count as gain or loss
+	ElevationThreshold float64
+}
+
+// dayCache holds the finished days of one trip by date
+type dayCache struct {
+	// Cached days were split in this location
+	location *time.Location
+	days     map[string]Day
+}
+
 type DayService struct {
-	daysCache map[string]Day
+	mu     sync.Mutex
+	config DayConfig
+	// By trip, the days of different trips never mix
+	caches map[int64]*dayCache
 }
 
-func NewDayService() *DayService {
+func NewDayService(config DayConfig) *DayService {
 	return &DayService{
-		daysCache: make(map[string]Day),
+		config: config,
+		caches: make(map[int64]*dayCache),
 	}
 }
 
 func (s *DayService) calculateDayStats(date string, events []repository.Event) Day {
+	eventCount := len(events)
+	first, last := events[0], events[len(events)-1]
+	startTime, endTime := first.TimeStamp, last.TimeStamp
+	// Drop bad fixes and GPS jumps before they add distance and speed
+	events = utils.FilterEvents(events, utils.CyclingFilter)
+	// Prefer terrain heights from the DEM over the noisy GPS altitude
+	events = utils.WithCorrectedAltitudes(events)
 	movingTime, averageSpeed, maxSpeed := utils.CalculateMovingTimeAndAverageSpeed(events, 0.001)
-	gain, loss := utils.CalculateElevationGainAndLoss(events)
+	gain, loss := utils.CalculateElevationGainAndLossWithThreshold(events, s.config.ElevationThreshold)
 	averageAltitude, maxAltitude, minAltitude := utils.CalculateAltitudes(events)
 	// numberOfStops, stopTime := utils.CalculateStops(events)
 
 	return Day{
 		Date:                   date,
+		StartTime:              startTime,
+		EndTime:                endTime,
+		StartLatitude:          first.Latitude,
+		StartLongitude:         first.Longitude,
+		EndLatitude:            last.Latitude,
+		EndLongitude:           last.Longitude,
 		AverageSpeed:           averageSpeed,
 		MaxSpeed:               maxSpeed,
 		DistanceInMeters:       utils.DistanceInMeters(events),
@@ -67,6 +113,8 @@ func (s *DayService) calculateDayStats(date string, events []repository.Event) D
 		MovingTimeInSeconds:    int64(movingTime),
 		NumberOfStops:          0,
 		TotalStopTimeInSeconds: 0,
+		Climbs:                 utils.DetectClimbs(events),
+		eventCount:             eventCount,
 	}
 }
 
@@ -77,19 +125,43 @@ func updateRideStats(ride *Ride, day Day) {
 	ride.MovingTime += day.MovingTimeInSeconds
 }
 
-func (s *DayService) GetDays(events []repository.Event) ([]Day, Ride) {
-	mountainTime, err := time.LoadLocation("America/Denver")
-	if err != nil {
-		log.Fatalf("Failed to load Mountain Time Zone: %v", err)
+// InvalidateRange drops all cached days touched by the unix range from..to,
+// e.g. after events have been imported for that period
+func (s *DayService) InvalidateRange(from, to int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for tripID, cache := range s.caches {
+		first := time.Unix(from, 0).In(cache.location).Format("2006-01-02")
+		last := time.Unix(to, 0).In(cache.location).Format("2006-01-02")
+		for date := range cache.days {
+			if date >= first && date <= last {
+				slog.Debug("Invalidating cached day", "trip", tripID, "day", date)
+				delete(cache.days, date)
+			}
+		}
+	}
+}
+
+// GetDays groups the track events of a trip into calendar days of loc
+func (s *DayService) GetDays(tripID int64, events []repository.Event, loc *time.Location) ([]Day, Ride) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cache := s.caches[tripID]
+	if cache == nil || cache.location.String() != loc.String() {
+		// Days start at a different time now
+		cache = &dayCache{location: loc, days: make(map[string]Day)}
+		s.caches[tripID] = cache
 	}
 
-	currentDate := time.Now().In(mountainTime).Format("2006-01-02")
+	currentDate := time.Now().In(loc).Format("2006-01-02")
 	eventsByDay := make(map[string][]repository.Event)
 
 	var ride Ride
 
 	for _, event := range events {
-		date := time.Unix(event.TimeStamp, 0).In(mountainTime).Format("2006-01-02")
+		date := time.Unix(event.TimeStamp, 0).In(loc).Format("2006-01-02")
 		eventsByDay[date] = append(eventsByDay[date], event)
 	}
 
@@ -103,19 +175,19 @@ func (s *DayService) GetDays(events []repository.Event) ([]Day, Ride) {
 			day = s.calculateDayStats(date, events)
 			days = append(days, day)
 		} else {
-			if day, ok := s.daysCache[date]; ok {
+			if day, ok := cache.days[date]; ok {
 				// Check if the cached day has the same number of events
-				log.Printf("Cache hit for %v", date)
-				if len(events) == len(eventsByDay[date]) {
+				slog.Debug("Cache hit for day", "day", date)
+				if day.eventCount == len(events) {
 					days = append(days, day)
 					updateRideStats(&ride, day)
 					continue // jump to next element in loop
 				}
 				// Bust the cache if new events are detected
-				delete(s.daysCache, date)
+				delete(cache.days, date)
 			}
 			day = s.calculateDayStats(date, events)
-			s.daysCache[date] = day
+			cache.days[date] = day
 			days = append(days, day)
 		}
 
@@ -132,3 +204,32 @@ func (s *DayService) GetDays(events []repository.Event) ([]Day, Ride) {
 
 	return days, ride
 }
+
+type DayProfile struct {
+	Date       string               `json:"date"`
+	Units      utils.Units          `json:"units"`
+	Points     []utils.ProfilePoint `json:"points,omitempty"`
+	SpeedBands []utils.Band         `json:"speedBands,omitempty"`
+	GradeBands []utils.Band         `json:"gradeBands,omitempty"`
+}
+
+// GetProfile prepares the events of a day the same way as the day stats and
+// returns the distance indexed profile with time spent per speed and grade band
+func (s *DayService) GetProfile(date string, events []repository.Event, units utils.UnitSystem) DayProfile {
+	events = utils.FilterEvents(events, utils.CyclingFilter)
+	events = utils.WithCorrectedAltitudes(events)
+	points := utils.Profile(events)
+	for i := range points {
+		points[i].Distance = units.Distance(points[i].Distance)
+		points[i].Altitude = units.Elevation(points[i].Altitude)
+		points[i].Speed = units.Speed(points[i].Speed)
+	}
+
+	return DayProfile{
+		Date:       date,
+		Units:      units.Units(),
+		Points:     points,
+		SpeedBands: utils.MovingTimeInSpeedBands(points, units.SpeedBands()),
+		GradeBands: utils.MovingTimeInGradeBands(points),
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/pkg/dem"
+)
+
+// ElevationService corrects the noisy inReach altitudes with terrain heights from a DEM
+type ElevationService struct {
+	repo *repository.Repository
+	dem  *dem.DEM
+}
+
+// NewElevationService returns nil without a DEM directory, all methods accept a nil service
+func NewElevationService(repo *repository.Repository, demPath string) *ElevationService {
+	if demPath == "" {
+		return nil
+	}
+	return &ElevationService{repo: repo, dem: dem.New(demPath)}
+}
+
+// Correct sets the corrected altitude of an event if the DEM covers its position
+func (s *ElevationService) Correct(event *repository.Event) {
+	if s == nil || (event.Latitude == 0 && event.Longitude == 0) {
+		return
+	}
+
+	altitude, err := s.dem.Elevation(event.Latitude, event.Longitude)
+	if err != nil {
+		if !errors.Is(err, dem.ErrNoData) {
+			slog.Error("Failed to look up DEM elevation", "error", err)
+		}
+		return
+	}
+	event.CorrectedAltitude = &altitude
+}
+
+// Backfill corrects all events of a trip that do not have a corrected altitude yet
+func (s *ElevationService) Backfill(tripID int64) (int, error) {
+	if s == nil {
+		return 0, errors.New("no DEM configured")
+	}
+
+	events, err := s.repo.Events.AllInRange(tripID, 0, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	altitudes := make(map[int64]float64)
+	for _, event := range events {
+		if event.CorrectedAltitude != nil {
+			continue
+		}
+		s.Correct(&event)
+		if event.CorrectedAltitude != nil {
+			altitudes[event.ID] = *event.CorrectedAltitude
+		}
+	}
+
+	if len(altitudes) == 0 {
+		return 0, nil
+	}
+	return len(altitudes), s.repo.Events.UpdateCorrectedAltitudes(altitudes)
+}
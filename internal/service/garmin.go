@@ -1,22 +1,55 @@
 package service
 
 import (
-	"log"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/janschill/track-me/internal/repository"
 	"github.com/janschill/track-me/internal/utils"
 	"github.com/janschill/track-me/pkg/garmin"
+	"github.com/janschill/track-me/pkg/metrics"
 )
 
 type GarminService struct {
-	repo *repository.Repository
+	repo      *repository.Repository
+	elevation *ElevationService
+	records   *RecordService
+	live      *LiveService
+	webhooks  *WebhookService
+	// Unix seconds of the last outbound payload since the start
+	lastPayload atomic.Int64
 }
 
-func NewGarminService(repo *repository.Repository) *GarminService {
-	return &GarminService{repo: repo}
+func NewGarminService(repo *repository.Repository, elevation *ElevationService, records *RecordService, live *LiveService, webhooks *WebhookService) *GarminService {
+	return &GarminService{repo: repo, elevation: elevation, records: records, live: live, webhooks: webhooks}
+}
+
+var eventsIngested = metrics.NewCounterVec(
+	"trackme_events_ingested_total",
+	"Events stored from Garmin outbound payloads by message code.",
+	"code",
+)
+
+// LastPayload returns when the last outbound payload arrived, zero when none
+// did since the server started
+func (s *GarminService) LastPayload() time.Time {
+	if last := s.lastPayload.Load(); last != 0 {
+		return time.Unix(last, 0)
+	}
+	return time.Time{}
 }
 
 func (s *GarminService) ProcessPayload(payload garmin.OutboundPayload) error {
+	s.lastPayload.Store(time.Now().Unix())
+	// Webhooks compare each event with the one before
+	previous, err := s.repo.Events.LastFromSource(1, repository.SourceInReach)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("Failed to retrieve last inReach event", "error", err)
+	}
 	for _, pEvent := range payload.Events {
 		event := repository.Event{
 			TripID:      1,
@@ -53,13 +86,25 @@ func (s *GarminService) ProcessPayload(payload garmin.OutboundPayload) error {
 			}
 
 			if err := s.repo.Messages.Create(message); err != nil {
-				log.Printf("Failed to save message from event %v", event.ID)
+				slog.Error("Failed to save message from event", "event", event.ID, "error", err)
+			} else {
+				s.live.PublishMessage(message)
+				s.webhooks.PublishMessage(message)
 			}
 		}
 
+		s.elevation.Correct(&event)
+
 		if err := s.repo.Events.Create(event); err != nil {
 			return err
 		}
+		eventsIngested.Inc(strconv.Itoa(event.MessageCode))
+		s.live.PublishPosition(event)
+		s.webhooks.PublishEvent(previous, event)
+		previous = event
 	}
+
+	// Garmin waits for the answer, the records are updated in the background
+	s.records.Schedule(1)
 	return nil
 }
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/pkg/pubsub"
+)
+
+const (
+	// Updates kept per trip for followers that reconnect
+	liveHistorySize = 100
+	// Updates queued per follower before a slow follower is dropped
+	liveBufferSize = 32
+)
+
+// Types of live updates
+const (
+	LivePosition = "position"
+	LiveMessage  = "message"
+	LiveKudos    = "kudos"
+)
+
+type livePosition struct {
+	TimeStamp int64   `json:"timeStamp"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude"`
+	Speed     float64 `json:"speed"`
+}
+
+type liveMessage struct {
+	Name       string `json:"name"`
+	Message    string `json:"message"`
+	TimeStamp  int64  `json:"timeStamp"`
+	FromGarmin bool   `json:"fromGarmin"`
+}
+
+type liveKudos struct {
+	Day   string `json:"day"`
+	Count int    `json:"count"`
+}
+
+// LiveService pushes new positions, messages and kudos to the followers of a trip
+type LiveService struct {
+	hub *pubsub.Hub
+}
+
+func NewLiveService() *LiveService {
+	return &LiveService{hub: pubsub.New(liveHistorySize, liveBufferSize)}
+}
+
+func tripTopic(tripID int64) string {
+	return fmt.Sprintf("trip/%d", tripID)
+}
+
+func (s *LiveService) publish(tripID int64, updateType string, v any) {
+	if s == nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("Failed to encode live update", "type", updateType, "error", err)
+		return
+	}
+	s.hub.Publish(tripTopic(tripID), updateType, data)
+}
+
+// PublishPosition sends a new event to the followers if it has a position
+func (s *LiveService) PublishPosition(e repository.Event) {
+	if e.Latitude == 0 && e.Longitude == 0 {
+		return
+	}
+	s.publish(e.TripID, LivePosition, livePosition{
+		TimeStamp: e.TimeStamp,
+		Latitude:  e.Latitude,
+		Longitude: e.Longitude,
+		Altitude:  e.Altitude,
+		Speed:     e.Speed,
+	})
+}
+
+func (s *LiveService) PublishMessage(m repository.Message) {
+	s.publish(m.TripID, LiveMessage, liveMessage{
+		Name:       m.Name,
+		Message:    m.Message,
+		TimeStamp:  m.TimeStamp,
+		FromGarmin: m.FromGarmin,
+	})
+}
+
+// PublishKudos sends the new kudos count of a day
+func (s *LiveService) PublishKudos(tripID int64, day string, count int) {
+	s.publish(tripID, LiveKudos, liveKudos{Day: day, Count: count})
+}
+
+// Subscribe follows the updates of a trip. See pubsub.Hub.Subscribe for lastID.
+func (s *LiveService) Subscribe(tripID int64, lastID uint64) (*pubsub.Subscription, []pubsub.Message, bool) {
+	return s.hub.Subscribe(tripTopic(tripID), lastID)
+}
+
+func (s *LiveService) Unsubscribe(sub *pubsub.Subscription) {
+	s.hub.Unsubscribe(sub)
+}
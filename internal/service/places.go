@@ -0,0 +1,52 @@
+package service
+
+import (
+	"log/slog"
+
+	"github.com/janschill/track-me/internal/i18n"
+	"github.com/janschill/track-me/internal/utils"
+	"github.com/janschill/track-me/pkg/geonames"
+)
+
+// Positions closer than this to a place are in it
+const inPlaceDistance = 1000
+
+// PlaceService names the nearest place of a position from an offline dataset
+type PlaceService struct {
+	index *geonames.Index
+}
+
+// NewPlaceService reads a GeoNames export from placesPath and falls back to the
+// embedded places without one
+func NewPlaceService(placesPath string) *PlaceService {
+	if placesPath == "" {
+		return &PlaceService{index: geonames.Default()}
+	}
+	index, err := geonames.Open(placesPath)
+	if err != nil {
+		slog.Warn("Failed to load places, using the embedded places", "path", placesPath, "error", err)
+		return &PlaceService{index: geonames.Default()}
+	}
+	slog.Info("Loaded places", "count", index.Len(), "path", placesPath)
+	return &PlaceService{index: index}
+}
+
+// Nearest returns the closest place, ok is false for positions without a fix
+func (s *PlaceService) Nearest(lat, lon float64) (geonames.Match, bool) {
+	if lat == 0 && lon == 0 {
+		return geonames.Match{}, false
+	}
+	return s.index.Nearest(lat, lon)
+}
+
+// Label describes a position relative to its nearest place, e.g. 12.3 km NE of Lincoln
+func (s *PlaceService) Label(lat, lon float64, units utils.UnitSystem, l *i18n.Locale) string {
+	match, ok := s.Nearest(lat, lon)
+	if !ok {
+		return ""
+	}
+	if match.Distance < inPlaceDistance {
+		return l.T("place.in", match.Name)
+	}
+	return l.T("place.near", units.FormatDistance(l, match.Distance), l.T("compass."+geonames.Compass(match.Bearing)), match.Name)
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/janschill/track-me/internal/i18n"
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/internal/utils"
+)
+
+// Titles of records are translated with the key record.<name>
+type recordDefinition struct {
+	name string
+	// Lower values are better, e.g. for the earliest start
+	lower  bool
+	value  func(day Day, loc *time.Location) float64
+	format func(units utils.UnitSystem, l *i18n.Locale, value float64) string
+}
+
+func secondsOfDay(ts int64, loc *time.Location) float64 {
+	t := time.Unix(ts, 0).In(loc)
+	return float64(t.Hour()*3600 + t.Minute()*60 + t.Second())
+}
+
+func formatDay(date string, l *i18n.Locale) string {
+	if day, err := utils.OnDayFromString(date, l); err == nil {
+		return day
+	}
+	return date
+}
+
+func formatClock(_ utils.UnitSystem, _ *i18n.Locale, seconds float64) string {
+	return fmt.Sprintf("%02d:%02d", int(seconds)/3600, int(seconds)%3600/60)
+}
+
+var recordDefinitions = []recordDefinition{
+	{
+		name:   "longest-day",
+		value:  func(d Day, _ *time.Location) float64 { return d.DistanceInMeters },
+		format: utils.UnitSystem.FormatDistance,
+	},
+	{
+		name:   "most-climbing",
+		value:  func(d Day, _ *time.Location) float64 { return float64(d.ElevationGain) },
+		format: utils.UnitSystem.FormatElevation,
+	},
+	{
+		name:   "highest-point",
+		value:  func(d Day, _ *time.Location) float64 { return d.MaxAltitude },
+		format: utils.UnitSystem.FormatElevation,
+	},
+	{
+		name:   "fastest-average",
+		value:  func(d Day, _ *time.Location) float64 { return d.AverageSpeed },
+		format: utils.UnitSystem.FormatSpeed,
+	},
+	{
+		name:   "longest-moving-time",
+		value:  func(d Day, _ *time.Location) float64 { return float64(d.MovingTimeInSeconds) },
+		format: func(_ utils.UnitSystem, _ *i18n.Locale, v float64) string { return utils.FormatTime(int64(v)) },
+	},
+	{
+		name:   "earliest-start",
+		lower:  true,
+		value:  func(d Day, loc *time.Location) float64 { return secondsOfDay(d.StartTime, loc) },
+		format: formatClock,
+	},
+	{
+		name:   "latest-start",
+		value:  func(d Day, loc *time.Location) float64 { return secondsOfDay(d.StartTime, loc) },
+		format: formatClock,
+	},
+}
+
+// TripRecord is a stored record ready for display
+type TripRecord struct {
+	Title string
+	Value string
+	Day   string
+}
+
+// Records are updated this long after the first payload of a burst, so the
+// payloads that follow are covered by the same update
+const recordUpdateDelay = 30 * time.Second
+
+type RecordService struct {
+	repo        *repository.Repository
+	dayService  *DayService
+	tripService *TripService
+	live        *LiveService
+
+	mu sync.Mutex
+	// Trips whose records are updated on the next run
+	pending map[int64]bool
+	// Wakes Run when a trip was scheduled
+	wake chan struct{}
+}
+
+func NewRecordService(repo *repository.Repository, dayService *DayService, tripService *TripService, live *LiveService) *RecordService {
+	return &RecordService{
+		repo:        repo,
+		dayService:  dayService,
+		tripService: tripService,
+		live:        live,
+		pending:     make(map[int64]bool),
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+func (d recordDefinition) beats(value, best float64) bool {
+	if d.lower {
+		return value < best
+	}
+	return value > best
+}
+
+// recordUpdate is a record beaten by a day together with the record it replaced
+type recordUpdate struct {
+	record   repository.Record
+	previous repository.Record
+	// Breaking an existing record of another day is announced with a message
+	announce bool
+}
+
+// beatenRecords compares the days finished before today with the best records
+// so far. Every beaten record is returned in the order it was broken.
+func beatenRecords(tripID int64, days []Day, records []repository.Record, loc *time.Location, today string, now int64) []recordUpdate {
+	// The first scan of a trip only stores the records
+	seeding := len(records) == 0
+	best := make(map[string]repository.Record, len(records))
+	for _, r := range records {
+		best[r.Name] = r
+	}
+
+	var updates []recordUpdate
+	for _, day := range days {
+		// The current day is not over yet
+		if day.Date >= today {
+			continue
+		}
+		for _, definition := range recordDefinitions {
+			value := definition.value(day, loc)
+			previous, ok := best[definition.name]
+			if ok && !definition.beats(value, previous.Value) {
+				continue
+			}
+
+			record := repository.Record{
+				TripID:    tripID,
+				Name:      definition.name,
+				Value:     value,
+				Day:       day.Date,
+				TimeStamp: now,
+			}
+			best[definition.name] = record
+			updates = append(updates, recordUpdate{
+				record:   record,
+				previous: previous,
+				// A record day that grew with new events is not news
+				announce: ok && !seeding && previous.Day != day.Date,
+			})
+		}
+	}
+	return updates
+}
+
+// recordMessage is the automated message announcing a beaten record. It is
+// read by everyone and stays in the default locale.
+func recordMessage(u recordUpdate, units utils.UnitSystem) repository.Message {
+	l := i18n.English
+	var format func(utils.UnitSystem, *i18n.Locale, float64) string
+	for _, definition := range recordDefinitions {
+		if definition.name == u.record.Name {
+			format = definition.format
+		}
+	}
+	return repository.Message{
+		TripID: u.record.TripID,
+		Message: l.T("record.message", l.T("record."+u.record.Name),
+			format(units, l, u.record.Value), formatDay(u.record.Day, l), format(units, l, u.previous.Value), formatDay(u.previous.Day, l)),
+		Name:       "Automated Message",
+		TimeStamp:  u.record.TimeStamp,
+		FromGarmin: true,
+	}
+}
+
+// Schedule updates the records of a trip in the background, see Run
+func (s *RecordService) Schedule(tripID int64) {
+	s.mu.Lock()
+	s.pending[tripID] = true
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run updates the records of the scheduled trips until ctx is done
+func (s *RecordService) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(recordUpdateDelay):
+		}
+
+		s.mu.Lock()
+		pending := s.pending
+		s.pending = make(map[int64]bool)
+		s.mu.Unlock()
+		for tripID := range pending {
+			if err := s.Update(tripID); err != nil {
+				slog.Error("Failed to update records", "trip", tripID, "error", err)
+			}
+		}
+	}
+}
+
+// Update scans the finished days of a trip and stores every record that was
+// beaten. Breaking an existing record posts an automated message.
+func (s *RecordService) Update(tripID int64) error {
+	track, err := s.repo.Events.Track(tripID)
+	if err != nil {
+		return err
+	}
+	records, err := s.repo.Records.AllByTrip(tripID)
+	if err != nil {
+		return err
+	}
+
+	loc := s.tripService.Location(tripID)
+	units := s.tripService.Units(tripID)
+	today := time.Now().In(loc).Format("2006-01-02")
+	days, _ := s.dayService.GetDays(tripID, track, loc)
+
+	for _, u := range beatenRecords(tripID, days, records, loc, today, time.Now().Unix()) {
+		if err := s.repo.Records.Save(u.record); err != nil {
+			return err
+		}
+		if !u.announce {
+			continue
+		}
+		message := recordMessage(u, units)
+		if err := s.repo.Messages.Create(message); err != nil {
+			slog.Error("Failed to save record message", "error", err)
+			continue
+		}
+		s.live.PublishMessage(message)
+	}
+
+	return nil
+}
+
+// Records returns the stored records of a trip in display order
+func (s *RecordService) Records(tripID int64, units utils.UnitSystem, l *i18n.Locale) ([]TripRecord, error) {
+	records, err := s.repo.Records.AllByTrip(tripID)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]repository.Record, len(records))
+	for _, r := range records {
+		byName[r.Name] = r
+	}
+
+	var tripRecords []TripRecord
+	for _, definition := range recordDefinitions {
+		if r, ok := byName[definition.name]; ok {
+			tripRecords = append(tripRecords, TripRecord{
+				Title: l.T("record." + definition.name),
+				Value: definition.format(units, l, r.Value),
+				Day:   r.Day,
+			})
+		}
+	}
+	return tripRecords, nil
+}
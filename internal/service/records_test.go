@@ -0,0 +1,139 @@
+package service
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/internal/utils"
+)
+
+func recordDay(date string, startHour int, distance float64) Day {
+	start, _ := time.ParseInLocation("2006-01-02", date, time.UTC)
+	return Day{
+		Date:             date,
+		StartTime:        start.Add(time.Duration(startHour) * time.Hour).Unix(),
+		DistanceInMeters: distance,
+	}
+}
+
+func TestBeatenRecords(t *testing.T) {
+	stored := []repository.Record{
+		{Name: "longest-day", Value: 100000, Day: "2025-06-14"},
+		{Name: "most-climbing", Value: 0, Day: "2025-06-14"},
+		{Name: "highest-point", Value: 0, Day: "2025-06-14"},
+		{Name: "fastest-average", Value: 0, Day: "2025-06-14"},
+		{Name: "longest-moving-time", Value: 0, Day: "2025-06-14"},
+		{Name: "earliest-start", Value: 8 * 3600, Day: "2025-06-14"},
+		{Name: "latest-start", Value: 8 * 3600, Day: "2025-06-14"},
+	}
+
+	tests := []struct {
+		name    string
+		days    []Day
+		records []repository.Record
+		today   string
+		// "<record> <day>", followed by " announced" when a message is posted
+		expected []string
+	}{
+		{
+			name:    "seeding stores without messages",
+			days:    []Day{recordDay("2025-06-14", 8, 100000), recordDay("2025-06-15", 6, 120000)},
+			records: nil,
+			today:   "2025-06-16",
+			expected: []string{
+				"longest-day 2025-06-14",
+				"most-climbing 2025-06-14",
+				"highest-point 2025-06-14",
+				"fastest-average 2025-06-14",
+				"longest-moving-time 2025-06-14",
+				"earliest-start 2025-06-14",
+				"latest-start 2025-06-14",
+				"longest-day 2025-06-15",
+				"earliest-start 2025-06-15",
+			},
+		},
+		{
+			name:     "beaten records are announced",
+			days:     []Day{recordDay("2025-06-15", 6, 120000)},
+			records:  stored,
+			today:    "2025-06-16",
+			expected: []string{"longest-day 2025-06-15 announced", "earliest-start 2025-06-15 announced"},
+		},
+		{
+			name:     "later start beats the latest start",
+			days:     []Day{recordDay("2025-06-15", 10, 50000)},
+			records:  stored,
+			today:    "2025-06-16",
+			expected: []string{"latest-start 2025-06-15 announced"},
+		},
+		{
+			name:     "record day that grew",
+			days:     []Day{recordDay("2025-06-14", 8, 110000)},
+			records:  stored,
+			today:    "2025-06-16",
+			expected: []string{"longest-day 2025-06-14"},
+		},
+		{
+			name:     "today is not over",
+			days:     []Day{recordDay("2025-06-15", 6, 120000)},
+			records:  stored,
+			today:    "2025-06-15",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, u := range beatenRecords(1, tt.days, tt.records, time.UTC, tt.today, 1750000000) {
+				update := u.record.Name + " " + u.record.Day
+				if u.announce {
+					update += " announced"
+				}
+				got = append(got, update)
+			}
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("beatenRecords() = %q; expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRecordMessage(t *testing.T) {
+	tests := []struct {
+		update   recordUpdate
+		units    utils.UnitSystem
+		expected string
+	}{
+		{
+			update: recordUpdate{
+				record:   repository.Record{TripID: 1, Name: "longest-day", Value: 120000, Day: "2025-06-15", TimeStamp: 1750000000},
+				previous: repository.Record{Name: "longest-day", Value: 100000, Day: "2025-06-14"},
+			},
+			units:    utils.Metric,
+			expected: "New record! Longest Day: 120.0 km on 15 June, the previous best was 100.0 km on 14 June.",
+		},
+		{
+			update: recordUpdate{
+				record:   repository.Record{TripID: 1, Name: "earliest-start", Value: 6*3600 + 30*60, Day: "2025-06-15", TimeStamp: 1750000000},
+				previous: repository.Record{Name: "earliest-start", Value: 8 * 3600, Day: "2025-06-14"},
+			},
+			units:    utils.Imperial,
+			expected: "New record! Earliest Start: 06:30 on 15 June, the previous best was 08:00 on 14 June.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.update.record.Name, func(t *testing.T) {
+			message := recordMessage(tt.update, tt.units)
+			if message.Message != tt.expected {
+				t.Errorf("recordMessage() = %q; expected %q", message.Message, tt.expected)
+			}
+			if !message.FromGarmin || message.TripID != 1 || message.TimeStamp != 1750000000 {
+				t.Errorf("recordMessage() = %+v; expected an automated message of trip 1", message)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/internal/utils"
+	"github.com/janschill/track-me/pkg/gpx"
+	"github.com/janschill/track-me/web"
+)
+
+const (
+	// Points farther off the planned route are not located on it
+	maxOffRoute = 5000.0
+	// Moving speed in km/h for estimates before the ride has any moving time
+	defaultRouteSpeed = 12.0
+)
+
+// Words in the type or symbol of a waypoint that decide its kind, checked in order
+var poiKeywords = []struct {
+	kind  string
+	words []string
+}{
+	{repository.POIWater, []string{"water", "spring", "drinking"}},
+	{repository.POILodging, []string{"lodging", "hotel", "motel", "camp", "cabin", "hostel", "lodge"}},
+	{repository.POIResupply, []string{"food", "store", "grocery", "shopping", "restaurant", "convenience", "gas station", "cafe", "market", "resupply"}},
+}
+
+// UpcomingPOI is a point of interest ahead of the rider
+type UpcomingPOI struct {
+	repository.POI
+	// Meters along the route from the rider
+	Distance float64
+	// Moving time at the ride's average speed
+	Seconds int64
+}
+
+// RouteService locates the rider and points of interest on the planned route
+type RouteService struct {
+	repo  *repository.Repository
+	route *utils.Route
+}
+
+// NewRouteService reads the planned route from a GPX file. It returns nil without
+// a usable route, all methods accept a nil service.
+func NewRouteService(repo *repository.Repository, routePath string) *RouteService {
+	if routePath == "" {
+		return nil
+	}
+	route, err := readRoute(routePath)
+	if err != nil {
+		slog.Error("Failed to read the planned route", "error", err)
+		return nil
+	}
+	return &RouteService{repo: repo, route: route}
+}
+
+func readRoute(path string) (*utils.Route, error) {
+	// The default route ships with the binary
+	f, err := web.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	g, err := gpx.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GPX %s: %w", path, err)
+	}
+	points := g.Points()
+	if len(points) < 2 {
+		return nil, fmt.Errorf("%s has no track to follow", path)
+	}
+	return utils.NewRoute(points), nil
+}
+
+// POIKind classifies a waypoint as resupply, water, lodging or other
+func POIKind(w gpx.Waypoint) string {
+	text := strings.ToLower(w.Type + " " + w.Symbol)
+	for _, k := range poiKeywords {
+		for _, word := range k.words {
+			if strings.Contains(text, word) {
+				return k.kind
+			}
+		}
+	}
+	return repository.POIOther
+}
+
+// ImportPOIs replaces the points of interest of a trip with the waypoints that
+// lie within maxOffRoute of the planned route. The stored points stay when no
+// waypoint is on the route, e.g. with the GPX of another route.
+func (s *RouteService) ImportPOIs(tripID int64, waypoints []gpx.Waypoint) (imported, skipped int, err error) {
+	if s == nil {
+		return 0, 0, fmt.Errorf("no planned route configured")
+	}
+
+	var pois []repository.POI
+	for _, w := range waypoints {
+		along, offRoute := s.route.Locate(w.Latitude, w.Longitude)
+		if offRoute > maxOffRoute {
+			slog.Info("Skipping waypoint off the route", "waypoint", w.Name, "meters", int(offRoute))
+			skipped++
+			continue
+		}
+		pois = append(pois, repository.POI{
+			TripID:        tripID,
+			Name:          w.Name,
+			Kind:          POIKind(w),
+			Description:   w.Description,
+			Latitude:      w.Latitude,
+			Longitude:     w.Longitude,
+			RouteDistance: along,
+		})
+	}
+	if len(waypoints) == 0 {
+		return 0, 0, fmt.Errorf("no waypoints to import")
+	}
+	if len(pois) == 0 {
+		return 0, skipped, fmt.Errorf("none of the %d waypoints is within %d m of the planned route", len(waypoints), int(maxOffRoute))
+	}
+	if err := s.repo.POIs.ReplaceForTrip(tripID, pois); err != nil {
+		return 0, 0, err
+	}
+	return len(pois), skipped, nil
+}
+
+// Locate returns the meters along the planned route of a position, ok is false
+// when the position is too far off the route
+func (s *RouteService) Locate(lat, lon float64) (along float64, ok bool) {
+	if s == nil || (lat == 0 && lon == 0) {
+		return 0, false
+	}
+	along, offRoute := s.route.Locate(lat, lon)
+	return along, offRoute <= maxOffRoute
+}
+
+// EstimatedSpeed is the ride's average moving speed in km/h, or a default before
+// the ride has any moving time
+func EstimatedSpeed(ride Ride) float64 {
+	if speed := ride.AverageMovingSpeed(); speed > 0 {
+		return speed
+	}
+	return defaultRouteSpeed
+}
+
+// Upcoming returns up to limit points of interest ahead of a position, only of
+// one kind unless kind is empty. Times are estimated at speed in km/h.
+func (s *RouteService) Upcoming(tripID int64, lat, lon, speed float64, kind string, limit int) ([]UpcomingPOI, error) {
+	along, ok := s.Locate(lat, lon)
+	if !ok {
+		return nil, nil
+	}
+	pois, err := s.repo.POIs.AllByTrip(tripID)
+	if err != nil {
+		return nil, err
+	}
+	if speed <= 0 {
+		return nil, fmt.Errorf("invalid speed %v", speed)
+	}
+
+	var upcoming []UpcomingPOI
+	for _, poi := range pois {
+		if len(upcoming) == limit {
+			break
+		}
+		if poi.RouteDistance < along || (kind != "" && poi.Kind != kind) {
+			continue
+		}
+		distance := poi.RouteDistance - along
+		upcoming = append(upcoming, UpcomingPOI{
+			POI:      poi,
+			Distance: distance,
+			Seconds:  int64(distance / (speed / 3.6)),
+		})
+	}
+	return upcoming, nil
+}
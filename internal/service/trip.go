@@ -0,0 +1,141 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/internal/utils"
+	"github.com/janschill/track-me/pkg/tz"
+)
+
+// Zones of trips are cached this long, so a zone set with make timezone-db
+// reaches a running server
+const locationTTL = time.Minute
+
+type cachedLocation struct {
+	loc       *time.Location
+	expiresAt time.Time
+}
+
+type TripService struct {
+	repo   *repository.Repository
+	finder *tz.Finder
+
+	mu        sync.Mutex
+	locations map[int64]cachedLocation
+}
+
+func NewTripService(repo *repository.Repository) *TripService {
+	return &TripService{repo: repo, finder: tz.Default(), locations: make(map[int64]cachedLocation)}
+}
+
+// Location is the time zone the days of a trip are split and shown in. Trips
+// with the auto timezone use the zone of the first position, so past days keep
+// their dates when the rider crosses into another zone. UTC is the fallback.
+func (s *TripService) Location(tripID int64) *time.Location {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cached, ok := s.locations[tripID]; ok && time.Now().Before(cached.expiresAt) {
+		return cached.loc
+	}
+	loc, ok := s.location(tripID)
+	if ok {
+		s.locations[tripID] = cachedLocation{loc: loc, expiresAt: time.Now().Add(locationTTL)}
+	}
+	return loc
+}
+
+// location resolves the zone of a trip, false when it fell back to UTC after an error
+func (s *TripService) location(tripID int64) (*time.Location, bool) {
+	trip, err := s.repo.Trips.Get(tripID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("Error retrieving trip", "trip", tripID, "error", err)
+		return time.UTC, false
+	}
+
+	if trip.Timezone != "" && trip.Timezone != repository.TimezoneAuto {
+		loc, err := time.LoadLocation(trip.Timezone)
+		if err != nil {
+			slog.Warn("Unknown timezone of trip", "timezone", trip.Timezone, "trip", tripID, "error", err)
+			return time.UTC, true
+		}
+		return loc, true
+	}
+
+	first, err := s.repo.Events.First(tripID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Error("Error retrieving first event of trip", "trip", tripID, "error", err)
+			return time.UTC, false
+		}
+		return time.UTC, true
+	}
+	return s.finder.Location(first.Latitude, first.Longitude), true
+}
+
+// SetTimezone accepts IANA zone names and repository.TimezoneAuto
+func (s *TripService) SetTimezone(tripID int64, timezone string) error {
+	if timezone != repository.TimezoneAuto {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return err
+		}
+	}
+	if err := s.repo.Trips.UpdateTimezone(tripID, timezone); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.locations, tripID)
+	s.mu.Unlock()
+	return nil
+}
+
+// SetDates sets the first and last day of a trip in yyyy-mm-dd, days in the
+// trip's timezone. An empty last day leaves the trip open.
+func (s *TripService) SetDates(tripID int64, first, last string) error {
+	loc := s.Location(tripID)
+	start, err := time.ParseInLocation("2006-01-02", first, loc)
+	if err != nil {
+		return fmt.Errorf("invalid first day %q, expected yyyy-mm-dd", first)
+	}
+	var end int64
+	if last != "" {
+		lastDay, err := time.ParseInLocation("2006-01-02", last, loc)
+		if err != nil {
+			return fmt.Errorf("invalid last day %q, expected yyyy-mm-dd", last)
+		}
+		if lastDay.Before(start) {
+			return fmt.Errorf("last day %s is before the first day %s", last, first)
+		}
+		// The trip ends when the last day does
+		end = lastDay.AddDate(0, 0, 1).Unix()
+	}
+	return s.repo.Trips.UpdateTimes(tripID, start.Unix(), end)
+}
+
+// Units is the default unit system of a trip, metric unless configured otherwise
+func (s *TripService) Units(tripID int64) utils.UnitSystem {
+	trip, err := s.repo.Trips.Get(tripID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Error("Error retrieving trip", "trip", tripID, "error", err)
+		}
+		return utils.Metric
+	}
+	if units, ok := utils.ParseUnitSystem(trip.Units); ok {
+		return units
+	}
+	return utils.Metric
+}
+
+func (s *TripService) SetUnits(tripID int64, units string) error {
+	if _, ok := utils.ParseUnitSystem(units); !ok {
+		return fmt.Errorf("unknown unit system %q, expected metric or imperial", units)
+	}
+	return s.repo.Trips.UpdateUnits(tripID, units)
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/pkg/notify"
+)
+
+// Severity of the escalation levels, later levels stay at the last one
+var severities = []string{"warning", "critical", "emergency"}
+
+type WatchdogConfig struct {
+	// Silence after which each escalation level is reached, increasing
+	Thresholds []time.Duration
+	// How often the trips are checked
+	Interval time.Duration
+}
+
+// WatchdogService alerts when the tracker of an active trip stops sending
+// positions, escalates the longer it stays silent and resolves the alert once
+// positions arrive again. Open alerts are stored, a restart does not repeat them.
+type WatchdogService struct {
+	repo        *repository.Repository
+	tripService *TripService
+	config      WatchdogConfig
+	notifiers   []notify.Notifier
+}
+
+func NewWatchdogService(repo *repository.Repository, tripService *TripService, config WatchdogConfig, notifiers ...notify.Notifier) *WatchdogService {
+	return &WatchdogService{
+		repo:        repo,
+		tripService: tripService,
+		config:      config,
+		notifiers:   notifiers,
+	}
+}
+
+func severity(level int) string {
+	return severities[min(level, len(severities))-1]
+}
+
+// level is the number of thresholds a silence reached
+func (s *WatchdogService) level(silence time.Duration) int {
+	level := 0
+	for _, threshold := range s.config.Thresholds {
+		if silence >= threshold {
+			level++
+		}
+	}
+	return level
+}
+
+// Run checks the trips right away and then every interval until ctx is done
+func (s *WatchdogService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Check(ctx, time.Now()); err != nil {
+			slog.Error("Watchdog check failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check compares the last position of every active trip with the thresholds
+func (s *WatchdogService) Check(ctx context.Context, now time.Time) error {
+	trips, err := s.repo.Trips.Active(now.Unix())
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, trip := range trips {
+		if err := s.checkTrip(ctx, trip, now); err != nil {
+			errs = append(errs, fmt.Errorf("trip %d: %w", trip.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *WatchdogService) checkTrip(ctx context.Context, trip repository.Trip, now time.Time) error {
+	last, err := s.repo.Events.Last(trip.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Nothing to watch before the first position
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	open, err := s.repo.Alerts.Get(trip.ID)
+	hasAlert := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	// A position after the silence resolves the alert, even if the tracker went
+	// silent again since
+	if hasAlert && last.TimeStamp > open.LastPositionAt {
+		if err := s.notify(ctx, s.resolved(trip, open, last)); err != nil {
+			return err
+		}
+		if err := s.repo.Alerts.Resolve(trip.ID); err != nil {
+			return err
+		}
+		slog.Info("Watchdog alert resolved", "trip", trip.ID, "escalation", open.Level)
+		hasAlert = false
+	}
+
+	silence := now.Sub(time.Unix(last.TimeStamp, 0))
+	level := s.level(silence)
+	if level == 0 || (hasAlert && level <= open.Level) {
+		return nil
+	}
+
+	alert := repository.Alert{TripID: trip.ID, Level: level, LastPositionAt: last.TimeStamp, NotifiedAt: now.Unix()}
+	if err := s.notify(ctx, s.escalated(trip, alert, last, silence)); err != nil {
+		// Not saved, the next check tries again
+		return err
+	}
+	slog.Warn("Watchdog alert", "trip", trip.ID, "escalation", level, "silence", silence.Truncate(time.Minute).String())
+	return s.repo.Alerts.Save(alert)
+}
+
+// notify succeeds when at least one notifier delivered the alert
+func (s *WatchdogService) notify(ctx context.Context, alert notify.Alert) error {
+	if len(s.notifiers) == 0 {
+		return nil
+	}
+	var errs []error
+	for _, n := range s.notifiers {
+		if err := n.Notify(ctx, alert); err != nil {
+			slog.Error("Failed to send watchdog alert", "notifier", fmt.Sprintf("%T", n), "error", err)
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == len(s.notifiers) {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+func tripName(trip repository.Trip) string {
+	if trip.Description != "" {
+		return trip.Description
+	}
+	return fmt.Sprintf("trip %d", trip.ID)
+}
+
+// formatSilence prints a duration in hours and minutes like 6h05m
+func formatSilence(d time.Duration) string {
+	hours, minutes := int(d.Hours()), int(d.Minutes())%60
+	if hours == 0 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh%02dm", hours, minutes)
+}
+
+func (s *WatchdogService) fields(trip repository.Trip, alert repository.Alert, last repository.Event) map[string]any {
+	return map[string]any{
+		"trip":           trip.ID,
+		"level":          alert.Level,
+		"severity":       severity(alert.Level),
+		"lastPositionAt": last.TimeStamp,
+		"latitude":       last.Latitude,
+		"longitude":      last.Longitude,
+	}
+}
+
+func (s *WatchdogService) escalated(trip repository.Trip, alert repository.Alert, last repository.Event, silence time.Duration) notify.Alert {
+	loc := s.tripService.Location(trip.ID)
+	fields := s.fields(trip, alert, last)
+	fields["resolved"] = false
+	fields["silentFor"] = int64(silence.Seconds())
+	return notify.Alert{
+		Subject: fmt.Sprintf("[%s] No position from %s for %s", severity(alert.Level), tripName(trip), formatSilence(silence)),
+		Text: fmt.Sprintf("The tracker of %s has not sent a position for %s.\n\nLast position: %.5f, %.5f at %s\nEscalation level %d of %d.\n",
+			tripName(trip), formatSilence(silence), last.Latitude, last.Longitude,
+			time.Unix(last.TimeStamp, 0).In(loc).Format("2006-01-02 15:04 MST"), alert.Level, len(s.config.Thresholds)),
+		Fields: fields,
+	}
+}
+
+func (s *WatchdogService) resolved(trip repository.Trip, open repository.Alert, last repository.Event) notify.Alert {
+	loc := s.tripService.Location(trip.ID)
+	silence := time.Unix(last.TimeStamp, 0).Sub(time.Unix(open.LastPositionAt, 0))
+	fields := s.fields(trip, open, last)
+	fields["resolved"] = true
+	fields["silentFor"] = int64(silence.Seconds())
+	return notify.Alert{
+		Subject: fmt.Sprintf("[resolved] %s sends positions again", tripName(trip)),
+		Text: fmt.Sprintf("The tracker of %s sent a position again after %s of silence.\n\nPosition: %.5f, %.5f at %s\n",
+			tripName(trip), formatSilence(silence), last.Latitude, last.Longitude,
+			time.Unix(last.TimeStamp, 0).In(loc).Format("2006-01-02 15:04 MST")),
+		Fields: fields,
+	}
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/janschill/track-me/internal/db"
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/pkg/notify"
+)
+
+// fakeNotifier records the alerts it was given and fails while err is set
+type fakeNotifier struct {
+	err    error
+	alerts []notify.Alert
+}
+
+func (n *fakeNotifier) Notify(_ context.Context, alert notify.Alert) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.alerts = append(n.alerts, alert)
+	return nil
+}
+
+func newTestRepository(t *testing.T) *repository.Repository {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "trackme.db")
+	db.CreateTables(path)
+	database, err := db.InitializeDB(path)
+	if err != nil {
+		t.Fatalf("InitializeDB() error = %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return repository.NewRepository(database)
+}
+
+func TestWatchdogCheck(t *testing.T) {
+	repo := newTestRepository(t)
+	start := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	if err := repo.Trips.UpdateTimezone(1, "UTC"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Trips.UpdateTimes(1, start.Add(-24*time.Hour).Unix(), 0); err != nil {
+		t.Fatal(err)
+	}
+	position := func(at time.Time) {
+		t.Helper()
+		err := repo.Events.Create(repository.Event{TripID: 1, Imei: "test", Latitude: 45.5, Longitude: -110.5, GpsFix: 3, TimeStamp: at.Unix()})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	position(start)
+
+	notifier := &fakeNotifier{}
+	watchdog := NewWatchdogService(repo, NewTripService(repo), WatchdogConfig{
+		Thresholds: []time.Duration{2 * time.Hour, 6 * time.Hour, 24 * time.Hour},
+	}, notifier)
+
+	// The steps run in order on the same database
+	steps := []struct {
+		name string
+		// Time of a position recorded before the check, zero for none
+		position time.Duration
+		now      time.Duration
+		failing  bool
+		// Severities of the alerts sent by the check, or resolved
+		expected []string
+		// Level of the stored alert after the check, 0 for none
+		level   int
+		wantErr bool
+	}{
+		{name: "below the first threshold", now: time.Hour},
+		{name: "first threshold", now: 2 * time.Hour, expected: []string{"warning"}, level: 1},
+		{name: "same level is not repeated", now: 3 * time.Hour, level: 1},
+		{name: "failed notification is not saved", now: 6 * time.Hour, failing: true, level: 1, wantErr: true},
+		{name: "next check retries", now: 6*time.Hour + 5*time.Minute, expected: []string{"critical"}, level: 2},
+		{name: "last threshold", now: 30 * time.Hour, expected: []string{"emergency"}, level: 3},
+		{name: "stays at the last level", now: 48 * time.Hour, level: 3},
+		{name: "position resumed", position: 49 * time.Hour, now: 49*time.Hour + 5*time.Minute, expected: []string{"resolved"}},
+		{name: "silent again", now: 51*time.Hour + 5*time.Minute, expected: []string{"warning"}, level: 1},
+	}
+
+	for _, step := range steps {
+		if step.position != 0 {
+			position(start.Add(step.position))
+		}
+		notifier.alerts = nil
+		notifier.err = nil
+		if step.failing {
+			notifier.err = errors.New("relay down")
+		}
+
+		err := watchdog.Check(context.Background(), start.Add(step.now))
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%s: Check() error = %v; wantErr %v", step.name, err, step.wantErr)
+		}
+		var sent []string
+		for _, alert := range notifier.alerts {
+			if alert.Fields["resolved"] == true {
+				sent = append(sent, "resolved")
+			} else {
+				sent = append(sent, alert.Fields["severity"].(string))
+			}
+		}
+		if !slices.Equal(sent, step.expected) {
+			t.Errorf("%s: sent %q; expected %q", step.name, sent, step.expected)
+		}
+
+		var level int
+		if alert, err := repo.Alerts.Get(1); err == nil {
+			level = alert.Level
+		}
+		if level != step.level {
+			t.Errorf("%s: stored level %d; expected %d", step.name, level, step.level)
+		}
+	}
+}
+
+func TestWatchdogSkipsTripsWithoutDates(t *testing.T) {
+	repo := newTestRepository(t)
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	if err := repo.Events.Create(repository.Event{TripID: 1, Imei: "test", Latitude: 45.5, Longitude: -110.5, GpsFix: 3, TimeStamp: now.Add(-48 * time.Hour).Unix()}); err != nil {
+		t.Fatal(err)
+	}
+	notifier := &fakeNotifier{}
+	watchdog := NewWatchdogService(repo, NewTripService(repo), WatchdogConfig{Thresholds: []time.Duration{2 * time.Hour}}, notifier)
+	if err := watchdog.Check(context.Background(), now); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(notifier.alerts) != 0 {
+		t.Errorf("sent %d alerts for a trip without dates; expected none", len(notifier.alerts))
+	}
+}
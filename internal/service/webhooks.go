@@ -0,0 +1,396 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/janschill/track-me/internal/repository"
+	"github.com/janschill/track-me/internal/utils"
+	"github.com/janschill/track-me/pkg/metrics"
+	"github.com/janschill/track-me/pkg/webhook"
+)
+
+// Types of webhook events
+const (
+	WebhookDayFinished    = "day.finished"
+	WebhookDeviceMessage  = "message.device"
+	WebhookVisitorMessage = "message.visitor"
+	WebhookSOS            = "sos"
+	WebhookLowBattery     = "battery.low"
+	WebhookKudosMilestone = "kudos.milestone"
+)
+
+// WebhookEvents are the event types webhooks can subscribe to
+var WebhookEvents = []string{
+	WebhookDayFinished,
+	WebhookDeviceMessage,
+	WebhookVisitorMessage,
+	WebhookSOS,
+	WebhookLowBattery,
+	WebhookKudosMilestone,
+}
+
+const (
+	// Deliveries sent per query of the queue
+	webhookBatchSize = 20
+	// Retries double from webhookRetryBase up to webhookRetryMax, about four
+	// hours in total before a delivery fails
+	webhookMaxAttempts = 10
+	webhookRetryBase   = 30 * time.Second
+	webhookRetryMax    = 2 * time.Hour
+	// The queue is checked this often for retries that became due
+	webhookPollInterval = 15 * time.Second
+	// Delivered and failed deliveries stay in the log this long
+	webhookLogRetention = 30 * 24 * time.Hour
+)
+
+// Garmin message codes of the SOS states
+var sosStates = map[int]string{
+	4: "declared",
+	6: "confirmed",
+	7: "cancelled",
+}
+
+// Kudos counts of a day that are announced
+var kudosMilestones = []int{10, 25, 50, 100, 250, 500, 1000}
+
+var webhookDeliveries = metrics.NewCounterVec(
+	"webhook_deliveries_total",
+	"Attempts to deliver webhooks by result: delivered, retry or failed.",
+	"result",
+)
+
+type webhookPayload struct {
+	Event  string `json:"event"`
+	TripID int64  `json:"tripId"`
+	// Unix seconds the event was queued at
+	CreatedAt int64 `json:"createdAt"`
+	Data      any   `json:"data"`
+}
+
+// Distances are in meters, speeds in km/h and durations in seconds like in the
+// JSON API
+type webhookDay struct {
+	Date          string  `json:"date"`
+	StartTime     int64   `json:"startTime"`
+	EndTime       int64   `json:"endTime"`
+	Distance      float64 `json:"distance"`
+	AverageSpeed  float64 `json:"averageSpeed"`
+	MaxSpeed      float64 `json:"maxSpeed"`
+	ElevationGain int64   `json:"elevationGain"`
+	ElevationLoss int64   `json:"elevationLoss"`
+	MaxAltitude   float64 `json:"maxAltitude"`
+	MovingTime    int64   `json:"movingTime"`
+}
+
+type webhookDeviceMessage struct {
+	Message   string `json:"message"`
+	TimeStamp int64  `json:"timeStamp"`
+}
+
+type webhookVisitorMessage struct {
+	Name         string `json:"name"`
+	Message      string `json:"message"`
+	TimeStamp    int64  `json:"timeStamp"`
+	SentToGarmin bool   `json:"sentToGarmin"`
+}
+
+type webhookPosition struct {
+	TimeStamp int64   `json:"timeStamp"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type webhookSOS struct {
+	webhookPosition
+	// declared, confirmed or cancelled
+	State   string `json:"state"`
+	Message string `json:"message,omitempty"`
+}
+
+type webhookKudos struct {
+	Day   string `json:"day"`
+	Count int    `json:"count"`
+}
+
+// WebhookService posts trip events to the registered webhooks. Events are
+// queued in the database and delivered in the background, failed deliveries
+// are retried with exponential backoff.
+type WebhookService struct {
+	repo        *repository.Repository
+	dayService  *DayService
+	tripService *TripService
+	client      *http.Client
+	// Wakes Run when an event was queued
+	wake chan struct{}
+}
+
+func NewWebhookService(repo *repository.Repository, dayService *DayService, tripService *TripService) *WebhookService {
+	return &WebhookService{
+		repo:        repo,
+		dayService:  dayService,
+		tripService: tripService,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// NewWebhook validates a webhook to register and generates its secret
+func (s *WebhookService) NewWebhook(rawURL string, events []string) (repository.Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return repository.Webhook{}, fmt.Errorf("invalid url %q, expected an http or https URL", rawURL)
+	}
+	if len(events) == 0 {
+		return repository.Webhook{}, fmt.Errorf("no events, expected some of %v", WebhookEvents)
+	}
+	for _, event := range events {
+		if !slices.Contains(WebhookEvents, event) {
+			return repository.Webhook{}, fmt.Errorf("unknown event %q, expected one of %v", event, WebhookEvents)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return repository.Webhook{}, err
+	}
+	return repository.Webhook{
+		URL:       u.String(),
+		Secret:    hex.EncodeToString(secret),
+		Events:    slices.Compact(slices.Sorted(slices.Values(events))),
+		CreatedAt: time.Now().Unix(),
+	}, nil
+}
+
+// enqueue queues an event for every webhook subscribed to its type
+func (s *WebhookService) enqueue(tripID int64, event string, data any) {
+	if s == nil {
+		return
+	}
+	webhooks, err := s.repo.Webhooks.All()
+	if err != nil {
+		slog.Error("Failed to queue webhook", "event", event, "error", err)
+		return
+	}
+	var ids []int64
+	for _, w := range webhooks {
+		if w.Subscribes(event) {
+			ids = append(ids, w.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	now := time.Now().Unix()
+	payload, err := json.Marshal(webhookPayload{Event: event, TripID: tripID, CreatedAt: now, Data: data})
+	if err != nil {
+		slog.Error("Failed to encode webhook", "event", event, "error", err)
+		return
+	}
+	if err := s.repo.Webhooks.Enqueue(ids, event, string(payload), now); err != nil {
+		slog.Error("Failed to queue webhook", "event", event, "error", err)
+		return
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// PublishEvent queues the webhooks an inReach event triggers. previous is the
+// inReach event of the trip before it, zero for the first one.
+func (s *WebhookService) PublishEvent(previous, e repository.Event) {
+	if s == nil {
+		return
+	}
+	position := webhookPosition{TimeStamp: e.TimeStamp, Latitude: e.Latitude, Longitude: e.Longitude}
+	if state, ok := sosStates[e.MessageCode]; ok {
+		s.enqueue(e.TripID, WebhookSOS, webhookSOS{webhookPosition: position, State: state, Message: e.FreeText})
+	}
+	if e.Status.LowBattery != 0 && previous.Status.LowBattery == 0 {
+		s.enqueue(e.TripID, WebhookLowBattery, position)
+	}
+
+	// A day is finished once the first event of a later day arrives
+	if previous.TimeStamp == 0 {
+		return
+	}
+	loc := s.tripService.Location(e.TripID)
+	date := time.Unix(previous.TimeStamp, 0).In(loc).Format("2006-01-02")
+	if time.Unix(e.TimeStamp, 0).In(loc).Format("2006-01-02") > date {
+		s.publishDay(e.TripID, date, loc)
+	}
+}
+
+func (s *WebhookService) publishDay(tripID int64, date string, loc *time.Location) {
+	start, err := time.ParseInLocation("2006-01-02", date, loc)
+	if err != nil {
+		return
+	}
+	events, err := s.repo.Events.AllInRange(tripID, start.Unix(), start.AddDate(0, 0, 1).Unix()-1)
+	if err != nil {
+		slog.Error("Failed to retrieve events of finished day", "day", date, "error", err)
+		return
+	}
+	track := make([]repository.Event, 0, len(events))
+	for _, e := range events {
+		if !utils.HasMessage(e) && (e.Latitude != 0 || e.Longitude != 0) {
+			track = append(track, e)
+		}
+	}
+	if len(track) == 0 {
+		return
+	}
+
+	day := s.dayService.calculateDayStats(date, track)
+	s.enqueue(tripID, WebhookDayFinished, webhookDay{
+		Date:          day.Date,
+		StartTime:     day.StartTime,
+		EndTime:       day.EndTime,
+		Distance:      day.DistanceInMeters,
+		AverageSpeed:  day.AverageSpeed,
+		MaxSpeed:      day.MaxSpeed,
+		ElevationGain: day.ElevationGain,
+		ElevationLoss: day.ElevationLoss,
+		MaxAltitude:   day.MaxAltitude,
+		MovingTime:    day.MovingTimeInSeconds,
+	})
+}
+
+// PublishMessage queues a message sent from the inReach or by a visitor
+func (s *WebhookService) PublishMessage(m repository.Message) {
+	if m.FromGarmin {
+		s.enqueue(m.TripID, WebhookDeviceMessage, webhookDeviceMessage{Message: m.Message, TimeStamp: m.TimeStamp})
+		return
+	}
+	s.enqueue(m.TripID, WebhookVisitorMessage, webhookVisitorMessage{
+		Name:         m.Name,
+		Message:      m.Message,
+		TimeStamp:    m.TimeStamp,
+		SentToGarmin: m.SentToGarmin,
+	})
+}
+
+// PublishKudos queues the kudos count of a day when it reached a milestone
+func (s *WebhookService) PublishKudos(tripID int64, day string, count int) {
+	if slices.Contains(kudosMilestones, count) {
+		s.enqueue(tripID, WebhookKudosMilestone, webhookKudos{Day: day, Count: count})
+	}
+}
+
+// Run delivers the queued events until ctx is done. Deliveries still pending
+// on shutdown are sent after the next start.
+func (s *WebhookService) Run(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	for {
+		s.deliverDue(ctx)
+		if err := s.repo.Webhooks.Prune(time.Now().Add(-webhookLogRetention).Unix()); err != nil {
+			slog.Error("Failed to prune webhook deliveries", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// deliverDue sends the due deliveries, one webhook after the other but all
+// webhooks at once, so an endpoint that is down does not hold up the others
+func (s *WebhookService) deliverDue(ctx context.Context) {
+	webhooks, err := s.repo.Webhooks.All()
+	if err != nil {
+		slog.Error("Failed to read webhooks", "error", err)
+		return
+	}
+	var wg sync.WaitGroup
+	for _, w := range webhooks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.deliverWebhook(ctx, w.ID)
+		}()
+	}
+	wg.Wait()
+}
+
+// deliverWebhook sends the due deliveries of a webhook in order until none is
+// left. After a failed attempt the others wait for the next pass, the endpoint
+// is likely to fail them as well.
+func (s *WebhookService) deliverWebhook(ctx context.Context, webhookID int64) {
+	for ctx.Err() == nil {
+		deliveries, err := s.repo.Webhooks.Due(webhookID, time.Now().Unix(), webhookBatchSize)
+		if err != nil {
+			slog.Error("Failed to read webhook queue", "webhook", webhookID, "error", err)
+			return
+		}
+		for _, d := range deliveries {
+			if !s.deliver(ctx, d) {
+				return
+			}
+		}
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// deliver attempts a delivery and stores the outcome, true when it was
+// delivered and stored
+func (s *WebhookService) deliver(ctx context.Context, d repository.WebhookDelivery) bool {
+	now := time.Now()
+	response, err := webhook.Send(ctx, s.client, webhook.Delivery{
+		ID:     strconv.FormatInt(d.ID, 10),
+		Event:  d.Event,
+		URL:    d.URL,
+		Secret: d.Secret,
+		Body:   []byte(d.Payload),
+	}, now)
+	if ctx.Err() != nil {
+		// Shutting down, the attempt does not count
+		return false
+	}
+
+	d.Attempts++
+	d.LastAttemptAt = now.Unix()
+	d.ResponseStatus = response.Status
+	d.Error = ""
+	result := "delivered"
+	switch {
+	case err == nil:
+		d.Status = repository.DeliveryDelivered
+	case d.Attempts >= webhookMaxAttempts:
+		d.Status = repository.DeliveryFailed
+		result = "failed"
+	default:
+		d.NextAttemptAt = now.Add(webhook.Backoff(d.Attempts, webhookRetryBase, webhookRetryMax)).Unix()
+		result = "retry"
+	}
+	if err != nil {
+		d.Error = err.Error()
+		if response.Body != "" {
+			d.Error += ": " + response.Body
+		}
+		slog.Warn("Webhook delivery failed", "webhook", d.WebhookID, "delivery", d.ID, "attempts", d.Attempts, "error", err)
+	}
+	webhookDeliveries.Inc(result)
+
+	if err := s.repo.Webhooks.UpdateDelivery(d); err != nil {
+		slog.Error("Failed to store webhook delivery", "delivery", d.ID, "error", err)
+		return false
+	}
+	return err == nil
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/janschill/track-me/internal/repository"
+)
+
+func TestDeliverDue(t *testing.T) {
+	repo := newTestRepository(t)
+	var deadRequests, healthyRequests atomic.Int64
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadRequests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer dead.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		healthyRequests.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer healthy.Close()
+
+	now := time.Now().Unix()
+	var ids []int64
+	for _, url := range []string{dead.URL, healthy.URL} {
+		id, err := repo.Webhooks.Create(repository.Webhook{URL: url, Secret: "secret", Events: []string{WebhookSOS}, CreatedAt: now})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	for range 3 {
+		if err := repo.Webhooks.Enqueue(ids, WebhookSOS, `{"event":"sos"}`, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	NewWebhookService(repo, nil, nil).deliverDue(context.Background())
+
+	// The dead endpoint is tried once per pass, the healthy one gets everything
+	if deadRequests.Load() != 1 || healthyRequests.Load() != 3 {
+		t.Errorf("requests dead %d, healthy %d; expected 1 and 3", deadRequests.Load(), healthyRequests.Load())
+	}
+	due, err := repo.Webhooks.Due(ids[0], now, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 2 {
+		t.Errorf("%d deliveries of the dead endpoint due; expected the 2 that were not tried", len(due))
+	}
+}
@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"github.com/janschill/track-me/internal/repository"
+)
+
+const (
+	// A climb ends once the altitude dropped this many meters below its top
+	climbMaxDescent = 20.0
+	// Shorter or flatter ascents are rollers, not climbs
+	climbMinLength = 500.0
+	climbMinGrade  = 3.0
+	// Distance the max grade is averaged over so GPS noise does not produce 40 % ramps
+	climbGradeWindow = 100.0
+)
+
+// Categories by score, length in meters times average grade in percent, hardest
+// first, with the thresholds Strava uses
+var climbCategories = []struct {
+	Name     string
+	MinScore float64
+}{
+	{"HC", 80000},
+	{"1", 64000},
+	{"2", 32000},
+	{"3", 16000},
+	{"4", 8000},
+}
+
+type Climb struct {
+	Category       string
+	StartLatitude  float64
+	StartLongitude float64
+	EndLatitude    float64
+	EndLongitude   float64
+	Start          int64
+	End            int64
+	LengthInMeters float64
+	ElevationGain  int64
+	AverageGrade   float64
+	MaxGrade       float64
+}
+
+func ClimbCategory(lengthInMeters, averageGrade float64) string {
+	score := lengthInMeters * averageGrade
+	for _, c := range climbCategories {
+		if score >= c.MinScore {
+			return c.Name
+		}
+	}
+	return ""
+}
+
+// DetectClimbs finds sustained ascents that reach at least category 4.
+// A climb runs from its lowest point to its top and may contain short dips.
+func DetectClimbs(events []repository.Event) []Climb {
+	// Same events as for elevation gain, tracking starts carry bogus altitudes
+	points := make([]repository.Event, 0, len(events))
+	for _, e := range events {
+		if e.Altitude >= 0 && e.MessageCode != 10 {
+			points = append(points, e)
+		}
+	}
+	if len(points) < 2 {
+		return nil
+	}
+
+	distances := make([]float64, len(points))
+	for i := 1; i < len(points); i++ {
+		distances[i] = distances[i-1] + haversine(points[i-1].Latitude, points[i-1].Longitude, points[i].Latitude, points[i].Longitude)*1000
+	}
+
+	var climbs []Climb
+	bottom, top := 0, 0
+	for i := 1; i < len(points); i++ {
+		if points[i].Altitude > points[top].Altitude {
+			top = i
+			continue
+		}
+		if points[top].Altitude-points[i].Altitude > climbMaxDescent {
+			if climb, ok := newClimb(points, distances, bottom, top); ok {
+				climbs = append(climbs, climb)
+			}
+			bottom, top = i, i
+			continue
+		}
+		if points[i].Altitude <= points[bottom].Altitude {
+			// A new low before the climb got going, start over from here
+			bottom, top = i, i
+		}
+	}
+	if climb, ok := newClimb(points, distances, bottom, top); ok {
+		climbs = append(climbs, climb)
+	}
+
+	return climbs
+}
+
+func newClimb(points []repository.Event, distances []float64, bottom, top int) (Climb, bool) {
+	length := distances[top] - distances[bottom]
+	if length < climbMinLength {
+		return Climb{}, false
+	}
+	gain := points[top].Altitude - points[bottom].Altitude
+	averageGrade := gain / length * 100
+	if averageGrade < climbMinGrade {
+		return Climb{}, false
+	}
+	category := ClimbCategory(length, averageGrade)
+	if category == "" {
+		return Climb{}, false
+	}
+
+	return Climb{
+		Category:       category,
+		StartLatitude:  points[bottom].Latitude,
+		StartLongitude: points[bottom].Longitude,
+		EndLatitude:    points[top].Latitude,
+		EndLongitude:   points[top].Longitude,
+		Start:          points[bottom].TimeStamp,
+		End:            points[top].TimeStamp,
+		LengthInMeters: length,
+		ElevationGain:  int64(gain),
+		AverageGrade:   averageGrade,
+		MaxGrade:       maxGrade(points, distances, bottom, top, averageGrade),
+	}, true
+}
+
+// maxGrade is the steepest grade over climbGradeWindow meters between bottom and top
+func maxGrade(points []repository.Event, distances []float64, bottom, top int, averageGrade float64) float64 {
+	steepest := averageGrade
+	j := bottom
+	for i := bottom; i < top; i++ {
+		for j < top && distances[j]-distances[i] < climbGradeWindow {
+			j++
+		}
+		if distances[j]-distances[i] < climbGradeWindow {
+			break
+		}
+		grade := (points[j].Altitude - points[i].Altitude) / (distances[j] - distances[i]) * 100
+		if grade > steepest {
+			steepest = grade
+		}
+	}
+	return steepest
+}
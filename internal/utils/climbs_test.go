@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/janschill/track-me/internal/repository"
+)
+
+// profile places events about 100 m apart heading north with the given altitudes
+func profile(altitudes []float64) []repository.Event {
+	events := make([]repository.Event, len(altitudes))
+	for i, a := range altitudes {
+		events[i] = repository.Event{
+			Latitude:  47.0 + float64(i)*0.0009,
+			Longitude: -113.0,
+			Altitude:  a,
+			TimeStamp: int64(i * 30),
+		}
+	}
+	return events
+}
+
+func TestClimbCategory(t *testing.T) {
+	tests := []struct {
+		length   float64
+		grade    float64
+		expected string
+	}{
+		{1000, 5, ""},
+		{2000, 5, "4"},
+		{4000, 5, "3"},
+		{8000, 5, "2"},
+		{10000, 5, "2"},
+		{13000, 5, "1"},
+		{16000, 5, "HC"},
+		{20000, 6, "HC"},
+	}
+
+	for _, tt := range tests {
+		if result := ClimbCategory(tt.length, tt.grade); result != tt.expected {
+			t.Errorf("ClimbCategory(%v, %v) = %q; expected %q", tt.length, tt.grade, result, tt.expected)
+		}
+	}
+}
+
+func TestDetectClimbs(t *testing.T) {
+	// Flat approach, 3 km at 6 % with a 10 m dip, 1 km descent, flat again
+	var altitudes []float64
+	for i := 0; i < 10; i++ {
+		altitudes = append(altitudes, 1000)
+	}
+	for i := 1; i <= 30; i++ {
+		a := 1000 + float64(i)*6
+		if i == 15 {
+			a -= 10
+		}
+		if i == 20 {
+			// One steep ramp
+			a += 12
+		}
+		altitudes = append(altitudes, a)
+	}
+	for i := 1; i <= 10; i++ {
+		altitudes = append(altitudes, 1180-float64(i)*15)
+	}
+	for i := 0; i < 10; i++ {
+		altitudes = append(altitudes, 1030)
+	}
+
+	climbs := DetectClimbs(profile(altitudes))
+	if len(climbs) != 1 {
+		t.Fatalf("DetectClimbs() returned %d climbs; expected 1", len(climbs))
+	}
+
+	c := climbs[0]
+	if c.ElevationGain != 180 {
+		t.Errorf("ElevationGain = %d; expected 180", c.ElevationGain)
+	}
+	if c.LengthInMeters < 2950 || c.LengthInMeters > 3050 {
+		t.Errorf("LengthInMeters = %v; expected about 3000", c.LengthInMeters)
+	}
+	if c.AverageGrade < 5.9 || c.AverageGrade > 6.1 {
+		t.Errorf("AverageGrade = %v; expected about 6", c.AverageGrade)
+	}
+	if c.MaxGrade < 17.9 || c.MaxGrade > 18.1 {
+		t.Errorf("MaxGrade = %v; expected about 18", c.MaxGrade)
+	}
+	if c.Category != "3" {
+		t.Errorf("Category = %q; expected 3", c.Category)
+	}
+	if c.Start != 9*30 || c.End != 39*30 {
+		t.Errorf("Start, End = %d, %d; expected %d, %d", c.Start, c.End, 9*30, 39*30)
+	}
+}
+
+func TestDetectClimbsIgnoresRollers(t *testing.T) {
+	// 25 m up and down again, over and over
+	var altitudes []float64
+	for i := 0; i < 50; i++ {
+		altitudes = append(altitudes, 1000+float64(i%6)*5)
+	}
+
+	if climbs := DetectClimbs(profile(altitudes)); len(climbs) != 0 {
+		t.Errorf("DetectClimbs() returned %d climbs; expected 0", len(climbs))
+	}
+	if climbs := DetectClimbs(nil); len(climbs) != 0 {
+		t.Errorf("DetectClimbs(nil) returned %d climbs; expected 0", len(climbs))
+	}
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"math"
+
+	"github.com/janschill/track-me/internal/repository"
+)
+
+type FilterOptions struct {
+	// Events with a gpsFix below this are dropped, 0 keeps all
+	MinGpsFix int
+	// Maximum plausible speed between two events in m/s
+	MaxSpeed float64
+	// Maximum plausible change of speed between two segments in m/s²
+	MaxAcceleration float64
+	// When this many consistent events in a row contradict the first fix,
+	// the first fix is dropped instead so it cannot swallow the whole track
+	MaxConsecutiveRejections int
+	// Smooth the remaining positions with a Kalman filter
+	Smooth bool
+	// Expected position error of a single fix in meters
+	KalmanAccuracy float64
+	// How fast the position is expected to change in m/s
+	KalmanProcessNoise float64
+}
+
+// Cycling filter: nobody on a loaded bike is faster than 90 km/h
+var CyclingFilter = FilterOptions{
+	MinGpsFix:                2,
+	MaxSpeed:                 25,
+	MaxAcceleration:          4,
+	MaxConsecutiveRejections: 3,
+	Smooth:                   false,
+	KalmanAccuracy:           10,
+	KalmanProcessNoise:       8,
+}
+
+// FilterEvents drops poor fixes and implausible jumps and optionally smooths the track.
+// The input slice is not modified.
+func FilterEvents(events []repository.Event, options FilterOptions) []repository.Event {
+	filtered := DropPoorFixes(events, options.MinGpsFix)
+	filtered = DropImplausible(filtered, options)
+	if options.Smooth {
+		filtered = KalmanSmooth(filtered, options.KalmanAccuracy, options.KalmanProcessNoise)
+	}
+	return filtered
+}
+
+func DropPoorFixes(events []repository.Event, minGpsFix int) []repository.Event {
+	filtered := make([]repository.Event, 0, len(events))
+	for _, e := range events {
+		if e.GpsFix >= minGpsFix {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+// DropImplausible removes events that could only be reached with an implausible
+// speed or acceleration from the last accepted event. After a real jump, e.g. a
+// gap in the recording, the speed becomes plausible again as time passes.
+func DropImplausible(events []repository.Event, options FilterOptions) []repository.Event {
+	if len(events) < 2 {
+		return events
+	}
+
+	filtered := []repository.Event{events[0]}
+	var rejected []repository.Event
+	lastSpeed := -1.0
+
+	for i := 1; i < len(events); i++ {
+		prev := filtered[len(filtered)-1]
+		e := events[i]
+
+		dt := float64(e.TimeStamp - prev.TimeStamp)
+		if dt <= 0 {
+			// Duplicate or out of order timestamp
+			continue
+		}
+
+		speed := haversine(prev.Latitude, prev.Longitude, e.Latitude, e.Longitude) * 1000 / dt
+		plausible := options.MaxSpeed <= 0 || speed <= options.MaxSpeed
+		if plausible && options.MaxAcceleration > 0 && lastSpeed >= 0 {
+			plausible = math.Abs(speed-lastSpeed)/dt <= options.MaxAcceleration
+		}
+
+		if !plausible {
+			rejected = append(rejected, e)
+			if len(filtered) == 1 && len(rejected) > options.MaxConsecutiveRejections && plausibleTrack(rejected, options.MaxSpeed) {
+				// The first fix was the outlier
+				filtered = rejected
+				rejected = nil
+				lastSpeed = -1
+			}
+			continue
+		}
+
+		filtered = append(filtered, e)
+		rejected = nil
+		lastSpeed = speed
+	}
+
+	return filtered
+}
+
+func plausibleTrack(events []repository.Event, maxSpeed float64) bool {
+	for i := 1; i < len(events); i++ {
+		dt := float64(events[i].TimeStamp - events[i-1].TimeStamp)
+		if dt <= 0 {
+			return false
+		}
+		speed := haversine(events[i-1].Latitude, events[i-1].Longitude, events[i].Latitude, events[i].Longitude) * 1000 / dt
+		if maxSpeed > 0 && speed > maxSpeed {
+			return false
+		}
+	}
+	return true
+}
+
+// KalmanSmooth applies a constant position Kalman filter to latitude and longitude.
+// accuracy is the measurement error in meters, processNoise the expected movement in m/s.
+func KalmanSmooth(events []repository.Event, accuracy, processNoise float64) []repository.Event {
+	smoothed := make([]repository.Event, len(events))
+	copy(smoothed, events)
+	if len(smoothed) == 0 {
+		return smoothed
+	}
+
+	measurementVariance := accuracy * accuracy
+	variance := measurementVariance
+	lat, lng := smoothed[0].Latitude, smoothed[0].Longitude
+
+	for i := 1; i < len(smoothed); i++ {
+		dt := float64(smoothed[i].TimeStamp - smoothed[i-1].TimeStamp)
+		if dt > 0 {
+			variance += dt * processNoise * processNoise
+		}
+
+		gain := variance / (variance + measurementVariance)
+		lat += gain * (smoothed[i].Latitude - lat)
+		lng += gain * (smoothed[i].Longitude - lng)
+		variance = (1 - gain) * variance
+
+		smoothed[i].Latitude = lat
+		smoothed[i].Longitude = lng
+	}
+
+	return smoothed
+}
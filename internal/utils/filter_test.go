@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"math"
+	"testing"
+
+	"github.com/janschill/track-me/internal/repository"
+)
+
+func withGpsFix(events []repository.Event, fix int) []repository.Event {
+	for i := range events {
+		events[i].GpsFix = fix
+	}
+	return events
+}
+
+// withOutliers teleports every nth event half a degree north
+func withOutliers(events []repository.Event, n int) []repository.Event {
+	noisy := make([]repository.Event, len(events))
+	copy(noisy, events)
+	for i := n; i < len(noisy); i += n {
+		noisy[i].Latitude += 0.5
+	}
+	return noisy
+}
+
+func TestDropPoorFixes(t *testing.T) {
+	events := []repository.Event{{GpsFix: 0}, {GpsFix: 1}, {GpsFix: 2}, {GpsFix: 3}}
+
+	filtered := DropPoorFixes(events, 2)
+	if len(filtered) != 2 || filtered[0].GpsFix != 2 {
+		t.Errorf("DropPoorFixes() = %+v; expected fixes 2 and 3", filtered)
+	}
+}
+
+func TestDropImplausible(t *testing.T) {
+	events := []repository.Event{
+		{Latitude: 47.0, Longitude: -113.0, TimeStamp: 0},
+		{Latitude: 47.001, Longitude: -113.0, TimeStamp: 60},
+		{Latitude: 48.0, Longitude: -113.0, TimeStamp: 120}, // teleport
+		{Latitude: 47.002, Longitude: -113.0, TimeStamp: 180},
+		{Latitude: 47.002, Longitude: -113.0, TimeStamp: 180}, // duplicate
+		{Latitude: 47.003, Longitude: -113.0, TimeStamp: 240},
+	}
+
+	filtered := DropImplausible(events, CyclingFilter)
+	if len(filtered) != 4 {
+		t.Fatalf("DropImplausible() returned %d events; expected 4", len(filtered))
+	}
+	for _, e := range filtered {
+		if e.Latitude == 48.0 {
+			t.Errorf("DropImplausible() kept the teleported event")
+		}
+	}
+}
+
+func TestDropImplausibleRecovers(t *testing.T) {
+	// The first fix is far off, the filter has to give up on it eventually
+	events := []repository.Event{{Latitude: 10.0, TimeStamp: 0}}
+	for i := 1; i <= 10; i++ {
+		events = append(events, repository.Event{Latitude: 47.0 + float64(i)*0.001, TimeStamp: int64(i * 60)})
+	}
+
+	filtered := DropImplausible(events, CyclingFilter)
+	if len(filtered) < 7 {
+		t.Errorf("DropImplausible() returned %d events; expected the track after the bad first fix", len(filtered))
+	}
+}
+
+func TestKalmanSmooth(t *testing.T) {
+	events := []repository.Event{
+		{Latitude: 47.0, TimeStamp: 0},
+		{Latitude: 47.0001, TimeStamp: 1},
+		{Latitude: 46.9999, TimeStamp: 2},
+		{Latitude: 47.0001, TimeStamp: 3},
+	}
+
+	smoothed := KalmanSmooth(events, 10, 1)
+	if len(smoothed) != len(events) {
+		t.Fatalf("KalmanSmooth() returned %d events; expected %d", len(smoothed), len(events))
+	}
+	if DistanceInMeters(smoothed) >= DistanceInMeters(events) {
+		t.Errorf("KalmanSmooth() distance %f; expected less than raw %f", DistanceInMeters(smoothed), DistanceInMeters(events))
+	}
+	if events[1].Latitude != 47.0001 {
+		t.Errorf("KalmanSmooth() modified its input")
+	}
+}
+
+func TestFilterEventsOnRides(t *testing.T) {
+	walkTestFiles(t, func(path string, data GPXData) {
+		expectedDistance := parseFloat(t, data.Distance)
+		relativeThreshold := 0.05
+
+		clean := withGpsFix(ConvertPointsToEvents(data.Points), 3)
+		noisy := withOutliers(clean, 200)
+
+		// Before: a handful of bad fixes ruin distance and max speed
+		rawDistance := DistanceInMeters(noisy)
+		if math.Abs(rawDistance-expectedDistance) <= relativeThreshold*expectedDistance {
+			t.Errorf("DistanceInMeters(noisy) = %f; expected outliers to distort it (file: %s)", rawDistance, path)
+		}
+
+		// After: filtering brings the distance back and keeps max speed plausible
+		filtered := FilterEvents(noisy, CyclingFilter)
+		distance := DistanceInMeters(filtered)
+		if math.Abs(distance-expectedDistance) > relativeThreshold*expectedDistance {
+			t.Errorf("DistanceInMeters(filtered) = %f; expected %f (file: %s)", distance, expectedDistance, path)
+		}
+		_, _, maxSpeed := CalculateMovingTimeAndAverageSpeed(filtered, 0.001)
+		if maxSpeed*1000 > CyclingFilter.MaxSpeed {
+			t.Errorf("max speed after filtering = %f m/s; expected at most %f (file: %s)", maxSpeed*1000, CyclingFilter.MaxSpeed, path)
+		}
+
+		// Clean rides are not changed noticeably
+		distance = DistanceInMeters(FilterEvents(clean, CyclingFilter))
+		if math.Abs(distance-expectedDistance) > relativeThreshold*expectedDistance {
+			t.Errorf("DistanceInMeters(clean filtered) = %f; expected %f (file: %s)", distance, expectedDistance, path)
+		}
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/janschill/track-me/internal/i18n"
 	"github.com/janschill/track-me/internal/repository"
 )
 
@@ -28,23 +29,21 @@ func FormatTime(seconds int64) string {
 	return fmt.Sprintf("%02d:%02d:%02d", hours, minutes, seconds)
 }
 
-func OnDay(ts int64) string {
-	t := time.Unix(ts, 0).UTC()
-	return t.Format("02 January")
+func OnDay(ts int64, loc *time.Location, l *i18n.Locale) string {
+	return l.Day(time.Unix(ts, 0).In(loc))
 }
 
-func WroteOnTime(ts int64) string {
-	t := time.Unix(ts, 0).UTC()
-	return t.Format("on 02 January at 15:04")
+func WroteOnTime(ts int64, loc *time.Location, l *i18n.Locale) string {
+	return l.DateTime(time.Unix(ts, 0).In(loc))
 }
 
-func OnDayFromString(dateStr string) (string, error) {
+func OnDayFromString(dateStr string, l *i18n.Locale) (string, error) {
 	t, err := time.Parse("2006-01-02", dateStr)
 	if err != nil {
 		return "", err
 	}
 
-	return t.Format("02 January"), nil
+	return l.Day(t), nil
 }
 
 func FindKudos(kudos []repository.Kudos, day string) int {
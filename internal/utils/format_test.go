@@ -2,6 +2,10 @@ package utils
 
 import (
 	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/janschill/track-me/internal/i18n"
 )
 
 func TestOneDecimal(t *testing.T) {
@@ -67,19 +71,29 @@ func TestFormatTime(t *testing.T) {
 	}
 }
 
+var (
+	denver, _ = time.LoadLocation("America/Denver")
+	german, _ = i18n.Get("de")
+)
+
 func TestOnDay(t *testing.T) {
 	tests := []struct {
 		name     string
 		ts       int64
+		loc      *time.Location
+		locale   *i18n.Locale
 		expected string
 	}{
-		{"New Year's Day 2020", 1577836800, "01 January"},
-		{"Leap Day 2020", 1582934400, "29 February"},
+		{"New Year's Day 2020", 1577836800, time.UTC, i18n.English, "01 January"},
+		{"Leap Day 2020", 1582934400, time.UTC, i18n.English, "29 February"},
+		{"New Year's Eve in Denver", 1577836800, denver, i18n.English, "31 December"},
+		{"Leap Day 2020 in German", 1582934400, time.UTC, german, "29. Februar"},
+		{"Spring in German", 1584057600, time.UTC, german, "13. März"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := OnDay(tt.ts)
+			result := OnDay(tt.ts, tt.loc, tt.locale)
 			if result != tt.expected {
 				t.Errorf("OnDay(%d) = %s, want %s", tt.ts, result, tt.expected)
 			}
@@ -91,14 +105,18 @@ func TestWroteOnTime(t *testing.T) {
 	tests := []struct {
 		name     string
 		ts       int64
+		loc      *time.Location
+		locale   *i18n.Locale
 		expected string
 	}{
-		{"Example timestamp", 1609459200, "on 01 January at 00:00"},
+		{"Example timestamp", 1609459200, time.UTC, i18n.English, "on 01 January at 00:00"},
+		{"Example timestamp in Denver", 1609459200, denver, i18n.English, "on 31 December at 17:00"},
+		{"Example timestamp in German", 1609459200, denver, german, "am 31. Dezember um 17:00"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := WroteOnTime(tt.ts)
+			result := WroteOnTime(tt.ts, tt.loc, tt.locale)
 			if result != tt.expected {
 				t.Errorf("WroteOnTime(%d) = %s, want %s", tt.ts, result, tt.expected)
 			}
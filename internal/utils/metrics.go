@@ -69,6 +69,8 @@ func haversine(lat1, lon1, lat2, lon2 float64) (km float64) {
 
 // Ramer–Douglas–Peucker algorithm
 // Used to decimate a curve composed of line segments to a similar curve with fewer points
+//
+// Deprecated: epsilon is in degrees, so the tolerance changes with latitude. Use Simplify.
 func Rdp(events []repository.Event, epsilon float64) []repository.Event {
 	if len(events) < 3 {
 		return events
@@ -173,30 +175,89 @@ func CalculateAltitudes(events []repository.Event) (averageAltitude, maxAltitude
 	return averageAltitude, maxAltitude, minAltitude
 }
 
+// Altitude changes smaller than this are treated as GPS noise
+const DefaultElevationThreshold = 5.0
+
 func CalculateElevationGainAndLoss(events []repository.Event) (elevationGain, elevationLoss int64) {
+	return CalculateElevationGainAndLossWithThreshold(events, DefaultElevationThreshold)
+}
+
+// Hysteresis: gain and loss are only counted once the altitude moved at least
+// threshold meters away from the last reference altitude, so wiggles cancel out
+func CalculateElevationGainAndLossWithThreshold(events []repository.Event, threshold float64) (elevationGain, elevationLoss int64) {
 	if len(events) < 2 {
 		return 0, 0
 	}
 
-	i := 0
-	for j := 1; j < len(events); j++ {
+	var gain, loss float64
+	reference := math.NaN()
+	for _, event := range events {
 		// Message Code 10 announces a tracking start
 		// Usually the altitude on these events are quite off
-		if events[j].Altitude < 0 || events[j].MessageCode == 10 {
+		if event.Altitude < 0 || event.MessageCode == 10 {
 			continue
 		}
 
-		if events[i].Altitude >= 0 && events[i].MessageCode != 10 {
-			altitudeDiff := events[j].Altitude - events[i].Altitude
-			if altitudeDiff > 0 {
-				elevationGain += int64(altitudeDiff)
-			} else {
-				elevationLoss -= int64(altitudeDiff)
-			}
+		if math.IsNaN(reference) {
+			reference = event.Altitude
+			continue
+		}
+
+		altitudeDiff := event.Altitude - reference
+		if altitudeDiff >= threshold && altitudeDiff > 0 {
+			gain += altitudeDiff
+			reference = event.Altitude
+		} else if -altitudeDiff >= threshold && altitudeDiff < 0 {
+			loss -= altitudeDiff
+			reference = event.Altitude
+		}
+	}
+
+	return int64(gain), int64(loss)
+}
+
+// WithCorrectedAltitudes uses the DEM corrected altitude of every event that has one
+func WithCorrectedAltitudes(events []repository.Event) []repository.Event {
+	corrected := make([]repository.Event, len(events))
+	copy(corrected, events)
+	for i := range corrected {
+		if corrected[i].CorrectedAltitude != nil {
+			corrected[i].Altitude = *corrected[i].CorrectedAltitude
+		}
+	}
+	return corrected
+}
+
+type Stop struct {
+	Latitude  float64
+	Longitude float64
+	Start     int64
+	End       int64
+}
+
+// Finds places where consecutive events stay within radius (meters) of the
+// first one for at least minDuration seconds
+func CalculateStops(events []repository.Event, radius float64, minDuration int64) []Stop {
+	var stops []Stop
+
+	i := 0
+	for i < len(events) {
+		j := i + 1
+		for j < len(events) && haversine(events[i].Latitude, events[i].Longitude, events[j].Latitude, events[j].Longitude)*1000 <= radius {
+			j++
+		}
+
+		if events[j-1].TimeStamp-events[i].TimeStamp >= minDuration {
+			stops = append(stops, Stop{
+				Latitude:  events[i].Latitude,
+				Longitude: events[i].Longitude,
+				Start:     events[i].TimeStamp,
+				End:       events[j-1].TimeStamp,
+			})
 		}
 
 		i = j
 	}
 
-	return elevationGain, elevationLoss
+	return stops
 }
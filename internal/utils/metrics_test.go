@@ -30,6 +30,14 @@ func walkTestFiles(t *testing.T, fileHandler func(path string, data GPXData)) {
 	}
 }
 
+func parseFloat(t *testing.T, s string) float64 {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		t.Fatalf("Failed to parse %q: %v", s, err)
+	}
+	return f
+}
+
 func TestDistanceInMeters(t *testing.T) {
 	walkTestFiles(t, func(path string, data GPXData) {
 		expectedDistance, err := strconv.ParseFloat(data.Distance, 64)
@@ -192,3 +200,57 @@ func TestCalculateElevationGainAndLoss(t *testing.T) {
 		})
 	}
 }
+
+func TestCalculateStops(t *testing.T) {
+	events := []repository.Event{
+		{Latitude: 47.0, Longitude: -113.0, TimeStamp: 0},
+		{Latitude: 47.01, Longitude: -113.0, TimeStamp: 600},
+		{Latitude: 47.0101, Longitude: -113.0, TimeStamp: 1200},
+		{Latitude: 47.0102, Longitude: -113.0, TimeStamp: 2400},
+		{Latitude: 47.02, Longitude: -113.0, TimeStamp: 3000},
+	}
+
+	stops := CalculateStops(events, 50, 900)
+	if len(stops) != 1 {
+		t.Fatalf("CalculateStops() returned %d stops; expected 1", len(stops))
+	}
+	if stops[0].Start != 600 || stops[0].End != 2400 {
+		t.Errorf("CalculateStops() stop = %+v; expected start 600 and end 2400", stops[0])
+	}
+
+	if stops := CalculateStops(nil, 50, 900); len(stops) != 0 {
+		t.Errorf("CalculateStops(nil) returned %d stops; expected 0", len(stops))
+	}
+}
+
+func TestCalculateElevationGainAndLossWithThreshold(t *testing.T) {
+	// Climbing 100 m with +-2 m of GPS noise on the way
+	altitudes := []float64{1000, 1002, 1000, 1003, 1001, 1020, 1018, 1050, 1048, 1100, 1098, 1100}
+	events := make([]repository.Event, len(altitudes))
+	for i, a := range altitudes {
+		events[i] = repository.Event{Altitude: a}
+	}
+
+	gain, loss := CalculateElevationGainAndLossWithThreshold(events, 0)
+	if gain <= 100 || loss == 0 {
+		t.Errorf("without threshold gain = %d, loss = %d; expected noise to add up", gain, loss)
+	}
+
+	gain, loss = CalculateElevationGainAndLossWithThreshold(events, 5)
+	if gain != 100 || loss != 0 {
+		t.Errorf("with threshold gain = %d, loss = %d; expected 100 and 0", gain, loss)
+	}
+}
+
+func TestWithCorrectedAltitudes(t *testing.T) {
+	corrected := 1234.0
+	events := []repository.Event{{Altitude: 1000, CorrectedAltitude: &corrected}, {Altitude: 900}}
+
+	result := WithCorrectedAltitudes(events)
+	if result[0].Altitude != 1234 || result[1].Altitude != 900 {
+		t.Errorf("WithCorrectedAltitudes() = %v, %v; expected 1234, 900", result[0].Altitude, result[1].Altitude)
+	}
+	if events[0].Altitude != 1000 {
+		t.Errorf("WithCorrectedAltitudes() modified its input")
+	}
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"math"
+
+	"github.com/janschill/track-me/internal/repository"
+)
+
+const (
+	// Speed is averaged over at least this many seconds
+	profileSpeedWindow = 60
+	// Grade is averaged over at least this many meters
+	profileGradeWindow = 100.0
+	// Longer gaps between events are breaks, e.g. the tracker was off overnight
+	profileMaxGap = 30 * 60
+)
+
+// Band limits in km/h, mph and percent, the outer bands are open ended
+var (
+	SpeedBands         = []float64{5, 10, 15, 20, 25, 30}
+	ImperialSpeedBands = []float64{3, 6, 9, 12, 15, 20}
+	GradeBands         = []float64{-10, -6, -3, -1, 1, 3, 6, 10}
+)
+
+type ProfilePoint struct {
+	TimeStamp int64   `json:"timeStamp"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// Meters, meters and km/h unless converted to another unit system
+	Distance float64 `json:"distance"` // since the first event
+	Altitude float64 `json:"altitude"`
+	Speed    float64 `json:"speed"`
+	Grade    float64 `json:"grade"` // percent
+}
+
+// Band is the time spent with a value in [From, To). From or To are nil for open ends.
+type Band struct {
+	From    *float64 `json:"from"`
+	To      *float64 `json:"to"`
+	Seconds int64    `json:"seconds"`
+}
+
+// Profile indexes the events by distance and adds a speed and grade averaged over
+// a trailing window to every point. Events should be filtered beforehand.
+func Profile(events []repository.Event) []ProfilePoint {
+	points := make([]ProfilePoint, len(events))
+	for i, e := range events {
+		points[i] = ProfilePoint{
+			TimeStamp: e.TimeStamp,
+			Latitude:  e.Latitude,
+			Longitude: e.Longitude,
+			Altitude:  e.Altitude,
+		}
+		if i > 0 {
+			prev := events[i-1]
+			points[i].Distance = points[i-1].Distance + haversine(prev.Latitude, prev.Longitude, e.Latitude, e.Longitude)*1000
+		}
+	}
+
+	speedStart, gradeStart := 0, 0
+	for i := 1; i < len(points); i++ {
+		if points[i].TimeStamp-points[i-1].TimeStamp > profileMaxGap {
+			// Do not average across a break
+			speedStart, gradeStart = i, i
+			continue
+		}
+
+		for speedStart < i-1 && points[i].TimeStamp-points[speedStart+1].TimeStamp >= profileSpeedWindow {
+			speedStart++
+		}
+		if dt := points[i].TimeStamp - points[speedStart].TimeStamp; dt > 0 {
+			points[i].Speed = (points[i].Distance - points[speedStart].Distance) / float64(dt) * 3.6
+		}
+
+		for gradeStart < i-1 && points[i].Distance-points[gradeStart+1].Distance >= profileGradeWindow {
+			gradeStart++
+		}
+		if dd := points[i].Distance - points[gradeStart].Distance; dd >= profileGradeWindow/10 {
+			points[i].Grade = (points[i].Altitude - points[gradeStart].Altitude) / dd * 100
+		}
+	}
+
+	return points
+}
+
+// TimeInBands sums the seconds between consecutive points into the band of the later
+// point's value. Breaks longer than profileMaxGap are not counted.
+func TimeInBands(points []ProfilePoint, bounds []float64, value func(ProfilePoint) float64) []Band {
+	bands := make([]Band, len(bounds)+1)
+	for i := range bands {
+		if i > 0 {
+			bands[i].From = &bounds[i-1]
+		}
+		if i < len(bounds) {
+			bands[i].To = &bounds[i]
+		}
+	}
+
+	for i := 1; i < len(points); i++ {
+		dt := points[i].TimeStamp - points[i-1].TimeStamp
+		if dt <= 0 || dt > profileMaxGap {
+			continue
+		}
+		v := value(points[i])
+		if math.IsNaN(v) {
+			continue
+		}
+		band := 0
+		for band < len(bounds) && v >= bounds[band] {
+			band++
+		}
+		bands[band].Seconds += dt
+	}
+
+	return bands
+}
+
+// MovingTimeInSpeedBands leaves out time spent stopped
+func MovingTimeInSpeedBands(points []ProfilePoint, bounds []float64) []Band {
+	return TimeInBands(points, bounds, func(p ProfilePoint) float64 {
+		if p.Speed < 1 {
+			return math.NaN()
+		}
+		return p.Speed
+	})
+}
+
+// MovingTimeInGradeBands leaves out time spent stopped
+func MovingTimeInGradeBands(points []ProfilePoint) []Band {
+	return TimeInBands(points, GradeBands, func(p ProfilePoint) float64 {
+		if p.Speed < 1 {
+			return math.NaN()
+		}
+		return p.Grade
+	})
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestProfile(t *testing.T) {
+	// 100 m every 30 s, first flat then 5 % up
+	altitudes := []float64{1000, 1000, 1000, 1000, 1005, 1010, 1015, 1020}
+	events := profile(altitudes)
+	// A night without events
+	for i := range events[6:] {
+		events[6+i].TimeStamp += 8 * 3600
+	}
+
+	points := Profile(events)
+	if len(points) != len(events) {
+		t.Fatalf("Profile() returned %d points; expected %d", len(points), len(events))
+	}
+	if math.Abs(points[7].Distance-700) > 2 {
+		t.Errorf("Distance = %v; expected about 700", points[7].Distance)
+	}
+	if math.Abs(points[3].Speed-12) > 0.1 {
+		t.Errorf("Speed = %v; expected about 12 km/h", points[3].Speed)
+	}
+	if math.Abs(points[2].Grade) > 0.01 || math.Abs(points[5].Grade-5) > 0.1 {
+		t.Errorf("Grade = %v, %v; expected 0 and about 5", points[2].Grade, points[5].Grade)
+	}
+	if points[6].Speed != 0 || points[6].Grade != 0 {
+		t.Errorf("Speed, Grade after a break = %v, %v; expected 0, 0", points[6].Speed, points[6].Grade)
+	}
+}
+
+func TestTimeInBands(t *testing.T) {
+	points := []ProfilePoint{
+		{TimeStamp: 0},
+		{TimeStamp: 60, Speed: 3},
+		{TimeStamp: 120, Speed: 12},
+		{TimeStamp: 180, Speed: 40},
+		{TimeStamp: 240, Speed: 0.5},
+		{TimeStamp: 10000, Speed: 12},
+	}
+
+	bands := MovingTimeInSpeedBands(points, SpeedBands)
+	if len(bands) != len(SpeedBands)+1 {
+		t.Fatalf("MovingTimeInSpeedBands() returned %d bands; expected %d", len(bands), len(SpeedBands)+1)
+	}
+	expected := []int64{60, 0, 60, 0, 0, 0, 60}
+	for i, band := range bands {
+		if band.Seconds != expected[i] {
+			t.Errorf("band %d Seconds = %d; expected %d", i, band.Seconds, expected[i])
+		}
+	}
+	if bands[0].From != nil || *bands[0].To != 5 || *bands[6].From != 30 || bands[6].To != nil {
+		t.Errorf("MovingTimeInSpeedBands() band limits are wrong")
+	}
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"math"
+
+	"github.com/janschill/track-me/pkg/gpx"
+)
+
+type routePoint struct {
+	latitude, longitude float64
+	// Meters from the start of the route
+	distance float64
+}
+
+// Route is a planned route indexed by the distance along it
+type Route struct {
+	points []routePoint
+}
+
+func NewRoute(points []gpx.Point) *Route {
+	route := &Route{points: make([]routePoint, len(points))}
+	for i, p := range points {
+		route.points[i] = routePoint{latitude: p.Latitude, longitude: p.Longitude}
+		if i > 0 {
+			prev := route.points[i-1]
+			route.points[i].distance = prev.distance + haversine(prev.latitude, prev.longitude, p.Latitude, p.Longitude)*1000
+		}
+	}
+	return route
+}
+
+// Length returns the length of the route in meters
+func (r *Route) Length() float64 {
+	if len(r.points) == 0 {
+		return 0
+	}
+	return r.points[len(r.points)-1].distance
+}
+
+// Locate projects a position onto the closest segment of the route. It returns
+// the meters along the route to that point and how far off the route the position is.
+// Segments are short enough to be treated as flat.
+func (r *Route) Locate(lat, lon float64) (along, offRoute float64) {
+	if len(r.points) == 0 {
+		return 0, math.Inf(1)
+	}
+	if len(r.points) == 1 {
+		return 0, haversine(r.points[0].latitude, r.points[0].longitude, lat, lon) * 1000
+	}
+
+	metersPerDegree := earthRadiusKm * 1000 * math.Pi / 180
+	offRoute = math.Inf(1)
+	for i := 1; i < len(r.points); i++ {
+		a, b := r.points[i-1], r.points[i]
+		// Local coordinates in meters with a at the origin
+		scale := math.Cos(degreesToRadians(a.latitude))
+		bx, by := (b.longitude-a.longitude)*scale*metersPerDegree, (b.latitude-a.latitude)*metersPerDegree
+		px, py := (lon-a.longitude)*scale*metersPerDegree, (lat-a.latitude)*metersPerDegree
+
+		t := 0.0
+		if length := bx*bx + by*by; length > 0 {
+			t = math.Max(0, math.Min(1, (px*bx+py*by)/length))
+		}
+		dx, dy := px-t*bx, py-t*by
+		if d := math.Sqrt(dx*dx + dy*dy); d < offRoute {
+			offRoute = d
+			along = a.distance + t*(b.distance-a.distance)
+		}
+	}
+	return along, offRoute
+}
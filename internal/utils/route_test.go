@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"math"
+	"testing"
+
+	"github.com/janschill/track-me/pkg/gpx"
+)
+
+// A route heading north for about 2 km, then east for about 1.3 km
+var testRoute = NewRoute([]gpx.Point{
+	{Latitude: 47.00, Longitude: -113.00},
+	{Latitude: 47.01, Longitude: -113.00},
+	{Latitude: 47.02, Longitude: -113.00},
+	{Latitude: 47.02, Longitude: -112.98},
+})
+
+func TestRouteLength(t *testing.T) {
+	expected := 2*1111.95 + 1516.5
+	if result := testRoute.Length(); math.Abs(result-expected) > 1 {
+		t.Errorf("Length() = %v; expected %v", result, expected)
+	}
+	if result := NewRoute(nil).Length(); result != 0 {
+		t.Errorf("Length() of an empty route = %v; expected 0", result)
+	}
+}
+
+func TestRouteLocate(t *testing.T) {
+	tests := []struct {
+		name             string
+		lat, lon         float64
+		expectedAlong    float64
+		expectedOffRoute float64
+	}{
+		{"start", 47.00, -113.00, 0, 0},
+		{"on the first segment", 47.005, -113.00, 556, 0},
+		{"beside the second segment", 47.015, -112.999, 1668, 76},
+		{"on the corner", 47.02, -113.00, 2224, 0},
+		{"on the last segment", 47.02, -112.99, 2982, 0},
+		{"before the start", 46.99, -113.00, 0, 1112},
+		{"past the end", 47.02, -112.97, 3740, 758},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			along, offRoute := testRoute.Locate(tt.lat, tt.lon)
+			if math.Abs(along-tt.expectedAlong) > 2 || math.Abs(offRoute-tt.expectedOffRoute) > 2 {
+				t.Errorf("Locate(%v, %v) = %.0f, %.0f; expected %.0f, %.0f", tt.lat, tt.lon, along, offRoute, tt.expectedAlong, tt.expectedOffRoute)
+			}
+		})
+	}
+}
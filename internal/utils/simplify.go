@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"math"
+
+	"github.com/janschill/track-me/internal/repository"
+)
+
+// segmentDistance returns the distance in meters between an event and the segment start-end.
+// Positions are projected onto a plane tangent at the segment start, which is accurate
+// for the few kilometers between two track points at any latitude.
+func segmentDistance(event, start, end repository.Event) float64 {
+	metersPerDegree := earthRadiusKm * 1000 * math.Pi / 180
+	cosLat := math.Cos(degreesToRadians(start.Latitude))
+	project := func(e repository.Event) (x, y float64) {
+		return (e.Longitude - start.Longitude) * metersPerDegree * cosLat, (e.Latitude - start.Latitude) * metersPerDegree
+	}
+
+	px, py := project(event)
+	ex, ey := project(end)
+
+	lengthSquared := ex*ex + ey*ey
+	if lengthSquared == 0 {
+		return math.Hypot(px, py)
+	}
+
+	// Position of the closest point along the segment, clamped to its ends
+	t := math.Max(0, math.Min(1, (px*ex+py*ey)/lengthSquared))
+	return math.Hypot(px-t*ex, py-t*ey)
+}
+
+// Simplify decimates a track with the Ramer–Douglas–Peucker algorithm.
+// epsilon is the maximum distance in meters a removed event may have from the simplified track.
+// It works with an explicit stack, so long tracks cannot overflow the call stack.
+func Simplify(events []repository.Event, epsilon float64) []repository.Event {
+	if len(events) < 3 {
+		return events
+	}
+
+	keep := make([]bool, len(events))
+	keep[0] = true
+	keep[len(events)-1] = true
+
+	type span struct{ first, last int }
+	stack := []span{{0, len(events) - 1}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		dmax := 0.0
+		index := 0
+		for i := s.first + 1; i < s.last; i++ {
+			d := segmentDistance(events[i], events[s.first], events[s.last])
+			if d > dmax {
+				index = i
+				dmax = d
+			}
+		}
+
+		if dmax > epsilon {
+			keep[index] = true
+			stack = append(stack, span{s.first, index}, span{index, s.last})
+		}
+	}
+
+	simplified := make([]repository.Event, 0, len(events)/4)
+	for i, e := range events {
+		if keep[i] {
+			simplified = append(simplified, e)
+		}
+	}
+	return simplified
+}
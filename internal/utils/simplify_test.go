@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"math"
+	"testing"
+
+	"github.com/janschill/track-me/internal/repository"
+)
+
+// zigzag builds a track heading north with a sideways offset of every other event in meters
+func zigzag(latitude float64, n int, offset float64) []repository.Event {
+	metersPerDegree := earthRadiusKm * 1000 * math.Pi / 180
+	events := make([]repository.Event, n)
+	for i := range events {
+		lng := 0.0
+		if i%2 == 1 {
+			lng = offset / (metersPerDegree * math.Cos(degreesToRadians(latitude)))
+		}
+		events[i] = repository.Event{Latitude: latitude + float64(i)*0.001, Longitude: lng, TimeStamp: int64(i)}
+	}
+	return events
+}
+
+func TestSegmentDistance(t *testing.T) {
+	start := repository.Event{Latitude: 60, Longitude: 10}
+	end := repository.Event{Latitude: 60.01, Longitude: 10}
+	event := zigzag(60, 2, 50)[1]
+	event.Latitude = 60.005
+	event.Longitude += 10
+
+	if d := segmentDistance(event, start, end); math.Abs(d-50) > 0.5 {
+		t.Errorf("segmentDistance() = %f; expected 50 m", d)
+	}
+
+	// Beyond the end of the segment the distance to the end point counts
+	beyond := repository.Event{Latitude: 60.02, Longitude: 10}
+	if d := segmentDistance(beyond, start, end); math.Abs(d-haversine(60.01, 10, 60.02, 10)*1000) > 0.5 {
+		t.Errorf("segmentDistance() = %f; expected distance to the segment end", d)
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   []repository.Event
+		epsilon  float64
+		expected int
+	}{
+		{"too short", zigzag(0, 2, 100), 10, 2},
+		{"straight line", zigzag(45, 100, 0), 10, 2},
+		{"wiggles below epsilon at the equator", zigzag(0, 100, 5), 10, 2},
+		{"wiggles below epsilon in the north", zigzag(65, 100, 5), 10, 2},
+		{"wiggles above epsilon at the equator", zigzag(0, 101, 50), 10, 101},
+		{"wiggles above epsilon in the north", zigzag(65, 101, 50), 10, 101},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			simplified := Simplify(tt.events, tt.epsilon)
+			if len(simplified) != tt.expected {
+				t.Errorf("Simplify() returned %d events; expected %d", len(simplified), tt.expected)
+			}
+		})
+	}
+}
+
+func TestSimplifyLongTrack(t *testing.T) {
+	events := zigzag(45, 500000, 1)
+	simplified := Simplify(events, 10)
+	if len(simplified) != 2 {
+		t.Errorf("Simplify() returned %d events; expected 2", len(simplified))
+	}
+}
+
+func TestSimplifyRides(t *testing.T) {
+	walkTestFiles(t, func(path string, data GPXData) {
+		events := ConvertPointsToEvents(data.Points)
+		simplified := Simplify(events, 10)
+
+		if len(simplified) >= len(events)/2 {
+			t.Errorf("Simplify() kept %d of %d events (file: %s)", len(simplified), len(events), path)
+		}
+
+		distance := DistanceInMeters(events)
+		simplifiedDistance := DistanceInMeters(simplified)
+		if math.Abs(distance-simplifiedDistance) > 0.05*distance {
+			t.Errorf("DistanceInMeters(simplified) = %f; expected about %f (file: %s)", simplifiedDistance, distance, path)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"github.com/janschill/track-me/internal/i18n"
+)
+
+type UnitSystem string
+
+const (
+	Metric   UnitSystem = "metric"
+	Imperial UnitSystem = "imperial"
+)
+
+const (
+	metersPerMile = 1609.344
+	metersPerFoot = 0.3048
+)
+
+// ParseUnitSystem returns false for anything but metric and imperial
+func ParseUnitSystem(s string) (UnitSystem, bool) {
+	switch UnitSystem(s) {
+	case Metric, Imperial:
+		return UnitSystem(s), true
+	}
+	return "", false
+}
+
+// Units describes a unit system for clients that convert values themselves.
+// Factors are the number of base units (meters, km/h) in one display unit.
+type Units struct {
+	System          UnitSystem `json:"system"`
+	Distance        string     `json:"distance"`
+	DistanceFactor  float64    `json:"distanceFactor"`
+	Speed           string     `json:"speed"`
+	SpeedFactor     float64    `json:"speedFactor"`
+	Elevation       string     `json:"elevation"`
+	ElevationFactor float64    `json:"elevationFactor"`
+}
+
+func (u UnitSystem) Units() Units {
+	if u == Imperial {
+		return Units{
+			System:   Imperial,
+			Distance: "mi", DistanceFactor: metersPerMile,
+			Speed: "mph", SpeedFactor: metersPerMile / 1000,
+			Elevation: "ft", ElevationFactor: metersPerFoot,
+		}
+	}
+	return Units{
+		System:   Metric,
+		Distance: "km", DistanceFactor: 1000,
+		Speed: "km/h", SpeedFactor: 1,
+		Elevation: "m", ElevationFactor: 1,
+	}
+}
+
+// Distance converts meters to kilometers or miles
+func (u UnitSystem) Distance(meters float64) float64 {
+	return meters / u.Units().DistanceFactor
+}
+
+// Speed converts km/h to km/h or mph
+func (u UnitSystem) Speed(kmh float64) float64 {
+	return kmh / u.Units().SpeedFactor
+}
+
+// Elevation converts meters to meters or feet
+func (u UnitSystem) Elevation(meters float64) float64 {
+	return meters / u.Units().ElevationFactor
+}
+
+func (u UnitSystem) FormatDistance(l *i18n.Locale, meters float64) string {
+	return l.Number(u.Distance(meters), 1) + " " + u.Units().Distance
+}
+
+func (u UnitSystem) FormatSpeed(l *i18n.Locale, kmh float64) string {
+	return l.Number(u.Speed(kmh), 1) + " " + u.Units().Speed
+}
+
+func (u UnitSystem) FormatElevation(l *i18n.Locale, meters float64) string {
+	return l.Number(u.Elevation(meters), 0) + " " + u.Units().Elevation
+}
+
+// SpeedBands are the limits of the speed histogram in this unit system
+func (u UnitSystem) SpeedBands() []float64 {
+	if u == Imperial {
+		return ImperialSpeedBands
+	}
+	return SpeedBands
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"math"
+	"testing"
+
+	"github.com/janschill/track-me/internal/i18n"
+)
+
+func TestParseUnitSystem(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected UnitSystem
+		ok       bool
+	}{
+		{"metric", Metric, true},
+		{"imperial", Imperial, true},
+		{"", "", false},
+		{"nautical", "", false},
+	}
+
+	for _, tt := range tests {
+		result, ok := ParseUnitSystem(tt.input)
+		if result != tt.expected || ok != tt.ok {
+			t.Errorf("ParseUnitSystem(%q) = %q, %v; expected %q, %v", tt.input, result, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestUnitConversions(t *testing.T) {
+	if result := Imperial.Distance(1609.344); math.Abs(result-1) > 1e-9 {
+		t.Errorf("Imperial.Distance(1609.344) = %v; expected 1", result)
+	}
+	if result := Imperial.Speed(16.09344); math.Abs(result-10) > 1e-9 {
+		t.Errorf("Imperial.Speed(16.09344) = %v; expected 10", result)
+	}
+	if result := Imperial.Elevation(3048); math.Abs(result-10000) > 1e-9 {
+		t.Errorf("Imperial.Elevation(3048) = %v; expected 10000", result)
+	}
+	if result := Metric.Distance(1500); result != 1.5 {
+		t.Errorf("Metric.Distance(1500) = %v; expected 1.5", result)
+	}
+}
+
+func TestUnitFormatting(t *testing.T) {
+	german, _ := i18n.Get("de")
+	tests := []struct {
+		name     string
+		result   string
+		expected string
+	}{
+		{"metric distance", Metric.FormatDistance(i18n.English, 3895000), "3,895.0 km"},
+		{"imperial distance", Imperial.FormatDistance(i18n.English, 3895000), "2,420.2 mi"},
+		{"metric speed", Metric.FormatSpeed(i18n.English, 13.84), "13.8 km/h"},
+		{"imperial speed", Imperial.FormatSpeed(i18n.English, 13.84), "8.6 mph"},
+		{"metric elevation", Metric.FormatElevation(i18n.English, 3363.4), "3,363 m"},
+		{"imperial elevation", Imperial.FormatElevation(i18n.English, 3363.4), "11,035 ft"},
+		{"german distance", Metric.FormatDistance(german, 3895000), "3.895,0 km"},
+		{"german speed", Metric.FormatSpeed(german, 13.84), "13,8 km/h"},
+		{"german elevation", Metric.FormatElevation(german, 3363.4), "3.363 m"},
+	}
+
+	for _, tt := range tests {
+		if tt.result != tt.expected {
+			t.Errorf("%s = %q; expected %q", tt.name, tt.result, tt.expected)
+		}
+	}
+}
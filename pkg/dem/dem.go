@@ -0,0 +1,134 @@
+// Package dem looks up terrain elevations in SRTM .hgt tiles stored on disk.
+// Tiles are named after their south west corner, e.g. N47W114.hgt, and contain
+// a square grid of big endian int16 heights in meters, rows from north to south.
+package dem
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Cells without data in SRTM tiles
+const void = -32768
+
+var ErrNoData = errors.New("dem: no elevation data")
+
+type tile struct {
+	size    int
+	heights []int16
+}
+
+type DEM struct {
+	dir   string
+	mu    sync.Mutex
+	tiles map[string]*tile
+}
+
+func New(dir string) *DEM {
+	return &DEM{
+		dir:   dir,
+		tiles: make(map[string]*tile),
+	}
+}
+
+func tileName(lat, lon float64) string {
+	latFloor := int(math.Floor(lat))
+	lonFloor := int(math.Floor(lon))
+
+	ns, ew := 'N', 'E'
+	if latFloor < 0 {
+		ns = 'S'
+		latFloor = -latFloor
+	}
+	if lonFloor < 0 {
+		ew = 'W'
+		lonFloor = -lonFloor
+	}
+	return fmt.Sprintf("%c%02d%c%03d.hgt", ns, latFloor, ew, lonFloor)
+}
+
+// loadTile reads a tile once and keeps it in memory. Missing tiles are
+// remembered as nil so the disk is not hit again.
+func (d *DEM) loadTile(name string) (*tile, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if t, ok := d.tiles[name]; ok {
+		return t, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(d.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		d.tiles[name] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	size := int(math.Sqrt(float64(len(data) / 2)))
+	if size < 2 || size*size*2 != len(data) {
+		return nil, fmt.Errorf("dem: %s is not a square grid of heights", name)
+	}
+
+	t := &tile{size: size, heights: make([]int16, size*size)}
+	for i := range t.heights {
+		t.heights[i] = int16(binary.BigEndian.Uint16(data[i*2:]))
+	}
+	d.tiles[name] = t
+	return t, nil
+}
+
+func (t *tile) height(row, col int) (float64, bool) {
+	h := t.heights[row*t.size+col]
+	return float64(h), h != void
+}
+
+// Elevation returns the bilinear interpolated terrain height at a position
+func (d *DEM) Elevation(lat, lon float64) (float64, error) {
+	t, err := d.loadTile(tileName(lat, lon))
+	if err != nil {
+		return 0, err
+	}
+	if t == nil {
+		return 0, ErrNoData
+	}
+
+	cells := float64(t.size - 1)
+	// Rows start at the northern edge of the tile
+	y := (math.Floor(lat) + 1 - lat) * cells
+	x := (lon - math.Floor(lon)) * cells
+
+	row := min(int(y), t.size-2)
+	col := min(int(x), t.size-2)
+	dy := y - float64(row)
+	dx := x - float64(col)
+
+	neighbours := [4]struct {
+		row, col int
+		weight   float64
+	}{
+		{row, col, (1 - dx) * (1 - dy)},
+		{row, col + 1, dx * (1 - dy)},
+		{row + 1, col, (1 - dx) * dy},
+		{row + 1, col + 1, dx * dy},
+	}
+
+	var elevation float64
+	for _, c := range neighbours {
+		if c.weight == 0 {
+			continue
+		}
+		h, ok := t.height(c.row, c.col)
+		if !ok {
+			return 0, ErrNoData
+		}
+		elevation += h * c.weight
+	}
+	return elevation, nil
+}
@@ -0,0 +1,78 @@
+package dem
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTile stores a 3x3 tile with heights given from north to south
+func writeTile(t *testing.T, dir, name string, heights []int16) {
+	data := make([]byte, 0, len(heights)*2)
+	for _, h := range heights {
+		data = binary.BigEndian.AppendUint16(data, uint16(h))
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		t.Fatalf("Failed to write tile: %v", err)
+	}
+}
+
+func TestTileName(t *testing.T) {
+	tests := []struct {
+		lat, lon float64
+		expected string
+	}{
+		{47.5, -113.2, "N47W114.hgt"},
+		{-33.9, 18.4, "S34E018.hgt"},
+		{0.5, 0.5, "N00E000.hgt"},
+	}
+
+	for _, tt := range tests {
+		if got := tileName(tt.lat, tt.lon); got != tt.expected {
+			t.Errorf("tileName(%v, %v) = %s; expected %s", tt.lat, tt.lon, got, tt.expected)
+		}
+	}
+}
+
+func TestElevation(t *testing.T) {
+	dir := t.TempDir()
+	writeTile(t, dir, "N47W114.hgt", []int16{
+		1000, 1100, 1200,
+		900, 1000, 1100,
+		800, 900, void,
+	})
+	d := New(dir)
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		expected float64
+	}{
+		{"north west corner", 47.9999999999, -114.0, 1000},
+		{"center", 47.5, -113.5, 1000},
+		{"south west corner", 47.0, -114.0, 800},
+		{"interpolated", 47.75, -113.75, 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := d.Elevation(tt.lat, tt.lon)
+			if err != nil {
+				t.Fatalf("Elevation() error = %v", err)
+			}
+			if math.Abs(got-tt.expected) > 1e-6 {
+				t.Errorf("Elevation(%v, %v) = %v; expected %v", tt.lat, tt.lon, got, tt.expected)
+			}
+		})
+	}
+
+	if _, err := d.Elevation(47.1, -113.1); !errors.Is(err, ErrNoData) {
+		t.Errorf("Elevation() next to a void error = %v; expected %v", err, ErrNoData)
+	}
+	if _, err := d.Elevation(10.5, 10.5); !errors.Is(err, ErrNoData) {
+		t.Errorf("Elevation() without tile error = %v; expected %v", err, ErrNoData)
+	}
+}
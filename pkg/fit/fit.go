@@ -0,0 +1,255 @@
+// Package fit decodes the record messages of Garmin FIT activity files.
+// Only the fields needed to rebuild a track are read, everything else is skipped.
+// https://developer.garmin.com/fit/protocol/
+package fit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	recordMessage = 20
+
+	fieldPositionLat      = 0
+	fieldPositionLong     = 1
+	fieldAltitude         = 2
+	fieldSpeed            = 6
+	fieldEnhancedSpeed    = 73
+	fieldEnhancedAltitude = 78
+	fieldTimestamp        = 253
+)
+
+// FIT timestamps count seconds since 1989-12-31T00:00:00Z
+const fitEpoch int64 = 631065600
+
+const semicirclesToDegrees = 180.0 / (1 << 31)
+
+var (
+	ErrInvalidHeader = errors.New("fit: invalid file header")
+	ErrInvalidCRC    = errors.New("fit: file checksum mismatch")
+)
+
+// Record is a single sample of an activity
+type Record struct {
+	Time      time.Time
+	Latitude  float64
+	Longitude float64
+	Altitude  float64
+	Speed     float64 // m/s
+	// HasPosition is false for samples recorded without a GPS fix
+	HasPosition bool
+}
+
+type fieldDefinition struct {
+	number   byte
+	size     byte
+	baseType byte
+}
+
+type definition struct {
+	globalMessage uint16
+	byteOrder     binary.ByteOrder
+	fields        []fieldDefinition
+	devDataSize   int
+}
+
+type decoder struct {
+	r             *bytes.Reader
+	definitions   map[byte]*definition
+	lastTimestamp uint32
+	records       []Record
+}
+
+// Decode reads all record messages from a FIT file
+func Decode(r io.Reader) ([]Record, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12 {
+		return nil, ErrInvalidHeader
+	}
+
+	headerSize := int(data[0])
+	if (headerSize != 12 && headerSize != 14) || len(data) < headerSize || string(data[8:12]) != ".FIT" {
+		return nil, ErrInvalidHeader
+	}
+	dataSize := int(binary.LittleEndian.Uint32(data[4:8]))
+	if len(data) < headerSize+dataSize+2 {
+		return nil, fmt.Errorf("fit: file truncated, expected %d bytes of data", dataSize)
+	}
+	end := headerSize + dataSize
+	if crc(data[:end]) != binary.LittleEndian.Uint16(data[end:end+2]) {
+		return nil, ErrInvalidCRC
+	}
+
+	d := &decoder{
+		r:           bytes.NewReader(data[headerSize:end]),
+		definitions: make(map[byte]*definition),
+	}
+	for d.r.Len() > 0 {
+		if err := d.readMessage(); err != nil {
+			return nil, err
+		}
+	}
+	return d.records, nil
+}
+
+func (d *decoder) readMessage() error {
+	header, err := d.r.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	// Compressed timestamp header: local type in bits 5-6, time offset in bits 0-4
+	if header&0x80 != 0 {
+		local := (header >> 5) & 0x03
+		offset := uint32(header & 0x1f)
+		timestamp := (d.lastTimestamp &^ 0x1f) + offset
+		if offset < d.lastTimestamp&0x1f {
+			timestamp += 0x20
+		}
+		d.lastTimestamp = timestamp
+		return d.readData(local, &timestamp)
+	}
+
+	local := header & 0x0f
+	if header&0x40 != 0 {
+		return d.readDefinition(local, header&0x20 != 0)
+	}
+	return d.readData(local, nil)
+}
+
+func (d *decoder) readDefinition(local byte, hasDevData bool) error {
+	var fixed [5]byte
+	if _, err := io.ReadFull(d.r, fixed[:]); err != nil {
+		return err
+	}
+
+	def := &definition{byteOrder: binary.LittleEndian}
+	if fixed[1] == 1 {
+		def.byteOrder = binary.BigEndian
+	}
+	def.globalMessage = def.byteOrder.Uint16(fixed[2:4])
+
+	def.fields = make([]fieldDefinition, fixed[4])
+	for i := range def.fields {
+		var f [3]byte
+		if _, err := io.ReadFull(d.r, f[:]); err != nil {
+			return err
+		}
+		def.fields[i] = fieldDefinition{number: f[0], size: f[1], baseType: f[2]}
+	}
+
+	if hasDevData {
+		count, err := d.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < int(count); i++ {
+			var f [3]byte
+			if _, err := io.ReadFull(d.r, f[:]); err != nil {
+				return err
+			}
+			def.devDataSize += int(f[1])
+		}
+	}
+
+	d.definitions[local] = def
+	return nil
+}
+
+func (d *decoder) readData(local byte, timestamp *uint32) error {
+	def, ok := d.definitions[local]
+	if !ok {
+		return fmt.Errorf("fit: data message for undefined local type %d", local)
+	}
+
+	record := Record{}
+	var lat, long int32
+	validLat, validLong := false, false
+
+	for _, field := range def.fields {
+		buf := make([]byte, field.size)
+		if _, err := io.ReadFull(d.r, buf); err != nil {
+			return err
+		}
+		if def.globalMessage != recordMessage {
+			if field.number == fieldTimestamp && field.size == 4 {
+				d.lastTimestamp = def.byteOrder.Uint32(buf)
+			}
+			continue
+		}
+
+		switch {
+		case field.number == fieldTimestamp && field.size == 4:
+			ts := def.byteOrder.Uint32(buf)
+			d.lastTimestamp = ts
+			timestamp = &ts
+		case field.number == fieldPositionLat && field.size == 4:
+			lat = int32(def.byteOrder.Uint32(buf))
+			validLat = lat != math.MaxInt32
+		case field.number == fieldPositionLong && field.size == 4:
+			long = int32(def.byteOrder.Uint32(buf))
+			validLong = long != math.MaxInt32
+		case field.number == fieldAltitude && field.size == 2:
+			if v := def.byteOrder.Uint16(buf); v != math.MaxUint16 && record.Altitude == 0 {
+				record.Altitude = float64(v)/5 - 500
+			}
+		case field.number == fieldEnhancedAltitude && field.size == 4:
+			if v := def.byteOrder.Uint32(buf); v != math.MaxUint32 {
+				record.Altitude = float64(v)/5 - 500
+			}
+		case field.number == fieldSpeed && field.size == 2:
+			if v := def.byteOrder.Uint16(buf); v != math.MaxUint16 && record.Speed == 0 {
+				record.Speed = float64(v) / 1000
+			}
+		case field.number == fieldEnhancedSpeed && field.size == 4:
+			if v := def.byteOrder.Uint32(buf); v != math.MaxUint32 {
+				record.Speed = float64(v) / 1000
+			}
+		}
+	}
+
+	if _, err := d.r.Seek(int64(def.devDataSize), io.SeekCurrent); err != nil {
+		return err
+	}
+
+	if def.globalMessage != recordMessage || timestamp == nil {
+		return nil
+	}
+
+	record.Time = time.Unix(int64(*timestamp)+fitEpoch, 0).UTC()
+	if validLat && validLong {
+		record.HasPosition = true
+		record.Latitude = float64(lat) * semicirclesToDegrees
+		record.Longitude = float64(long) * semicirclesToDegrees
+	}
+	d.records = append(d.records, record)
+	return nil
+}
+
+var crcTable = [16]uint16{
+	0x0000, 0xCC01, 0xD801, 0x1400, 0xF001, 0x3C00, 0x2800, 0xE401,
+	0xA001, 0x6C00, 0x7800, 0xB401, 0x5000, 0x9C01, 0x8801, 0x4400,
+}
+
+func crc(data []byte) uint16 {
+	var sum uint16
+	for _, b := range data {
+		tmp := crcTable[sum&0xF]
+		sum = (sum >> 4) & 0x0FFF
+		sum = sum ^ tmp ^ crcTable[b&0xF]
+
+		tmp = crcTable[sum&0xF]
+		sum = (sum >> 4) & 0x0FFF
+		sum = sum ^ tmp ^ crcTable[(b>>4)&0xF]
+	}
+	return sum
+}
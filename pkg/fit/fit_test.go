@@ -0,0 +1,117 @@
+package fit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func degreesToSemicircles(d float64) uint32 {
+	return uint32(int32(d / semicirclesToDegrees))
+}
+
+// buildFIT wraps message bytes with a 14 byte header and the trailing checksum
+func buildFIT(messages []byte) []byte {
+	header := make([]byte, 14)
+	header[0] = 14
+	header[1] = 0x20
+	binary.LittleEndian.PutUint16(header[2:4], 2132)
+	binary.LittleEndian.PutUint32(header[4:8], uint32(len(messages)))
+	copy(header[8:12], ".FIT")
+	binary.LittleEndian.PutUint16(header[12:14], crc(header[:12]))
+
+	file := append(header, messages...)
+	return binary.LittleEndian.AppendUint16(file, crc(file))
+}
+
+func testMessages() []byte {
+	var b bytes.Buffer
+	le := binary.LittleEndian
+
+	// file_id definition and data on local type 1, must be skipped
+	b.Write([]byte{0x41, 0, 0, 0, 0, 1, 4, 4, 0x86})
+	b.WriteByte(0x01)
+	b.Write(le.AppendUint32(nil, 1000))
+
+	// record definition on local type 0: timestamp, lat, long, enhanced altitude, speed
+	b.Write([]byte{0x40, 0, 0, recordMessage, 0, 5,
+		fieldTimestamp, 4, 0x86,
+		fieldPositionLat, 4, 0x85,
+		fieldPositionLong, 4, 0x85,
+		fieldEnhancedAltitude, 4, 0x86,
+		fieldSpeed, 2, 0x84,
+	})
+
+	ts := uint32(1725890400 - fitEpoch)
+	b.WriteByte(0x00)
+	b.Write(le.AppendUint32(nil, ts))
+	b.Write(le.AppendUint32(nil, degreesToSemicircles(48.99542)))
+	b.Write(le.AppendUint32(nil, degreesToSemicircles(-115.05712)))
+	b.Write(le.AppendUint32(nil, uint32((825.8+500)*5)))
+	b.Write(le.AppendUint16(nil, 5500))
+
+	// no GPS fix yet
+	b.WriteByte(0x00)
+	b.Write(le.AppendUint32(nil, ts+10))
+	b.Write(le.AppendUint32(nil, math.MaxInt32))
+	b.Write(le.AppendUint32(nil, math.MaxInt32))
+	b.Write(le.AppendUint32(nil, math.MaxUint32))
+	b.Write(le.AppendUint16(nil, math.MaxUint16))
+
+	// record definition on local type 2 without a timestamp field,
+	// followed by a compressed timestamp header 5 seconds later
+	b.Write([]byte{0x42, 0, 0, recordMessage, 0, 2,
+		fieldPositionLat, 4, 0x85,
+		fieldPositionLong, 4, 0x85,
+	})
+	offset := byte((ts + 15) & 0x1f)
+	b.WriteByte(0x80 | 2<<5 | offset)
+	b.Write(le.AppendUint32(nil, degreesToSemicircles(48.99546)))
+	b.Write(le.AppendUint32(nil, degreesToSemicircles(-115.05811)))
+
+	return b.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	records, err := Decode(bytes.NewReader(buildFIT(testMessages())))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Decode() returned %d records; expected 3", len(records))
+	}
+
+	first := records[0]
+	if !first.Time.Equal(time.Unix(1725890400, 0)) {
+		t.Errorf("records[0].Time = %v; expected %v", first.Time, time.Unix(1725890400, 0).UTC())
+	}
+	if !first.HasPosition || math.Abs(first.Latitude-48.99542) > 1e-6 || math.Abs(first.Longitude+115.05712) > 1e-6 {
+		t.Errorf("records[0] position = %v, %v; expected 48.99542, -115.05712", first.Latitude, first.Longitude)
+	}
+	if math.Abs(first.Altitude-825.8) > 0.1 || first.Speed != 5.5 {
+		t.Errorf("records[0] altitude/speed = %v, %v; expected 825.8, 5.5", first.Altitude, first.Speed)
+	}
+
+	if records[1].HasPosition {
+		t.Errorf("records[1].HasPosition = true; expected false for invalid coordinates")
+	}
+
+	if !records[2].Time.Equal(time.Unix(1725890415, 0)) || !records[2].HasPosition {
+		t.Errorf("records[2] = %+v; expected compressed timestamp 1725890415 with position", records[2])
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	if _, err := Decode(bytes.NewReader([]byte("not a fit file"))); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("Decode() error = %v; expected %v", err, ErrInvalidHeader)
+	}
+
+	file := buildFIT(testMessages())
+	file[len(file)-1] ^= 0xff
+	if _, err := Decode(bytes.NewReader(file)); !errors.Is(err, ErrInvalidCRC) {
+		t.Errorf("Decode() error = %v; expected %v", err, ErrInvalidCRC)
+	}
+}
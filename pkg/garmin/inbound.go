@@ -5,9 +5,11 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
+
+	"github.com/janschill/track-me/pkg/metrics"
 )
 
 type Client struct {
@@ -65,13 +67,20 @@ func (c *Client) newRequest(method, endpoint string, body []byte) (*http.Request
 	return req, nil
 }
 
+var inboundMessages = metrics.NewCounterVec(
+	"garmin_inbound_messages_total",
+	"Messages sent to the device through IPC Inbound by result: sent, failed or rate_limited.",
+	"result",
+)
+
 func (c *Client) SendMessage(sender string, message string) error {
 	if len(message) > 160 {
 		return fmt.Errorf("message length exceeds limit")
 	}
 
 	if !c.rateLimiter.Allow(c.imei) {
-		log.Print("rate limit exceeded")
+		slog.Warn("Inbound rate limit exceeded")
+		inboundMessages.Inc("rate_limited")
 		return fmt.Errorf("rate limit exceeded")
 	}
 
@@ -91,7 +100,7 @@ func (c *Client) SendMessage(sender string, message string) error {
 
 	requestBody, err := json.Marshal(payload)
 	if err != nil {
-		log.Print("failed to marshal request body: %w", err)
+		slog.Error("Failed to marshal request body", "error", err)
 		return fmt.Errorf("failed to marshal request body: %w", err)
 	}
 
@@ -102,15 +111,18 @@ func (c *Client) SendMessage(sender string, message string) error {
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		inboundMessages.Inc("failed")
 		return fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		inboundMessages.Inc("failed")
 		return fmt.Errorf("failed to send message, status code: %d", resp.StatusCode)
 	}
 
-	log.Printf("Message sent successfully: %v", payload)
+	slog.Info("Message sent to Garmin")
+	inboundMessages.Inc("sent")
 
 	return nil
 }
@@ -2,7 +2,7 @@ package garmin
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 )
 
@@ -68,7 +68,7 @@ func (h *OutboundHandler) CreateOutboundEvent(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	log.Printf("Outbound payload received. %v event(s)\n", len(payload.Events))
+	slog.InfoContext(r.Context(), "Outbound payload received", "events", len(payload.Events))
 
 	if err := h.processPayload(payload); err != nil {
 		http.Error(w, "Error processing payload", http.StatusInternalServerError)
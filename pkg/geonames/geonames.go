@@ -0,0 +1,251 @@
+// Package geonames finds the nearest named place of a position without network access.
+//
+// Places are read from tab separated files in the layout of the GeoNames exports,
+// e.g. cities1000.txt from https://download.geonames.org/export/dump/. The embedded
+// dataset only holds the towns along the Great Divide; a full export can be passed
+// to Load or Open instead. Lookups use a k-d tree over points on the unit sphere,
+// so they do not break down near the poles or the antimeridian.
+package geonames
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:embed places.txt
+var embedded string
+
+const earthRadius = 6371000.0
+
+// Columns of the GeoNames exports that are read
+const (
+	columnName       = 1
+	columnLatitude   = 4
+	columnLongitude  = 5
+	columnCountry    = 8
+	columnAdmin1     = 10
+	columnPopulation = 14
+	minColumns       = columnPopulation + 1
+)
+
+type Place struct {
+	Name        string
+	CountryCode string
+	// State or province code, e.g. MT, or a number for most countries
+	Admin1     string
+	Latitude   float64
+	Longitude  float64
+	Population int64
+}
+
+// Match is the nearest place of a position
+type Match struct {
+	Place
+	// Meters from the place to the position
+	Distance float64
+	// Degrees clockwise from north from the place to the position
+	Bearing float64
+}
+
+type node struct {
+	point       [3]float64
+	place       int
+	left, right int
+}
+
+// Index is a k-d tree of places
+type Index struct {
+	places []Place
+	nodes  []node
+	root   int
+}
+
+// Load reads places from a GeoNames export. Lines starting with # are ignored.
+func Load(r io.Reader) (*Index, error) {
+	var places []Place
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		columns := strings.Split(text, "\t")
+		if len(columns) < minColumns {
+			return nil, fmt.Errorf("geonames: line %d has %d columns, expected at least %d", line, len(columns), minColumns)
+		}
+		lat, err := strconv.ParseFloat(columns[columnLatitude], 64)
+		if err != nil {
+			return nil, fmt.Errorf("geonames: invalid latitude on line %d: %w", line, err)
+		}
+		lon, err := strconv.ParseFloat(columns[columnLongitude], 64)
+		if err != nil {
+			return nil, fmt.Errorf("geonames: invalid longitude on line %d: %w", line, err)
+		}
+		// The population is empty for some places
+		population, _ := strconv.ParseInt(columns[columnPopulation], 10, 64)
+		places = append(places, Place{
+			Name:        columns[columnName],
+			CountryCode: columns[columnCountry],
+			Admin1:      columns[columnAdmin1],
+			Latitude:    lat,
+			Longitude:   lon,
+			Population:  population,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("geonames: %w", err)
+	}
+	return New(places), nil
+}
+
+// Open loads places from a file, see Load
+func Open(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+var (
+	defaultOnce  sync.Once
+	defaultIndex *Index
+)
+
+// Default returns the index of the embedded places
+func Default() *Index {
+	defaultOnce.Do(func() {
+		index, err := Load(strings.NewReader(embedded))
+		if err != nil {
+			panic(err)
+		}
+		defaultIndex = index
+	})
+	return defaultIndex
+}
+
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// unitVector places a position on the unit sphere. The straight distance between
+// two such points grows with their great circle distance.
+func unitVector(lat, lon float64) [3]float64 {
+	phi, lambda := toRadians(lat), toRadians(lon)
+	return [3]float64{
+		math.Cos(phi) * math.Cos(lambda),
+		math.Cos(phi) * math.Sin(lambda),
+		math.Sin(phi),
+	}
+}
+
+// New builds the index of a list of places
+func New(places []Place) *Index {
+	index := &Index{places: places, nodes: make([]node, len(places))}
+	order := make([]int, len(places))
+	for i, p := range places {
+		index.nodes[i] = node{point: unitVector(p.Latitude, p.Longitude), place: i, left: -1, right: -1}
+		order[i] = i
+	}
+	index.root = index.build(order, 0)
+	return index
+}
+
+// build splits the nodes at the median of an axis and returns the root of the subtree
+func (x *Index) build(order []int, depth int) int {
+	if len(order) == 0 {
+		return -1
+	}
+	axis := depth % 3
+	sort.Slice(order, func(i, j int) bool {
+		return x.nodes[order[i]].point[axis] < x.nodes[order[j]].point[axis]
+	})
+	median := len(order) / 2
+	root := order[median]
+	x.nodes[root].left = x.build(order[:median], depth+1)
+	x.nodes[root].right = x.build(order[median+1:], depth+1)
+	return root
+}
+
+func squaredDistance(a, b [3]float64) float64 {
+	dx, dy, dz := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dx*dx + dy*dy + dz*dz
+}
+
+func (x *Index) nearest(n, depth int, target [3]float64, best *int, bestDistance *float64) {
+	if n < 0 {
+		return
+	}
+	current := x.nodes[n]
+	if d := squaredDistance(current.point, target); d < *bestDistance {
+		*best, *bestDistance = n, d
+	}
+
+	axis := depth % 3
+	diff := target[axis] - current.point[axis]
+	near, far := current.left, current.right
+	if diff > 0 {
+		near, far = far, near
+	}
+	x.nearest(near, depth+1, target, best, bestDistance)
+	// The other side can only be closer if the splitting plane is
+	if diff*diff < *bestDistance {
+		x.nearest(far, depth+1, target, best, bestDistance)
+	}
+}
+
+// Nearest returns the place closest to a position, ok is false for an empty index
+func (x *Index) Nearest(lat, lon float64) (match Match, ok bool) {
+	if x.root < 0 {
+		return Match{}, false
+	}
+	best, bestDistance := -1, math.Inf(1)
+	x.nearest(x.root, 0, unitVector(lat, lon), &best, &bestDistance)
+
+	place := x.places[x.nodes[best].place]
+	return Match{
+		Place:    place,
+		Distance: Distance(place.Latitude, place.Longitude, lat, lon),
+		Bearing:  Bearing(place.Latitude, place.Longitude, lat, lon),
+	}, true
+}
+
+// Len returns the number of places in the index
+func (x *Index) Len() int {
+	return len(x.places)
+}
+
+// Distance returns the great circle distance in meters
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := toRadians(lat1), toRadians(lat2)
+	dPhi, dLambda := toRadians(lat2-lat1), toRadians(lon2-lon1)
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return earthRadius * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// Bearing returns the initial bearing from the first to the second position
+// in degrees clockwise from north
+func Bearing(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := toRadians(lat1), toRadians(lat2)
+	dLambda := toRadians(lon2 - lon1)
+	y := math.Sin(dLambda) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dLambda)
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
+
+var compassPoints = []string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}
+
+// Compass returns the nearest of the eight compass points of a bearing
+func Compass(bearing float64) string {
+	return compassPoints[int(math.Round(math.Mod(bearing+360, 360)/45))%8]
+}
@@ -0,0 +1,125 @@
+package geonames
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestDefaultNearest(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat, lon float64
+		expected string
+		compass  string
+	}{
+		{"Banff townsite", 51.1784, -115.5708, "Banff", "N"},
+		{"north of Lincoln", 47.05, -112.68, "Lincoln", "N"},
+		{"east of Pie Town", 34.30, -108.00, "Pie Town", "E"},
+		{"Mexican border", 31.34, -108.53, "Antelope Wells", "N"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, ok := Default().Nearest(tt.lat, tt.lon)
+			if !ok || match.Name != tt.expected {
+				t.Fatalf("Nearest(%v, %v) = %s, %v; expected %s", tt.lat, tt.lon, match.Name, ok, tt.expected)
+			}
+			if match.Distance > 1000 && Compass(match.Bearing) != tt.compass {
+				t.Errorf("Compass(%v) = %s; expected %s", match.Bearing, Compass(match.Bearing), tt.compass)
+			}
+		})
+	}
+}
+
+func TestNearestMatchesLinearSearch(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	places := make([]Place, 500)
+	for i := range places {
+		places[i] = Place{
+			Latitude:  random.Float64()*180 - 90,
+			Longitude: random.Float64()*360 - 180,
+		}
+	}
+	index := New(places)
+
+	for i := 0; i < 1000; i++ {
+		lat, lon := random.Float64()*180-90, random.Float64()*360-180
+		match, _ := index.Nearest(lat, lon)
+
+		closest := math.Inf(1)
+		for _, p := range places {
+			closest = math.Min(closest, Distance(p.Latitude, p.Longitude, lat, lon))
+		}
+		if math.Abs(match.Distance-closest) > 1e-6 {
+			t.Fatalf("Nearest(%v, %v) is %v m away; expected %v m", lat, lon, match.Distance, closest)
+		}
+	}
+}
+
+func TestNearestAcrossAntimeridian(t *testing.T) {
+	index := New([]Place{
+		{Name: "West", Latitude: 0, Longitude: 179.9},
+		{Name: "Far", Latitude: 0, Longitude: 178},
+	})
+	match, _ := index.Nearest(0, -179.9)
+	if match.Name != "West" {
+		t.Errorf("Nearest(0, -179.9) = %s; expected West", match.Name)
+	}
+}
+
+func TestEmptyIndex(t *testing.T) {
+	if _, ok := New(nil).Nearest(0, 0); ok {
+		t.Errorf("Nearest() on an empty index = ok; expected not ok")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	export := "# comment\n" +
+		"1\tLincoln\tLincoln\t\t46.95494\t-112.68171\tP\tPPL\tUS\t\tMT\t043\t\t\t1013\t1655\t1650\tAmerica/Denver\t2011-05-14\n" +
+		"\n" +
+		"2\tOvando\tOvando\t\t47.02048\t-113.13226\tP\tPPL\tUS\t\tMT\t077\t\t\t\t\t1255\tAmerica/Denver\t2006-01-17\n"
+	index, err := Load(strings.NewReader(export))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if index.Len() != 2 {
+		t.Fatalf("Load() read %d places; expected 2", index.Len())
+	}
+	match, _ := index.Nearest(47, -113)
+	if match.Name != "Ovando" || match.Admin1 != "MT" || match.Population != 0 {
+		t.Errorf("Nearest(47, -113) = %+v; expected Ovando, MT without population", match.Place)
+	}
+
+	invalid := []string{
+		"1\tShort\tShort\t\t46\t-112\n",
+		"1\tBad\tBad\t\tnorth\t-112\tP\tPPL\tUS\t\tMT\t\t\t\t1\n",
+	}
+	for _, export := range invalid {
+		if _, err := Load(strings.NewReader(export)); err == nil {
+			t.Errorf("Load(%q) error = nil; expected an error", export)
+		}
+	}
+}
+
+func TestCompass(t *testing.T) {
+	tests := []struct {
+		bearing  float64
+		expected string
+	}{
+		{0, "N"},
+		{22, "N"},
+		{23, "NE"},
+		{90, "E"},
+		{200, "S"},
+		{337, "NW"},
+		{359, "N"},
+	}
+
+	for _, tt := range tests {
+		if result := Compass(tt.bearing); result != tt.expected {
+			t.Errorf("Compass(%v) = %s; expected %s", tt.bearing, result, tt.expected)
+		}
+	}
+}
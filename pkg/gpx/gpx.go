@@ -0,0 +1,60 @@
+package gpx
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+type GPX struct {
+	XMLName   xml.Name   `xml:"gpx"`
+	Waypoints []Waypoint `xml:"wpt"`
+	Tracks    []Track    `xml:"trk"`
+}
+
+// Waypoint is a named point of interest, e.g. a store or a campground
+type Waypoint struct {
+	Latitude    float64 `xml:"lat,attr"`
+	Longitude   float64 `xml:"lon,attr"`
+	Elevation   float64 `xml:"ele"`
+	Name        string  `xml:"name"`
+	Description string  `xml:"desc"`
+	// Type and symbol are free text, e.g. Food and Convenience Store
+	Type   string `xml:"type"`
+	Symbol string `xml:"sym"`
+}
+
+type Track struct {
+	Name     string    `xml:"name"`
+	Segments []Segment `xml:"trkseg"`
+}
+
+type Segment struct {
+	Points []Point `xml:"trkpt"`
+}
+
+type Point struct {
+	Latitude  float64   `xml:"lat,attr"`
+	Longitude float64   `xml:"lon,attr"`
+	Elevation float64   `xml:"ele"`
+	Time      time.Time `xml:"time"`
+}
+
+func Decode(r io.Reader) (*GPX, error) {
+	var g GPX
+	if err := xml.NewDecoder(r).Decode(&g); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// Points returns the points of all tracks and segments in file order
+func (g *GPX) Points() []Point {
+	var points []Point
+	for _, track := range g.Tracks {
+		for _, segment := range track.Segments {
+			points = append(points, segment.Points...)
+		}
+	}
+	return points
+}
@@ -0,0 +1,84 @@
+package gpx
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+  <trk>
+    <name>Morning Ride</name>
+    <trkseg>
+      <trkpt lat="48.99542" lon="-115.05712"><ele>825.8</ele><time>2024-09-09T14:00:00Z</time></trkpt>
+      <trkpt lat="48.99546" lon="-115.05811"><ele>822.7</ele><time>2024-09-09T14:00:10Z</time></trkpt>
+    </trkseg>
+    <trkseg>
+      <trkpt lat="48.99549" lon="-115.0583"><ele>822.4</ele></trkpt>
+    </trkseg>
+  </trk>
+</gpx>`
+
+func TestDecode(t *testing.T) {
+	g, err := Decode(strings.NewReader(testGPX))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	if len(g.Tracks) != 1 || g.Tracks[0].Name != "Morning Ride" {
+		t.Fatalf("Decode() tracks = %+v; expected one track named Morning Ride", g.Tracks)
+	}
+
+	points := g.Points()
+	if len(points) != 3 {
+		t.Fatalf("Points() returned %d points; expected 3", len(points))
+	}
+	if points[0].Latitude != 48.99542 || points[0].Elevation != 825.8 {
+		t.Errorf("Points()[0] = %+v; expected lat 48.99542 and ele 825.8", points[0])
+	}
+	if !points[1].Time.Equal(time.Date(2024, time.September, 9, 14, 0, 10, 0, time.UTC)) {
+		t.Errorf("Points()[1].Time = %v; expected 2024-09-09T14:00:10Z", points[1].Time)
+	}
+	if !points[2].Time.IsZero() {
+		t.Errorf("Points()[2].Time = %v; expected zero time", points[2].Time)
+	}
+}
+
+func TestDecodeWaypoints(t *testing.T) {
+	waypoints := `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+  <wpt lat="46.95494" lon="-112.68171">
+    <ele>1655</ele>
+    <name>Lincoln</name>
+    <desc>Grocery and motel</desc>
+    <sym>Convenience Store</sym>
+    <type>Food</type>
+  </wpt>
+  <wpt lat="47.02048" lon="-113.13226"><name>Ovando</name></wpt>
+</gpx>`
+	g, err := Decode(strings.NewReader(waypoints))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	if len(g.Waypoints) != 2 || len(g.Points()) != 0 {
+		t.Fatalf("Decode() = %d waypoints, %d points; expected 2 waypoints and no points", len(g.Waypoints), len(g.Points()))
+	}
+	expected := Waypoint{
+		Latitude: 46.95494, Longitude: -112.68171, Elevation: 1655,
+		Name: "Lincoln", Description: "Grocery and motel", Type: "Food", Symbol: "Convenience Store",
+	}
+	if g.Waypoints[0] != expected {
+		t.Errorf("Waypoints[0] = %+v; expected %+v", g.Waypoints[0], expected)
+	}
+	if g.Waypoints[1].Name != "Ovando" || g.Waypoints[1].Symbol != "" {
+		t.Errorf("Waypoints[1] = %+v; expected Ovando without symbol", g.Waypoints[1])
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	if _, err := Decode(strings.NewReader("not xml")); err == nil {
+		t.Errorf("Decode() expected an error for invalid input")
+	}
+}
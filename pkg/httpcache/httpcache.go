@@ -0,0 +1,71 @@
+// Package httpcache answers conditional requests and compresses responses.
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETag returns a strong entity tag over the values a response is built from
+func ETag(parts ...any) string {
+	h := sha256.New()
+	for _, part := range parts {
+		fmt.Fprintf(h, "%v\x00", part)
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil))[:20] + `"`
+}
+
+// ETagOf returns a strong entity tag over a response body
+func ETagOf(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:])[:20] + `"`
+}
+
+// opaque strips the weak prefix, If-None-Match compares tags weakly
+func opaque(etag string) string {
+	return strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+}
+
+func matches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || opaque(candidate) == opaque(etag) {
+			return true
+		}
+	}
+	return false
+}
+
+// NotModified sets the ETag and Last-Modified headers and answers with 304 Not
+// Modified when the client's copy is current. If-None-Match takes precedence over
+// If-Modified-Since. A zero modified time omits Last-Modified.
+func NotModified(w http.ResponseWriter, r *http.Request, etag string, modified time.Time) bool {
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	notModified := false
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		notModified = etag != "" && matches(ifNoneMatch, etag)
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modified.IsZero() {
+		// HTTP dates have second precision
+		notModified = !modified.Truncate(time.Second).After(since)
+	}
+	if notModified {
+		h := w.Header()
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+	}
+	return notModified
+}
@@ -0,0 +1,181 @@
+package httpcache
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotModified(t *testing.T) {
+	etag := ETag("trip", 1, 42)
+	modified := time.Date(2024, 6, 14, 12, 0, 0, 500, time.UTC)
+
+	tests := []struct {
+		name     string
+		header   map[string]string
+		expected bool
+	}{
+		{"unconditional", nil, false},
+		{"same tag", map[string]string{"If-None-Match": etag}, true},
+		{"weak tag of a compressed copy", map[string]string{"If-None-Match": "W/" + etag}, true},
+		{"one of many", map[string]string{"If-None-Match": `"other", ` + etag}, true},
+		{"any", map[string]string{"If-None-Match": "*"}, true},
+		{"other tag", map[string]string{"If-None-Match": `"other"`}, false},
+		{"not modified since", map[string]string{"If-Modified-Since": "Fri, 14 Jun 2024 12:00:00 GMT"}, true},
+		{"modified since", map[string]string{"If-Modified-Since": "Fri, 14 Jun 2024 11:59:59 GMT"}, false},
+		{"tag wins over date", map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": "Fri, 14 Jun 2024 12:00:00 GMT"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for name, value := range tt.header {
+				r.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			if result := NotModified(w, r, etag, modified); result != tt.expected {
+				t.Errorf("NotModified() = %v; expected %v", result, tt.expected)
+			}
+			if tt.expected && w.Code != http.StatusNotModified {
+				t.Errorf("status = %d; expected 304", w.Code)
+			}
+			if w.Header().Get("ETag") != etag || w.Header().Get("Last-Modified") != "Fri, 14 Jun 2024 12:00:00 GMT" {
+				t.Errorf("headers = %v; expected the tag and modification time", w.Header())
+			}
+		})
+	}
+}
+
+func TestETag(t *testing.T) {
+	if ETag("a", 1) == ETag("a1") {
+		t.Errorf("ETag(a, 1) = ETag(a1); expected parts to be separated")
+	}
+	if ETagOf([]byte("body")) != ETagOf([]byte("body")) || ETagOf([]byte("body")) == ETagOf([]byte("other")) {
+		t.Errorf("ETagOf() is not derived from the body")
+	}
+}
+
+func TestCompress(t *testing.T) {
+	long := strings.Repeat("track me ", 500)
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		body           string
+		status         int
+		expectedGzip   bool
+	}{
+		{"html", "gzip, deflate, br", "text/html; charset=utf-8", long, http.StatusOK, true},
+		{"json", "gzip", "application/json", long, http.StatusOK, true},
+		{"sniffed text", "gzip", "", long, http.StatusOK, true},
+		{"not accepted", "br", "text/html", long, http.StatusOK, false},
+		{"declined", "gzip;q=0", "text/html", long, http.StatusOK, false},
+		{"image", "gzip", "image/png", long, http.StatusOK, false},
+		{"event stream", "gzip", "text/event-stream", long, http.StatusOK, false},
+		{"error", "gzip", "text/plain", long, http.StatusNotFound, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.Header().Set("ETag", `"abc"`)
+				// The type is sniffed from the body on the first write
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			gzipped := w.Header().Get("Content-Encoding") == "gzip"
+			if gzipped != tt.expectedGzip {
+				t.Fatalf("Content-Encoding = %q; expected gzip %v", w.Header().Get("Content-Encoding"), tt.expectedGzip)
+			}
+			if w.Header().Get("Vary") != "Accept-Encoding" {
+				t.Errorf("Vary = %q; expected Accept-Encoding", w.Header().Get("Vary"))
+			}
+			body := w.Body.String()
+			if gzipped {
+				if w.Header().Get("ETag") != `W/"abc"` {
+					t.Errorf("ETag = %s; expected a weak tag", w.Header().Get("ETag"))
+				}
+				reader, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatalf("gzip.NewReader() error = %v", err)
+				}
+				decoded, _ := io.ReadAll(reader)
+				body = string(decoded)
+			}
+			if body != tt.body {
+				t.Errorf("body has %d bytes; expected %d", len(body), len(tt.body))
+			}
+		})
+	}
+}
+
+func TestCompressSkipsSmallBodies(t *testing.T) {
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", "2")
+		io.WriteString(w, "{}")
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != "{}" {
+		t.Errorf("small body was compressed: %v %q", w.Header(), w.Body.String())
+	}
+}
+
+func TestCompressEmptyBody(t *testing.T) {
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusOK)
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "" || w.Body.Len() != 0 {
+		t.Errorf("empty body = %d %v %q; expected an empty, uncompressed 200", w.Code, w.Header(), w.Body.String())
+	}
+}
+
+func TestCompressNotModified(t *testing.T) {
+	long := strings.Repeat("track me ", 500)
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if NotModified(w, r, `"track-4-42"`, time.Time{}) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, long)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag != `W/"track-4-42"` {
+		t.Fatalf("first response = %d with ETag %s; expected 200 with a weak tag", w.Code, etag)
+	}
+
+	// The browser revalidates with the tag of the compressed copy
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("revalidation = %d with %d bytes; expected an empty 304", w.Code, w.Body.Len())
+	}
+}
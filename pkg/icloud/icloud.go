@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
+
+	"github.com/janschill/track-me/pkg/httpcache"
+	"github.com/janschill/track-me/pkg/metrics"
 )
 
 type ICloudHandler struct {
@@ -109,6 +112,13 @@ func (h *ICloudHandler) defaultGetWebAssetUrls(base_url string, photoGuids []str
 	return &assetUrlsResponse, nil
 }
 
+// The hit ratio is rate(hits) / rate(all lookups)
+var cacheLookups = metrics.NewCounterVec(
+	"icloud_cache_lookups_total",
+	"Lookups of the shared album's stream and asset URLs in the cache by result: hit or miss.",
+	"cache", "result",
+)
+
 func (h *ICloudHandler) Photos(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method is not supported.", http.StatusMethodNotAllowed)
@@ -125,9 +135,11 @@ func (h *ICloudHandler) Photos(w http.ResponseWriter, r *http.Request) {
 	var err error
 
 	if cachedStream, found := h.cache.Get(cacheKeyStream); found {
-		log.Print("Cache hit for stream")
+		slog.DebugContext(r.Context(), "Cache hit for stream")
+		cacheLookups.Inc("stream", "hit")
 		stream = cachedStream.(*StreamResponse)
 	} else {
+		cacheLookups.Inc("stream", "miss")
 		stream, err = h.getWebStream(base_url)
 		if err != nil {
 			http.Error(w, "Failed to get web stream.", http.StatusInternalServerError)
@@ -143,9 +155,11 @@ func (h *ICloudHandler) Photos(w http.ResponseWriter, r *http.Request) {
 
 	var assetsUrl *AssetUrlsResponse
 	if cachedAssets, found := h.cache.Get(cacheKeyAssets); found {
-		log.Print("Cache hit for assets")
+		slog.DebugContext(r.Context(), "Cache hit for assets")
+		cacheLookups.Inc("assets", "hit")
 		assetsUrl = cachedAssets.(*AssetUrlsResponse)
 	} else {
+		cacheLookups.Inc("assets", "miss")
 		assetsUrl, err = h.getWebAssetUrls(base_url, photoGuids)
 		if err != nil {
 			http.Error(w, "Failed to get web asset URLs.", http.StatusInternalServerError)
@@ -180,6 +194,11 @@ func (h *ICloudHandler) Photos(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Media URLs are fetched again every 10 minutes
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if httpcache.NotModified(w, r, httpcache.ETagOf(response), time.Time{}) {
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	_, err = w.Write(response)
 	if err != nil {
@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCounterVec(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("requests_total", "Requests by route.", "route", "status")
+	c.Inc("/", "200")
+	c.Inc("/", "200")
+	c.Add(3, "/track", "500")
+
+	if c.Value("/", "200") != 2 {
+		t.Errorf("Value() = %v; expected 2", c.Value("/", "200"))
+	}
+
+	var b strings.Builder
+	r.WriteText(&b)
+	expected := `# HELP requests_total Requests by route.
+# TYPE requests_total counter
+requests_total{route="/",status="200"} 2
+requests_total{route="/track",status="500"} 3
+`
+	if b.String() != expected {
+		t.Errorf("WriteText() =\n%s\nexpected\n%s", b.String(), expected)
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogramVec("duration_seconds", "Durations.", []float64{0.1, 1}, "op")
+	h.Observe(0.05, "select")
+	h.Observe(0.1, "select")
+	h.Observe(0.5, "select")
+	h.Observe(3, "select")
+
+	var b strings.Builder
+	r.WriteText(&b)
+	expected := `# HELP duration_seconds Durations.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{op="select",le="0.1"} 2
+duration_seconds_bucket{op="select",le="1"} 3
+duration_seconds_bucket{op="select",le="+Inf"} 4
+duration_seconds_sum{op="select"} 3.65
+duration_seconds_count{op="select"} 4
+`
+	if b.String() != expected {
+		t.Errorf("WriteText() =\n%s\nexpected\n%s", b.String(), expected)
+	}
+}
+
+func TestGaugeFunc(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    float64
+		expected string
+	}{
+		{"value", 42.5, "# HELP age_seconds Age.\n# TYPE age_seconds gauge\nage_seconds 42.5\n"},
+		{"unknown", math.NaN(), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			r.NewGaugeFunc("age_seconds", "Age.", func() float64 { return tt.value })
+			var b strings.Builder
+			r.WriteText(&b)
+			if b.String() != tt.expected {
+				t.Errorf("WriteText() = %q; expected %q", b.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("escaped_total", "Help with \\ and\nnewline.", "path")
+	c.Inc("a\"b\\c\nd")
+
+	var b strings.Builder
+	r.WriteText(&b)
+	for _, line := range []string{
+		`# HELP escaped_total Help with \\ and\nnewline.`,
+		`escaped_total{path="a\"b\\c\nd"} 1`,
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("WriteText() =\n%s\nexpected line %s", b.String(), line)
+		}
+	}
+}
+
+func TestRegistryOrderAndDuplicates(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("b_total", "B.")
+	r.NewCounterVec("a_total", "A.")
+
+	var b strings.Builder
+	r.WriteText(&b)
+	if strings.Index(b.String(), "a_total") > strings.Index(b.String(), "b_total") {
+		t.Errorf("WriteText() =\n%s\nexpected metrics sorted by name", b.String())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("registering a_total twice did not panic")
+		}
+	}()
+	r.NewCounterVec("a_total", "A again.")
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("hits_total", "Hits.").Inc()
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("Handler() = %d %s; expected the text format", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), "hits_total 1\n") {
+		t.Errorf("body = %q; expected the counter", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d; expected 405", w.Code)
+	}
+}
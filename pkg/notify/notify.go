@@ -0,0 +1,114 @@
+// Package notify sends alerts by email through an SMTP relay or as JSON to a
+// webhook.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+type Alert struct {
+	Subject string
+	Text    string
+	// Details for machines reading webhooks, e.g. the level of an alert
+	Fields map[string]any
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// SMTP mails alerts, usually through a relay on the same host
+type SMTP struct {
+	// host:port of the relay
+	Addr string
+	From string
+	To   []string
+	// Optional, PLAIN authentication is only used over TLS or to localhost
+	Username string
+	Password string
+}
+
+func (s *SMTP) Notify(ctx context.Context, alert Alert) error {
+	if len(s.To) == 0 {
+		return errors.New("notify: no recipients")
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, _ := strings.Cut(s.Addr, ":")
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+
+	// smtp.SendMail cannot be cancelled, it is left to finish in the background
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.Addr, auth, s.From, s.To, s.message(alert, time.Now()))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *SMTP) message(alert Alert, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", alert.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(alert.Text, "\r\n", "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// Webhook posts alerts as JSON objects with the subject, the text and the
+// fields of the alert
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+func (h *Webhook) Notify(ctx context.Context, alert Alert) error {
+	body := make(map[string]any, len(alert.Fields)+2)
+	for k, v := range alert.Fields {
+		body[k] = v
+	}
+	body["subject"] = alert.Subject
+	body["text"] = alert.Text
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notify: webhook answered %s", resp.Status)
+	}
+	return nil
+}
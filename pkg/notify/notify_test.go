@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhook(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{"accepted", http.StatusNoContent, false},
+		{"failed", http.StatusBadGateway, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received map[string]any
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Content-Type") != "application/json" {
+					t.Errorf("Content-Type = %q; expected application/json", r.Header.Get("Content-Type"))
+				}
+				json.NewDecoder(r.Body).Decode(&received)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			hook := &Webhook{URL: server.URL}
+			err := hook.Notify(context.Background(), Alert{Subject: "Tracker silent", Text: "For 3h", Fields: map[string]any{"level": 2}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Notify() error = %v; wantErr %v", err, tt.wantErr)
+			}
+			if received["subject"] != "Tracker silent" || received["text"] != "For 3h" || received["level"] != float64(2) {
+				t.Errorf("received %v; expected the subject, text and fields", received)
+			}
+		})
+	}
+}
+
+// fakeRelay accepts one mail without TLS or authentication and returns its data
+func fakeRelay(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	data := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch command := strings.ToUpper(strings.Fields(line)[0]); command {
+			case "EHLO", "HELO":
+				reply("250 localhost")
+			case "DATA":
+				reply("354 go ahead")
+				var b strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					b.WriteString(line)
+				}
+				data <- b.String()
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return l.Addr().String(), data
+}
+
+func TestSMTP(t *testing.T) {
+	addr, data := fakeRelay(t)
+	s := &SMTP{Addr: addr, From: "trackme@example.com", To: []string{"rider@example.com", "home@example.com"}}
+	err := s.Notify(context.Background(), Alert{Subject: "Tracker silent – 3h", Text: "No position\nsince noon"})
+	if err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	select {
+	case mail := <-data:
+		for _, expected := range []string{
+			"From: trackme@example.com\r\n",
+			"To: rider@example.com, home@example.com\r\n",
+			"Subject: =?utf-8?q?Tracker_silent_=E2=80=93_3h?=\r\n",
+			"\r\n\r\nNo position\r\nsince noon\r\n",
+		} {
+			if !strings.Contains(mail, expected) {
+				t.Errorf("mail =\n%s\nexpected %q", mail, expected)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no mail received")
+	}
+}
+
+func TestSMTPWithoutRecipients(t *testing.T) {
+	s := &SMTP{Addr: "127.0.0.1:1", From: "trackme@example.com"}
+	if err := s.Notify(context.Background(), Alert{Subject: "Test"}); err == nil {
+		t.Errorf("Notify() error = nil; expected an error without recipients")
+	}
+}
@@ -0,0 +1,80 @@
+// Package polyline implements Google's encoded polyline algorithm format.
+// https://developers.google.com/maps/documentation/utilities/polylinealgorithm
+package polyline
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+var ErrInvalid = errors.New("polyline: invalid encoding")
+
+// Encode turns [latitude, longitude] pairs into an encoded polyline with 5 decimals precision
+func Encode(points [][2]float64) string {
+	var b strings.Builder
+	var prevLat, prevLng int64
+	for _, p := range points {
+		lat := int64(math.Round(p[0] * 1e5))
+		lng := int64(math.Round(p[1] * 1e5))
+		encodeValue(&b, lat-prevLat)
+		encodeValue(&b, lng-prevLng)
+		prevLat, prevLng = lat, lng
+	}
+	return b.String()
+}
+
+func encodeValue(b *strings.Builder, v int64) {
+	v <<= 1
+	if v < 0 {
+		v = ^v
+	}
+	for v >= 0x20 {
+		b.WriteByte(byte((0x20 | (v & 0x1f)) + 63))
+		v >>= 5
+	}
+	b.WriteByte(byte(v + 63))
+}
+
+// Decode turns an encoded polyline back into [latitude, longitude] pairs
+func Decode(s string) ([][2]float64, error) {
+	var points [][2]float64
+	var lat, lng int64
+	for i := 0; i < len(s); {
+		dLat, n, err := decodeValue(s[i:])
+		if err != nil {
+			return nil, err
+		}
+		i += n
+		dLng, n, err := decodeValue(s[i:])
+		if err != nil {
+			return nil, err
+		}
+		i += n
+
+		lat += dLat
+		lng += dLng
+		points = append(points, [2]float64{float64(lat) / 1e5, float64(lng) / 1e5})
+	}
+	return points, nil
+}
+
+func decodeValue(s string) (int64, int, error) {
+	var result int64
+	var shift uint
+	for i := 0; i < len(s); i++ {
+		c := int64(s[i]) - 63
+		if c < 0 || c > 0x3f {
+			return 0, 0, ErrInvalid
+		}
+		result |= (c & 0x1f) << shift
+		shift += 5
+		if c < 0x20 {
+			if result&1 != 0 {
+				return ^(result >> 1), i + 1, nil
+			}
+			return result >> 1, i + 1, nil
+		}
+	}
+	return 0, 0, ErrInvalid
+}
@@ -0,0 +1,39 @@
+package polyline
+
+import (
+	"math"
+	"testing"
+)
+
+// Example from the algorithm documentation
+var examplePoints = [][2]float64{{38.5, -120.2}, {40.7, -120.95}, {43.252, -126.453}}
+
+const exampleEncoded = "_p~iF~ps|U_ulLnnqC_mqNvxq`@"
+
+func TestEncode(t *testing.T) {
+	if got := Encode(examplePoints); got != exampleEncoded {
+		t.Errorf("Encode() = %q; expected %q", got, exampleEncoded)
+	}
+	if got := Encode(nil); got != "" {
+		t.Errorf("Encode(nil) = %q; expected empty string", got)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	points, err := Decode(exampleEncoded)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if len(points) != len(examplePoints) {
+		t.Fatalf("Decode() returned %d points; expected %d", len(points), len(examplePoints))
+	}
+	for i, p := range points {
+		if math.Abs(p[0]-examplePoints[i][0]) > 1e-5 || math.Abs(p[1]-examplePoints[i][1]) > 1e-5 {
+			t.Errorf("Decode()[%d] = %v; expected %v", i, p, examplePoints[i])
+		}
+	}
+
+	if _, err := Decode("_p~iF~ps|"); err == nil {
+		t.Errorf("Decode() expected an error for a truncated polyline")
+	}
+}
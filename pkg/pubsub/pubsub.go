@@ -0,0 +1,144 @@
+// Package pubsub is an in-process publish/subscribe hub with topics.
+//
+// Every message gets an id that increases across all topics. Each topic keeps
+// its most recent messages so a subscriber that reconnects can pass the last id
+// it received and get what it missed. Publishing never blocks: a subscriber
+// whose buffer is full is dropped and has to resubscribe.
+package pubsub
+
+import (
+	"sync"
+	"time"
+)
+
+type Message struct {
+	ID    uint64
+	Topic string
+	Type  string
+	Data  []byte
+}
+
+type Subscription struct {
+	// Receives the messages of the topic, closed when the subscription ends
+	C     <-chan Message
+	c     chan Message
+	topic string
+}
+
+type topic struct {
+	subscribers map[*Subscription]struct{}
+	history     []Message
+	// Id of the latest message that is no longer kept
+	evicted uint64
+}
+
+type Hub struct {
+	mu     sync.Mutex
+	topics map[string]*topic
+	lastID uint64
+	// Ids of this hub are above startID
+	startID     uint64
+	historySize int
+	bufferSize  int
+}
+
+// New creates a hub that keeps historySize messages per topic for replays and
+// buffers up to bufferSize messages per subscriber
+func New(historySize, bufferSize int) *Hub {
+	// Starting at the clock keeps ids increasing across restarts
+	start := uint64(time.Now().UnixNano())
+	return &Hub{
+		topics:      make(map[string]*topic),
+		lastID:      start,
+		startID:     start,
+		historySize: historySize,
+		bufferSize:  bufferSize,
+	}
+}
+
+func (h *Hub) topic(name string) *topic {
+	t, ok := h.topics[name]
+	if !ok {
+		t = &topic{subscribers: make(map[*Subscription]struct{})}
+		h.topics[name] = t
+	}
+	return t
+}
+
+// Publish sends a message to all subscribers of a topic and returns its id
+func (h *Hub) Publish(topicName, messageType string, data []byte) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	m := Message{ID: h.lastID, Topic: topicName, Type: messageType, Data: data}
+	t := h.topic(topicName)
+	t.history = append(t.history, m)
+	if len(t.history) > h.historySize {
+		t.evicted = t.history[0].ID
+		t.history = append(t.history[:0], t.history[1:]...)
+	}
+	for s := range t.subscribers {
+		select {
+		case s.c <- m:
+		default:
+			h.remove(t, s)
+		}
+	}
+	return m.ID
+}
+
+// Subscribe starts receiving the messages of a topic. With a lastID other than 0
+// it also returns the kept messages published after it; complete is false when
+// messages after lastID are no longer kept, the subscriber has to reload then.
+func (h *Hub) Subscribe(topicName string, lastID uint64) (s *Subscription, missed []Message, complete bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c := make(chan Message, h.bufferSize)
+	s = &Subscription{C: c, c: c, topic: topicName}
+	t := h.topic(topicName)
+	t.subscribers[s] = struct{}{}
+
+	if lastID == 0 {
+		return s, nil, true
+	}
+	// Ids outside of this hub's range were handed out by a previous process
+	complete = lastID >= h.startID && lastID <= h.lastID && lastID >= t.evicted
+	for _, m := range t.history {
+		if m.ID > lastID {
+			missed = append(missed, m)
+		}
+	}
+	return s, missed, complete
+}
+
+// Unsubscribe ends a subscription, it may be called more than once
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if t, ok := h.topics[s.topic]; ok {
+		h.remove(t, s)
+	}
+}
+
+func (h *Hub) remove(t *topic, s *Subscription) {
+	if _, ok := t.subscribers[s]; !ok {
+		return
+	}
+	delete(t.subscribers, s)
+	close(s.c)
+	if len(t.subscribers) == 0 && len(t.history) == 0 && t.evicted == 0 {
+		delete(h.topics, s.topic)
+	}
+}
+
+// Subscribers returns the number of subscribers of a topic
+func (h *Hub) Subscribers(topicName string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if t, ok := h.topics[topicName]; ok {
+		return len(t.subscribers)
+	}
+	return 0
+}
@@ -0,0 +1,131 @@
+package pubsub
+
+import (
+	"sync"
+	"testing"
+)
+
+func receive(t *testing.T, s *Subscription) Message {
+	t.Helper()
+	select {
+	case m, ok := <-s.C:
+		if !ok {
+			t.Fatal("subscription closed; expected a message")
+		}
+		return m
+	default:
+		t.Fatal("no message; expected one")
+	}
+	return Message{}
+}
+
+func TestPublishToTopic(t *testing.T) {
+	hub := New(10, 10)
+	first, _, _ := hub.Subscribe("trip/1", 0)
+	other, _, _ := hub.Subscribe("trip/2", 0)
+
+	id := hub.Publish("trip/1", "position", []byte(`{}`))
+	m := receive(t, first)
+	if m.ID != id || m.Type != "position" || string(m.Data) != `{}` {
+		t.Errorf("received %+v; expected position %d", m, id)
+	}
+	select {
+	case m := <-other.C:
+		t.Errorf("other topic received %+v; expected nothing", m)
+	default:
+	}
+	if next := hub.Publish("trip/2", "message", nil); next <= id {
+		t.Errorf("Publish() = %d; expected an id above %d", next, id)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	hub := New(3, 10)
+	var ids []uint64
+	for i := 0; i < 5; i++ {
+		ids = append(ids, hub.Publish("trip/1", "position", nil))
+		hub.Publish("trip/2", "position", nil)
+	}
+
+	tests := []struct {
+		name             string
+		lastID           uint64
+		expectedMissed   int
+		expectedComplete bool
+	}{
+		{"new subscriber", 0, 0, true},
+		{"up to date", ids[4], 0, true},
+		{"missed one", ids[3], 1, true},
+		{"missed all kept", ids[1], 3, true},
+		{"missed more than kept", ids[0], 3, false},
+		{"previous process", 1, 3, false},
+		{"unknown id", ids[4] + 100, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, missed, complete := hub.Subscribe("trip/1", tt.lastID)
+			defer hub.Unsubscribe(s)
+			if len(missed) != tt.expectedMissed || complete != tt.expectedComplete {
+				t.Errorf("Subscribe(%d) missed %d, complete %v; expected %d, %v", tt.lastID, len(missed), complete, tt.expectedMissed, tt.expectedComplete)
+			}
+			for i := 1; i < len(missed); i++ {
+				if missed[i].ID <= missed[i-1].ID {
+					t.Errorf("missed messages out of order: %d after %d", missed[i].ID, missed[i-1].ID)
+				}
+			}
+		})
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	hub := New(0, 2)
+	slow, _, _ := hub.Subscribe("trip/1", 0)
+	for i := 0; i < 3; i++ {
+		hub.Publish("trip/1", "position", nil)
+	}
+
+	received := 0
+	for range slow.C {
+		received++
+	}
+	if received != 2 {
+		t.Errorf("slow subscriber received %d messages; expected 2 before being dropped", received)
+	}
+	if n := hub.Subscribers("trip/1"); n != 0 {
+		t.Errorf("Subscribers() = %d; expected 0", n)
+	}
+	// Unsubscribing a dropped subscriber is harmless
+	hub.Unsubscribe(slow)
+}
+
+func TestConcurrentPublish(t *testing.T) {
+	hub := New(5, 1000)
+	s, _, _ := hub.Subscribe("trip/1", 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				hub.Publish("trip/1", "position", nil)
+			}
+		}()
+	}
+	wg.Wait()
+	hub.Unsubscribe(s)
+
+	var last uint64
+	received := 0
+	for m := range s.C {
+		if m.ID <= last {
+			t.Fatalf("received %d after %d; expected increasing ids", m.ID, last)
+		}
+		last = m.ID
+		received++
+	}
+	if received != 500 {
+		t.Errorf("received %d messages; expected 500", received)
+	}
+}
@@ -0,0 +1,179 @@
+// Package tz finds the IANA time zone of a position without network access.
+//
+// Boundaries are read from GeoJSON in the format of timezone-boundary-builder,
+// a FeatureCollection of Polygon and MultiPolygon features with a "tzid"
+// property. The embedded dataset is a simplified outline of the zones of the
+// contiguous US and western Canada; a full export can be passed to Load instead.
+// Positions outside all boundaries fall back to the nautical zone of their longitude.
+package tz
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	// Zone names must resolve on hosts without a tz database
+	_ "time/tzdata"
+)
+
+//go:embed timezones.geojson
+var embedded string
+
+type geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+type featureCollection struct {
+	Features []struct {
+		Properties struct {
+			Tzid string `json:"tzid"`
+		} `json:"properties"`
+		Geometry geometry `json:"geometry"`
+	} `json:"features"`
+}
+
+// A polygon is an outer ring followed by its holes, points are [longitude, latitude]
+type polygon [][][2]float64
+
+type zone struct {
+	name                           string
+	polygons                       []polygon
+	minLat, maxLat, minLon, maxLon float64
+}
+
+type Finder struct {
+	zones []zone
+}
+
+// Load reads time zone boundaries from GeoJSON. Zones are matched in the order of
+// their features, so smaller zones enclosed by others have to come first.
+func Load(r io.Reader) (*Finder, error) {
+	var collection featureCollection
+	if err := json.NewDecoder(r).Decode(&collection); err != nil {
+		return nil, fmt.Errorf("tz: invalid boundaries: %w", err)
+	}
+
+	f := &Finder{}
+	for _, feature := range collection.Features {
+		z := zone{
+			name:   feature.Properties.Tzid,
+			minLat: math.Inf(1), maxLat: math.Inf(-1),
+			minLon: math.Inf(1), maxLon: math.Inf(-1),
+		}
+		switch feature.Geometry.Type {
+		case "Polygon":
+			var p polygon
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &p); err != nil {
+				return nil, fmt.Errorf("tz: invalid polygon of %s: %w", z.name, err)
+			}
+			z.polygons = []polygon{p}
+		case "MultiPolygon":
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &z.polygons); err != nil {
+				return nil, fmt.Errorf("tz: invalid multipolygon of %s: %w", z.name, err)
+			}
+		default:
+			return nil, fmt.Errorf("tz: unsupported geometry %q of %s", feature.Geometry.Type, z.name)
+		}
+
+		for _, p := range z.polygons {
+			if len(p) == 0 {
+				continue
+			}
+			for _, point := range p[0] {
+				z.minLon = math.Min(z.minLon, point[0])
+				z.maxLon = math.Max(z.maxLon, point[0])
+				z.minLat = math.Min(z.minLat, point[1])
+				z.maxLat = math.Max(z.maxLat, point[1])
+			}
+		}
+		f.zones = append(f.zones, z)
+	}
+	return f, nil
+}
+
+var (
+	defaultOnce   sync.Once
+	defaultFinder *Finder
+)
+
+// Default returns the finder for the embedded boundaries
+func Default() *Finder {
+	defaultOnce.Do(func() {
+		f, err := Load(strings.NewReader(embedded))
+		if err != nil {
+			panic(err)
+		}
+		defaultFinder = f
+	})
+	return defaultFinder
+}
+
+// inRing tests with ray casting whether a point lies inside a closed ring
+func inRing(ring [][2]float64, lat, lon float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a[1] > lat) != (b[1] > lat) && lon < (b[0]-a[0])*(lat-a[1])/(b[1]-a[1])+a[0] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func (p polygon) contains(lat, lon float64) bool {
+	if len(p) == 0 || !inRing(p[0], lat, lon) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if inRing(hole, lat, lon) {
+			return false
+		}
+	}
+	return true
+}
+
+// Lookup returns the zone whose boundary contains the position. ok is false when
+// no boundary matched and the nautical zone of the longitude was returned.
+func (f *Finder) Lookup(lat, lon float64) (name string, ok bool) {
+	for _, z := range f.zones {
+		if lat < z.minLat || lat > z.maxLat || lon < z.minLon || lon > z.maxLon {
+			continue
+		}
+		for _, p := range z.polygons {
+			if p.contains(lat, lon) {
+				return z.name, true
+			}
+		}
+	}
+	return Nautical(lon), false
+}
+
+// Location loads the zone of a position, see Lookup
+func (f *Finder) Location(lat, lon float64) *time.Location {
+	name, _ := f.Lookup(lat, lon)
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Nautical returns the Etc zone of the 15° band of a longitude.
+// The sign of Etc zones is inverted, Etc/GMT+7 is seven hours behind UTC.
+func Nautical(lon float64) string {
+	offset := int(math.Round(lon / 15))
+	switch {
+	case offset == 0:
+		return "Etc/GMT"
+	case offset < 0:
+		return fmt.Sprintf("Etc/GMT+%d", -offset)
+	default:
+		return fmt.Sprintf("Etc/GMT-%d", offset)
+	}
+}
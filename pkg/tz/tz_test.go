@@ -0,0 +1,89 @@
+package tz
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat, lon float64
+		expected string
+	}{
+		{"Banff", 51.18, -115.57, "America/Edmonton"},
+		{"Fernie", 49.50, -115.06, "America/Edmonton"},
+		{"Vancouver", 49.28, -123.12, "America/Vancouver"},
+		{"Coeur d'Alene", 47.68, -116.78, "America/Los_Angeles"},
+		{"Missoula", 46.87, -113.99, "America/Denver"},
+		{"Boise", 43.62, -116.20, "America/Boise"},
+		{"Steamboat Springs", 40.48, -106.83, "America/Denver"},
+		{"Phoenix", 33.45, -112.07, "America/Phoenix"},
+		{"El Paso", 31.76, -106.49, "America/Denver"},
+		{"Antelope Wells", 31.3337, -108.53, "America/Denver"},
+		{"Chicago", 41.88, -87.70, "America/Chicago"},
+		{"New York", 40.71, -74.00, "America/New_York"},
+	}
+
+	f := Default()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, ok := f.Lookup(tt.lat, tt.lon)
+			if !ok || name != tt.expected {
+				t.Errorf("Lookup(%v, %v) = %q, %v; expected %q, true", tt.lat, tt.lon, name, ok, tt.expected)
+			}
+			if _, err := time.LoadLocation(name); err != nil {
+				t.Errorf("LoadLocation(%q) error = %v", name, err)
+			}
+		})
+	}
+}
+
+func TestLookupFallsBackToNautical(t *testing.T) {
+	// Middle of the Atlantic
+	name, ok := Default().Lookup(40, -40)
+	if ok || name != "Etc/GMT+3" {
+		t.Errorf("Lookup() = %q, %v; expected Etc/GMT+3, false", name, ok)
+	}
+}
+
+func TestNautical(t *testing.T) {
+	tests := []struct {
+		lon      float64
+		expected string
+	}{
+		{0, "Etc/GMT"},
+		{-105, "Etc/GMT+7"},
+		{13.4, "Etc/GMT-1"},
+		{179, "Etc/GMT-12"},
+	}
+
+	for _, tt := range tests {
+		if result := Nautical(tt.lon); result != tt.expected {
+			t.Errorf("Nautical(%v) = %q; expected %q", tt.lon, result, tt.expected)
+		}
+	}
+}
+
+func TestLoadMultiPolygonWithHole(t *testing.T) {
+	boundaries := `{"type":"FeatureCollection","features":[
+		{"type":"Feature","properties":{"tzid":"Europe/Berlin"},"geometry":{"type":"MultiPolygon","coordinates":[
+			[[[0,0],[10,0],[10,10],[0,10],[0,0]],[[4,4],[6,4],[6,6],[4,6],[4,4]]]
+		]}}
+	]}`
+	f, err := Load(strings.NewReader(boundaries))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if name, ok := f.Lookup(2, 2); !ok || name != "Europe/Berlin" {
+		t.Errorf("Lookup(2, 2) = %q, %v; expected Europe/Berlin, true", name, ok)
+	}
+	if _, ok := f.Lookup(5, 5); ok {
+		t.Errorf("Lookup(5, 5) matched the hole of a polygon")
+	}
+
+	if _, err := Load(strings.NewReader(`{"features":[{"geometry":{"type":"Point"}}]}`)); err == nil {
+		t.Errorf("Load() accepted a point geometry")
+	}
+}
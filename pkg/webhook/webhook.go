@@ -0,0 +1,124 @@
+// Package webhook posts events signed with HMAC-SHA256 to webhook endpoints
+// and verifies the signature on the receiving side.
+//
+// The signature covers the timestamp and the body, "<timestamp>.<body>", so a
+// captured request cannot be replayed later with a new timestamp.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Unix seconds the request was signed at
+	TimestampHeader = "X-Trackme-Timestamp"
+	// "sha256=" followed by the hex HMAC of the timestamp and the body
+	SignatureHeader = "X-Trackme-Signature"
+	// Type of the event, e.g. "sos"
+	EventHeader = "X-Trackme-Event"
+	// Stays the same across retries, receivers can drop duplicates with it
+	DeliveryHeader = "X-Trackme-Delivery"
+
+	signaturePrefix = "sha256="
+	// Length of the response body kept for the delivery log
+	maxResponseExcerpt = 256
+)
+
+var (
+	ErrSignature = errors.New("webhook: signature does not match")
+	ErrExpired   = errors.New("webhook: timestamp outside the tolerance")
+)
+
+// Sign returns the value of the signature header for a body sent at timestamp
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and timestamp headers of a received body.
+// Requests signed more than tolerance before or after now are rejected.
+func Verify(secret string, header http.Header, body []byte, now time.Time, tolerance time.Duration) error {
+	timestamp, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return fmt.Errorf("webhook: invalid timestamp: %w", err)
+	}
+	if diff := now.Sub(time.Unix(timestamp, 0)); diff > tolerance || diff < -tolerance {
+		return ErrExpired
+	}
+	if !hmac.Equal([]byte(header.Get(SignatureHeader)), []byte(Sign(secret, timestamp, body))) {
+		return ErrSignature
+	}
+	return nil
+}
+
+// Delivery is one event posted to an endpoint
+type Delivery struct {
+	ID     string
+	Event  string
+	URL    string
+	Secret string
+	// JSON
+	Body []byte
+}
+
+// Response is what the endpoint answered
+type Response struct {
+	Status int
+	// Start of the body, for the delivery log
+	Body string
+}
+
+// Send posts a delivery signed at now. Any answer but 2xx is an error, the
+// response is returned with it.
+func Send(ctx context.Context, client *http.Client, d Delivery, now time.Time) (Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		return Response{}, err
+	}
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "track-me-webhooks")
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(d.Secret, timestamp, d.Body))
+	req.Header.Set(EventHeader, d.Event)
+	req.Header.Set(DeliveryHeader, d.ID)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseExcerpt))
+	// Drain a little more so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	response := Response{Status: resp.StatusCode, Body: strings.ToValidUTF8(string(excerpt), "")}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return response, fmt.Errorf("webhook: endpoint answered %s", resp.Status)
+	}
+	return response, nil
+}
+
+// Backoff is the delay before retrying after the given number of failed
+// attempts: base doubled for every attempt after the first, at most max
+func Backoff(attempts int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	return min(delay, max)
+}
@@ -0,0 +1,119 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	// echo -n '1700000000.{"event":"sos"}' | openssl dgst -sha256 -hmac secret
+	expected := "sha256=92fe7b1c6c030365026fdf1cd5d72119bdf58da94d932c60155b438b4a38b6c2"
+	if got := Sign("secret", 1700000000, []byte(`{"event":"sos"}`)); got != expected {
+		t.Errorf("Sign() = %q; expected %q", got, expected)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"event":"sos"}`)
+	signed := func(timestamp int64, secret string, body []byte) http.Header {
+		header := http.Header{}
+		header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+		header.Set(SignatureHeader, Sign(secret, timestamp, body))
+		return header
+	}
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		wantErr error
+	}{
+		{"valid", signed(now.Unix(), "secret", body), nil},
+		{"within tolerance", signed(now.Unix()-299, "secret", body), nil},
+		{"other secret", signed(now.Unix(), "other", body), ErrSignature},
+		{"other body", signed(now.Unix(), "secret", []byte(`{"event":"kudos.milestone"}`)), ErrSignature},
+		{"too old", signed(now.Unix()-301, "secret", body), ErrExpired},
+		{"from the future", signed(now.Unix()+301, "secret", body), ErrExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify("secret", tt.header, body, now, 5*time.Minute)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v; expected %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := Verify("secret", http.Header{}, body, now, 5*time.Minute); err == nil {
+		t.Errorf("Verify() without headers error = nil; expected an error")
+	}
+}
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{"accepted", http.StatusAccepted, false},
+		{"failed", http.StatusServiceUnavailable, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if err := Verify("secret", r.Header, body, now, time.Minute); err != nil {
+					t.Errorf("Verify() error = %v", err)
+				}
+				if r.Header.Get(EventHeader) != "sos" || r.Header.Get(DeliveryHeader) != "42" {
+					t.Errorf("event %q, delivery %q; expected sos and 42", r.Header.Get(EventHeader), r.Header.Get(DeliveryHeader))
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte("busy"))
+			}))
+			defer server.Close()
+
+			response, err := Send(context.Background(), server.Client(), Delivery{
+				ID:     "42",
+				Event:  "sos",
+				URL:    server.URL,
+				Secret: "secret",
+				Body:   []byte(`{"event":"sos"}`),
+			}, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v; wantErr %v", err, tt.wantErr)
+			}
+			if response.Status != tt.status || response.Body != "busy" {
+				t.Errorf("Send() = %+v; expected status %d and body busy", response, tt.status)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{4, 4 * time.Minute},
+		{9, 2 * time.Hour},
+		{100, 2 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts, 30*time.Second, 2*time.Hour); got != tt.expected {
+			t.Errorf("Backoff(%d) = %v; expected %v", tt.attempts, got, tt.expected)
+		}
+	}
+}
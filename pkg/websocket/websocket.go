@@ -0,0 +1,380 @@
+// Package websocket is a minimal server side implementation of RFC 6455.
+//
+// It covers what the live map needs: the opening handshake, text and binary
+// messages split into fragments, ping/pong and the closing handshake. Extensions
+// like compression and subprotocols are not supported.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Magic value of the handshake from RFC 6455 section 1.3
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Opcodes
+const (
+	continuationFrame = 0x0
+	TextMessage       = 0x1
+	BinaryMessage     = 0x2
+	CloseMessage      = 0x8
+	PingMessage       = 0x9
+	PongMessage       = 0xA
+)
+
+// Close codes
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseNoStatus        = 1005
+	CloseInvalidData     = 1007
+	ClosePolicyViolation = 1008
+	CloseTooBig          = 1009
+)
+
+// Control frames carry at most 125 bytes
+const maxControlPayload = 125
+
+// DefaultMaxMessageSize limits incoming messages unless set otherwise on a Conn
+const DefaultMaxMessageSize = 64 * 1024
+
+// CloseError is returned by ReadMessage once the peer closed the connection
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: closed with %d %s", e.Code, e.Reason)
+}
+
+var ErrClosed = errors.New("websocket: connection closed")
+
+type Conn struct {
+	conn net.Conn
+	br   *bufio.Reader
+	// Clients mask their frames, servers must not
+	client bool
+	// Incoming messages above this size close the connection with CloseTooBig
+	MaxMessageSize int64
+	// Reading fails when the peer sends no frame for this long, pongs included.
+	// Zero waits forever.
+	IdleTimeout time.Duration
+
+	writeMu sync.Mutex
+	closed  bool
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, client bool) *Conn {
+	return &Conn{conn: conn, br: br, client: client, MaxMessageSize: DefaultMaxMessageSize}
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameOrigin reports whether a browser opened the connection from a page of
+// this host. Browsers do not apply CORS to WebSockets, so any page could
+// otherwise connect with the visitor's cookies. Clients other than browsers
+// send no Origin.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Upgrade completes the opening handshake and takes over the connection. On
+// failure it has already answered the request with an error.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method is not supported.", http.StatusMethodNotAllowed)
+		return nil, errors.New("websocket: method is not GET")
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "Expected a WebSocket upgrade", http.StatusBadRequest)
+		return nil, errors.New("websocket: not an upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	if !sameOrigin(r) {
+		http.Error(w, "Cross origin WebSocket is not allowed", http.StatusForbidden)
+		return nil, errors.New("websocket: origin does not match host")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "Invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("websocket: invalid key")
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "WebSocket is not supported", http.StatusInternalServerError)
+		return nil, fmt.Errorf("websocket: %w", err)
+	}
+	// Deadlines of the server do not apply to a hijacked connection
+	conn.SetDeadline(time.Time{})
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: handshake failed: %w", err)
+	}
+	return newConn(conn, rw.Reader, false), nil
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// writeFrame sends a single unfragmented frame
+func (c *Conn) writeFrame(opcode int, payload []byte, deadline time.Time) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+
+	header := make([]byte, 2, 14)
+	header[0] = 0x80 | byte(opcode)
+	switch length := len(payload); {
+	case length <= 125:
+		header[1] = byte(length)
+	case length <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	data := payload
+	if c.client {
+		header[1] |= 0x80
+		var mask [4]byte
+		binary.BigEndian.PutUint32(mask[:], uint32(time.Now().UnixNano()))
+		header = append(header, mask[:]...)
+		data = make([]byte, len(payload))
+		copy(data, payload)
+		maskBytes(mask, data)
+	}
+
+	c.conn.SetWriteDeadline(deadline)
+	if _, err := c.conn.Write(append(header, data...)); err != nil {
+		return err
+	}
+	if opcode == CloseMessage {
+		c.closed = true
+	}
+	return nil
+}
+
+func maskBytes(mask [4]byte, data []byte) {
+	for i := range data {
+		data[i] ^= mask[i%4]
+	}
+}
+
+// WriteMessage sends a text or binary message, waiting at most until deadline
+func (c *Conn) WriteMessage(messageType int, data []byte, deadline time.Time) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+	return c.writeFrame(messageType, data, deadline)
+}
+
+// Ping asks the peer for a pong, which ReadMessage reads and discards
+func (c *Conn) Ping(deadline time.Time) error {
+	return c.writeFrame(PingMessage, nil, deadline)
+}
+
+// Close sends a close frame with a code and reason and closes the connection
+// without waiting for the peer's answer
+func (c *Conn) Close(code int, reason string, deadline time.Time) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > maxControlPayload {
+		payload = payload[:maxControlPayload]
+	}
+	err := c.writeFrame(CloseMessage, payload, deadline)
+	c.conn.Close()
+	if errors.Is(err, ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+type frame struct {
+	fin     bool
+	opcode  int
+	payload []byte
+}
+
+func (c *Conn) readFrame() (frame, error) {
+	if c.IdleTimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.IdleTimeout))
+	}
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return frame{}, err
+	}
+	f := frame{fin: head[0]&0x80 != 0, opcode: int(head[0] & 0x0F)}
+	if head[0]&0x70 != 0 {
+		return f, &CloseError{Code: CloseProtocolError, Reason: "reserved bits set"}
+	}
+	masked := head[1]&0x80 != 0
+	if masked == c.client {
+		return f, &CloseError{Code: CloseProtocolError, Reason: "wrong masking"}
+	}
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return f, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return f, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if f.opcode >= CloseMessage && (length > maxControlPayload || !f.fin) {
+		return f, &CloseError{Code: CloseProtocolError, Reason: "invalid control frame"}
+	}
+	if length > uint64(c.MaxMessageSize) {
+		return f, &CloseError{Code: CloseTooBig, Reason: "message too big"}
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return f, err
+		}
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, f.payload); err != nil {
+		return f, err
+	}
+	if masked {
+		maskBytes(mask, f.payload)
+	}
+	return f, nil
+}
+
+// nextDataFrame reads frames until one that belongs to a message, answering
+// pings and the closing handshake on the way
+func (c *Conn) nextDataFrame() (frame, error) {
+	for {
+		f, err := c.readFrame()
+		if err != nil {
+			var closeErr *CloseError
+			if errors.As(err, &closeErr) {
+				c.Close(closeErr.Code, closeErr.Reason, time.Now().Add(time.Second))
+			}
+			return f, err
+		}
+
+		switch f.opcode {
+		case PingMessage:
+			if err := c.writeFrame(PongMessage, f.payload, time.Now().Add(time.Second)); err != nil {
+				return f, err
+			}
+		case PongMessage:
+		case CloseMessage:
+			closeErr := &CloseError{Code: CloseNoStatus}
+			if len(f.payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(f.payload))
+				closeErr.Reason = string(f.payload[2:])
+			}
+			// Echo the code to complete the closing handshake
+			code := closeErr.Code
+			if code == CloseNoStatus {
+				code = CloseNormal
+			}
+			c.Close(code, "", time.Now().Add(time.Second))
+			return f, closeErr
+		case continuationFrame, TextMessage, BinaryMessage:
+			return f, nil
+		default:
+			return f, c.fail(CloseProtocolError, "unknown opcode")
+		}
+	}
+}
+
+// ReadMessage returns the next text or binary message. Once the connection is
+// closed it returns a *CloseError with the peer's code, or with the code it
+// closed the connection with after a protocol violation.
+func (c *Conn) ReadMessage() (messageType int, data []byte, err error) {
+	f, err := c.nextDataFrame()
+	if err != nil {
+		return 0, nil, err
+	}
+	if f.opcode == continuationFrame {
+		return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+	}
+
+	messageType, data = f.opcode, f.payload
+	for !f.fin {
+		if f, err = c.nextDataFrame(); err != nil {
+			return 0, nil, err
+		}
+		if f.opcode != continuationFrame {
+			return 0, nil, c.fail(CloseProtocolError, "expected a continuation frame")
+		}
+		if int64(len(data)+len(f.payload)) > c.MaxMessageSize {
+			return 0, nil, c.fail(CloseTooBig, "message too big")
+		}
+		data = append(data, f.payload...)
+	}
+	if messageType == TextMessage && !utf8.Valid(data) {
+		return 0, nil, c.fail(CloseInvalidData, "invalid UTF-8")
+	}
+	return messageType, data, nil
+}
+
+func (c *Conn) fail(code int, reason string) error {
+	c.Close(code, reason, time.Now().Add(time.Second))
+	return &CloseError{Code: code, Reason: reason}
+}
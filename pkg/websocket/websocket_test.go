@@ -0,0 +1,192 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoServer answers every message with the same message
+func echoServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(messageType, data, time.Now().Add(time.Second)); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func dial(t *testing.T, server *httptest.Server) (*Conn, net.Conn) {
+	t.Helper()
+	netConn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { netConn.Close() })
+	netConn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	request := "GET / HTTP/1.1\r\n" +
+		"Host: example.com\r\n" +
+		"Origin: https://example.com\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := netConn.Write([]byte(request)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	br := bufio.NewReader(netConn)
+	response, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("ReadResponse() error = %v", err)
+	}
+	// Example from RFC 6455 section 1.3
+	if response.StatusCode != http.StatusSwitchingProtocols || response.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake = %d %v; expected 101 with the accept key", response.StatusCode, response.Header)
+	}
+	return newConn(netConn, br, true), netConn
+}
+
+func TestEcho(t *testing.T) {
+	client, _ := dial(t, echoServer(t))
+	deadline := time.Now().Add(time.Second)
+
+	messages := [][]byte{
+		[]byte("hello"),
+		bytes.Repeat([]byte("a"), 300),
+		bytes.Repeat([]byte("b"), DefaultMaxMessageSize),
+	}
+	for _, message := range messages {
+		if err := client.WriteMessage(TextMessage, message, deadline); err != nil {
+			t.Fatalf("WriteMessage() error = %v", err)
+		}
+		messageType, data, err := client.ReadMessage()
+		if err != nil || messageType != TextMessage || !bytes.Equal(data, message) {
+			t.Errorf("ReadMessage() = %d, %d bytes, %v; expected the %d bytes sent", messageType, len(data), err, len(message))
+		}
+	}
+}
+
+// clientFrame encodes a short frame as a client with a zero mask
+func clientFrame(fin bool, opcode int, payload string) []byte {
+	first := byte(opcode)
+	if fin {
+		first |= 0x80
+	}
+	return append([]byte{first, 0x80 | byte(len(payload)), 0, 0, 0, 0}, payload...)
+}
+
+func TestFragmentsAndPing(t *testing.T) {
+	client, netConn := dial(t, echoServer(t))
+
+	// A ping between the fragments of a message is answered right away
+	var frames []byte
+	frames = append(frames, clientFrame(false, TextMessage, "hel")...)
+	frames = append(frames, clientFrame(true, PingMessage, "are you there")...)
+	frames = append(frames, clientFrame(true, continuationFrame, "lo")...)
+	if _, err := netConn.Write(frames); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	f, err := client.readFrame()
+	if err != nil || f.opcode != PongMessage || string(f.payload) != "are you there" {
+		t.Fatalf("readFrame() = %+v, %v; expected the pong", f, err)
+	}
+	messageType, data, err := client.ReadMessage()
+	if err != nil || messageType != TextMessage || string(data) != "hello" {
+		t.Errorf("ReadMessage() = %d, %q, %v; expected hello", messageType, data, err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	client, netConn := dial(t, echoServer(t))
+	if _, err := netConn.Write(clientFrame(true, CloseMessage, "\x03\xe8bye")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	f, err := client.readFrame()
+	if err != nil || f.opcode != CloseMessage || !bytes.Equal(f.payload, []byte{0x03, 0xE8}) {
+		t.Errorf("readFrame() = %+v, %v; expected the echoed close code 1000", f, err)
+	}
+}
+
+func TestProtocolViolations(t *testing.T) {
+	tests := []struct {
+		name         string
+		frame        []byte
+		expectedCode int
+	}{
+		{"unmasked frame", []byte{0x81, 0x02, 'h', 'i'}, CloseProtocolError},
+		{"reserved bits", []byte{0xC1, 0x80, 0, 0, 0, 0}, CloseProtocolError},
+		{"continuation without a message", clientFrame(true, continuationFrame, ""), CloseProtocolError},
+		{"unknown opcode", clientFrame(true, 0x3, ""), CloseProtocolError},
+		{"fragmented ping", clientFrame(false, PingMessage, ""), CloseProtocolError},
+		{"invalid UTF-8", clientFrame(true, TextMessage, "\xc3\x28"), CloseInvalidData},
+		{"too big", []byte{0x82, 0xFF, 0, 0, 0, 0, 0, 0x10, 0, 0, 0, 0, 0, 0}, CloseTooBig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, netConn := dial(t, echoServer(t))
+			if _, err := netConn.Write(tt.frame); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			_, _, err := client.ReadMessage()
+			var closeErr *CloseError
+			if !errors.As(err, &closeErr) || closeErr.Code != tt.expectedCode {
+				t.Errorf("ReadMessage() error = %v; expected close code %d", err, tt.expectedCode)
+			}
+		})
+	}
+}
+
+func TestUpgradeRejectsPlainRequests(t *testing.T) {
+	server := echoServer(t)
+	tests := []struct {
+		name           string
+		method         string
+		header         map[string]string
+		expectedStatus int
+	}{
+		{"plain GET", http.MethodGet, nil, http.StatusBadRequest},
+		{"POST", http.MethodPost, nil, http.StatusMethodNotAllowed},
+		{"old version", http.MethodGet, map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "8"}, http.StatusUpgradeRequired},
+		{"missing key", http.MethodGet, map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13"}, http.StatusBadRequest},
+		{"other origin", http.MethodGet, map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13",
+			"Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ==", "Origin": "https://evil.example"}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, _ := http.NewRequest(tt.method, server.URL, nil)
+			for name, value := range tt.header {
+				request.Header.Set(name, value)
+			}
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			response.Body.Close()
+			if response.StatusCode != tt.expectedStatus {
+				t.Errorf("status = %d; expected %d", response.StatusCode, tt.expectedStatus)
+			}
+		})
+	}
+}
@@ -1,15 +1,19 @@
 import { lockKudosButtons, sendKudos } from "./modules/kudos.js";
+import { followLiveUpdates } from "./modules/live.js";
 import { initializeMap } from "./modules/map.js";
 import { countCharacters, setupFormSubmission } from "./modules/messages.js";
 import { displayPhotosByDate, fetchPhotos } from "./modules/photos.js";
+import { setupProfiles } from "./modules/profile.js";
 import { convertTimestamps, updateLastPingTime, updateMovementStatus } from "./modules/time.js";
 
 document.addEventListener('DOMContentLoaded', async function () {
   initializeMap()
   setupFormSubmission()
   countCharacters()
+  setupProfiles()
   updateLastPingTime()
   updateMovementStatus()
+  followLiveUpdates()
   const photos = await fetchPhotos()
   displayPhotosByDate(photos)
   lockKudosButtons()
@@ -0,0 +1,13 @@
+// Messages of the page's locale, see internal/i18n/locales
+export function t(key, ...args) {
+  const message = (serverData.Strings || {})[key] ?? key;
+  let i = 0;
+  return message.replace(/%[sd%]/g, verb => verb === '%%' ? '%' : String(args[i++]));
+}
+
+export function formatNumber(value, decimals) {
+  return value.toLocaleString(serverData.Locale, {
+    minimumFractionDigits: decimals,
+    maximumFractionDigits: decimals,
+  });
+}
@@ -1,3 +1,6 @@
+import { t } from './i18n.js';
+import { sendLive } from './live.js';
+
 export function lockKudosButtons() {
   document.querySelectorAll('.kudos-button').forEach(button => {
     const day = button.getAttribute('onclick').match(/'([^']+)'/)[1];
@@ -12,6 +15,13 @@ export async function sendKudos(day) {
   const kudosKey = `kudos_${day}`;
   if (localStorage.getItem(kudosKey)) return;
 
+  // The new count comes back as a live update
+  if (sendLive({ type: 'kudos', day })) {
+    localStorage.setItem(kudosKey, 'true');
+    document.getElementById(`kudos-button-${day}`).classList.add('kudos-button--clicked');
+    return;
+  }
+
   try {
     const response = await fetch('/kudos', {
       method: 'POST',
@@ -30,9 +40,24 @@ export async function sendKudos(day) {
     if (kudosCountValue) {
       kudosCountValue.textContent = parseInt(kudosCountValue.textContent) + 1;
     } else {
-      kudosCountElement.textContent = '1 kudos';
+      kudosCountElement.textContent = t('kudos.one');
     }
   } catch (error) {
     console.error('Error sending kudos:', error);
   }
 }
+
+// Shows a kudos count pushed by the server
+export function setKudosCount(day, count) {
+  const kudosCountElement = document.getElementById(`kudos-count-${day}`);
+  if (!kudosCountElement) return;
+  const kudosCountValue = document.getElementById(`kudos-count-${day}-value`);
+  if (kudosCountValue) {
+    kudosCountValue.textContent = count;
+    return;
+  }
+  const value = document.createElement('span');
+  value.id = `kudos-count-${day}-value`;
+  value.textContent = count;
+  kudosCountElement.replaceChildren(value, ` ${t('kudos.count')}`);
+}
@@ -0,0 +1,88 @@
+import { setKudosCount } from './kudos.js';
+import { moveMarker, refreshTrack } from './map.js';
+import { appendMessage } from './messages.js';
+import { updateLastPingTime, updateMovementStatus } from './time.js';
+
+const reconnectDelay = 5000;
+let socket;
+
+const updates = {
+  position(position) {
+    serverData.LastEvent = {
+      ...serverData.LastEvent,
+      TimeStamp: position.timeStamp,
+      Latitude: position.latitude,
+      Longitude: position.longitude,
+      Altitude: position.altitude,
+      Speed: position.speed,
+    };
+    moveMarker(position);
+    refreshTrack();
+    updateLastPingTime();
+    updateMovementStatus();
+  },
+  message: appendMessage,
+  kudos({ day, count }) {
+    setKudosCount(day, count);
+  },
+  // Updates were missed while disconnected, the page is outdated
+  reset() {
+    window.location.reload();
+  },
+};
+
+// Follows the trip's new positions, messages and kudos over a WebSocket, or
+// Server-Sent Events where WebSockets do not get through
+export function followLiveUpdates() {
+  if (window.WebSocket) {
+    connectSocket();
+  } else if (window.EventSource) {
+    followEvents();
+  }
+}
+
+function connectSocket(lastId) {
+  const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
+  let opened = false;
+  socket = new WebSocket(`${protocol}//${window.location.host}/ws`);
+
+  socket.addEventListener('open', () => {
+    opened = true;
+    socket.send(JSON.stringify({ type: 'subscribe', trip: 1, lastId }));
+  });
+  socket.addEventListener('message', (e) => {
+    const update = JSON.parse(e.data);
+    if (update.id) lastId = update.id;
+    if (update.type === 'error') {
+      console.error('Live update error:', update.message);
+    } else if (updates[update.type]) {
+      updates[update.type](update.data);
+    }
+  });
+  socket.addEventListener('close', () => {
+    socket = undefined;
+    if (!opened) {
+      followEvents();
+      return;
+    }
+    setTimeout(() => connectSocket(lastId), reconnectDelay);
+  });
+}
+
+// EventSource reconnects on its own and sends the id of the last update
+function followEvents() {
+  const source = new EventSource('/live');
+  for (const type of Object.keys(updates)) {
+    source.addEventListener(type, (e) => {
+      if (type === 'reset') source.close();
+      updates[type](JSON.parse(e.data));
+    });
+  }
+}
+
+// Sends a message over the open WebSocket, false without one
+export function sendLive(message) {
+  if (!socket || socket.readyState !== WebSocket.OPEN) return false;
+  socket.send(JSON.stringify(message));
+  return true;
+}
@@ -1,4 +1,12 @@
 // import L from 'leaflet';
+import { formatNumber, t } from "./i18n.js";
+import { decodePolyline } from "./polyline.js";
+
+let marker;
+let loadTrack;
+let trackTimer;
+// Positions often arrive in bursts, the track is fetched once they settled
+const trackRefreshDelay = 2000;
 
 export function initializeMap() {
   const latitude = serverData.LastEvent.Latitude
@@ -11,17 +19,51 @@ export function initializeMap() {
     attribution: 'Map data &copy; <a href="https://www.openstreetmap.org/copyright">OpenStreetMap</a> contributors',
     maxZoom: 18,
   }).addTo(map);
-  // Load traveled path
-  // const events = serverData.EventsJSON
-  const events = serverData.EventsJSON
-  let path;
-  if (events && events.length > 2) {
-    const pathCoordinates = events.map(event => [event.Latitude, event.Longitude]);
-    path = L.polyline(pathCoordinates, { color: '#c43514' }).addTo(map).bringToFront();
+  // Load traveled path, simplified on the server for the current zoom
+  const path = L.polyline([], { color: '#c43514' }).addTo(map);
+  // The ETag changes with the level and with new events
+  let trackETag;
+  loadTrack = async function () {
+    try {
+      const response = await fetch(`/track?zoom=${map.getZoom()}`);
+      if (!response.ok) throw new Error('Failed to load track');
+      const etag = response.headers.get('ETag');
+      if (etag && etag === trackETag) return;
+      const track = await response.json();
+      trackETag = etag;
+      path.setLatLngs(decodePolyline(track.polyline)).bringToFront();
+    } catch (error) {
+      console.error('Error loading track:', error);
+    }
+  };
+  map.on('zoomend', loadTrack);
+  loadTrack();
+
+  // Mark climbs from their bottom to their top
+  const units = serverData.Units;
+  for (const climb of serverData.Climbs || []) {
+    const name = climb.Category === 'HC' ? 'HC' : t('climb.category', climb.Category);
+    const length = formatNumber(climb.LengthInMeters / units.distanceFactor, 1);
+    const gain = formatNumber(climb.ElevationGain / units.elevationFactor, 0);
+    const popup = t('climb.popup', name, length, units.distance, gain, units.elevation,
+      formatNumber(climb.AverageGrade, 1), formatNumber(climb.MaxGrade, 1));
+    L.circleMarker([climb.StartLatitude, climb.StartLongitude], {
+      radius: 3, color: '#7b1fa2',
+    }).addTo(map).bindPopup(popup);
+    L.circleMarker([climb.EndLatitude, climb.EndLongitude], {
+      radius: 6, color: '#7b1fa2', fillOpacity: 0.8,
+    }).addTo(map).bindPopup(popup);
+  }
+
+  // Mark where each day ended
+  for (const overnight of serverData.Overnights || []) {
+    L.circleMarker([overnight.Latitude, overnight.Longitude], {
+      radius: 5, color: '#1f4e79', fillOpacity: 0.8,
+    }).addTo(map).bindPopup(`${t('label.overnight')}: ${overnight.Place}`);
   }
 
   // Load full planned route
-  const url = '/static/gpx/Great_Divide_2024.gpx'
+  const url = serverData.Route
   new L.GPX(url, {
     async: true,
     markers: {
@@ -36,9 +78,7 @@ export function initializeMap() {
     }
   }).on('loaded', function (e) {
     map.fitBounds(e.target.getBounds());
-    if (events && events.length > 2) {
-      path.bringToFront();
-    }
+    path.bringToFront();
   }).addTo(map).bringToBack();
 
   const elevation_options = {
@@ -48,14 +88,29 @@ export function initializeMap() {
     downloadLink: false,
     waypoints: false,
     distanceMarkers: false,
+    imperial: serverData.Units.system === 'imperial',
   }
   const controlElevation = L.control.elevation(elevation_options).addTo(map);
   controlElevation.load(url)
   const customIcon = L.icon({
-    iconUrl: '/static/images/marker.png',
+    iconUrl: serverData.Marker,
     iconSize: [35, 56],
     iconAnchor: [12, 41],
     popupAnchor: [1, -34],
   });
-  L.marker([latitude, longitude], { icon: customIcon }).addTo(map).openPopup();
+  marker = L.marker([latitude, longitude], { icon: customIcon }).addTo(map);
+  if (serverData.Place) marker.bindPopup(serverData.Place);
+}
+
+// Fetches the track again after live positions extended it
+export function refreshTrack() {
+  if (!loadTrack) return;
+  clearTimeout(trackTimer);
+  trackTimer = setTimeout(loadTrack, trackRefreshDelay);
+}
+
+// Moves the marker to a live position, the place of the popup is not known yet
+export function moveMarker({ latitude, longitude }) {
+  if (!marker) return;
+  marker.setLatLng([latitude, longitude]).unbindPopup();
 }
@@ -1,3 +1,5 @@
+import { t } from './i18n.js';
+
 export function countCharacters() {
   const messageInput = document.getElementById('message');
   const charCount = document.getElementById('charCount');
@@ -75,14 +77,27 @@ export function setupFormSubmission() {
   emailElement.addEventListener('input', updateButtonState);
 }
 
-function appendMessage({ name, message }) {
+// Messages shown since the page loaded, a sent message also comes back live
+const appendedMessages = new Set();
+
+function escapeHTML(text) {
+  const element = document.createElement('div');
+  element.textContent = text;
+  return element.innerHTML;
+}
+
+export function appendMessage({ name, message, timeStamp, fromGarmin }) {
+  const key = `${timeStamp}|${name}|${message}`;
+  if (appendedMessages.has(key)) return;
+  appendedMessages.add(key);
+
   const messagesList = document.getElementById('messagesList');
   const messageElement = `
-    <li class="box">
+    <li class="box${fromGarmin ? ' box--border' : ''}">
       <header class="box__header box__header--baseline">
-        <h3 class="box__title ft-l">${name} wrote</h3>
+        <h3 class="box__title ft-l">${escapeHTML(fromGarmin ? name : t('message.wrote', name))}</h3>
       </header>
-      <section><p>${message}</p></section>
+      <section><p>${escapeHTML(message)}</p></section>
     </li>
   `;
   messagesList.insertAdjacentHTML('afterbegin', messageElement);
@@ -1,3 +1,5 @@
+import { t } from './i18n.js';
+
 export async function fetchPhotos() {
   try {
     const response = await fetch('/photos');
@@ -70,7 +72,7 @@ function handleHighResImageDisplay({ target }) {
 
   const closeButton = document.createElement('button');
   closeButton.className = 'close-button button';
-  closeButton.innerText = 'Close';
+  closeButton.innerText = t('photos.close');
   closeButton.addEventListener('click', closeHighResImage);
 
   overlay.append(highResImg, closeButton);
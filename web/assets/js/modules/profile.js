@@ -0,0 +1,66 @@
+import { t } from './i18n.js';
+
+const width = 300;
+const height = 80;
+
+function elevationPath(points) {
+  const distance = points[points.length - 1].distance || 1;
+  const altitudes = points.map(p => p.altitude);
+  const min = Math.min(...altitudes);
+  const range = Math.max(...altitudes) - min || 1;
+  return points.map((p, i) => {
+    const x = (p.distance / distance) * width;
+    const y = height - ((p.altitude - min) / range) * height;
+    return `${i === 0 ? 'M' : 'L'}${x.toFixed(1)},${y.toFixed(1)}`;
+  }).join(' ');
+}
+
+function bandLabel(band, unit) {
+  if (band.from === null) return `< ${band.to} ${unit}`;
+  if (band.to === null) return `≥ ${band.from} ${unit}`;
+  return `${band.from} – ${band.to} ${unit}`;
+}
+
+function bandList(bands, unit) {
+  const total = bands.reduce((sum, b) => sum + b.seconds, 0) || 1;
+  return bands
+    .filter(b => b.seconds > 0)
+    .map(b => `<li>${bandLabel(b, unit)}: ${Math.round(b.seconds / total * 100)} %</li>`)
+    .join('');
+}
+
+async function showProfile(day, container) {
+  const date = day.querySelector('.day-date').value;
+  try {
+    const [profile, summary] = await Promise.all([
+      fetch(`/profile?date=${date}`).then(r => r.ok ? r.json() : null),
+      fetch(`/profile/summary?date=${date}`).then(r => r.ok ? r.json() : null),
+    ]);
+    if (!profile || !summary || (profile.points || []).length < 2) {
+      container.textContent = t('profile.none');
+      return;
+    }
+    container.innerHTML = `
+      <svg class="profile__chart" viewBox="0 0 ${width} ${height}" preserveAspectRatio="none">
+        <path d="${elevationPath(profile.points)}" fill="none" stroke="#c43514" stroke-width="1.5" />
+      </svg>
+      <div class="row row-flex">
+        <ul><small class="label">${t('profile.time-at-speed')}</small>${bandList(summary.speedBands, summary.units.speed)}</ul>
+        <ul><small class="label">${t('profile.time-on-grade')}</small>${bandList(summary.gradeBands, '%')}</ul>
+      </div>`;
+  } catch (error) {
+    console.error(`Failed to load profile for ${date}:`, error);
+  }
+}
+
+export function setupProfiles() {
+  document.querySelectorAll('.days-container ol li').forEach(day => {
+    const button = day.querySelector('.profile-button');
+    const container = day.querySelector('.profile');
+    if (!button || !container) return;
+    button.addEventListener('click', () => {
+      if (container.classList.toggle('hidden')) return;
+      if (!container.hasChildNodes()) showProfile(day, container);
+    });
+  });
+}
@@ -1,14 +1,23 @@
+import { t } from './i18n.js';
+
 export function convertTimestamps() {
   document.querySelectorAll('.box__subtitle[data-timestamp]').forEach(element => {
     const date = new Date(parseInt(element.dataset.timestamp) * 1000);
-    const formattedDate = date.toLocaleString('en-GB', {
+    const timeZone = serverData.Timezone || undefined;
+    // British English writes the day before the month like the server does
+    const locale = serverData.Locale === 'en' ? 'en-GB' : serverData.Locale;
+    const day = date.toLocaleDateString(locale, {
       day: '2-digit',
       month: 'long',
+      timeZone
+    });
+    const time = date.toLocaleTimeString(locale, {
       hour: '2-digit',
       minute: '2-digit',
-      hour12: false
-    }).replace(',', ' at');
-    element.textContent = `on ${formattedDate}`;
+      hour12: false,
+      timeZone
+    });
+    element.textContent = t('format.on-day-at-time', day, time);
   });
 }
 
@@ -16,7 +25,7 @@ export function updateLastPingTime() {
   const lastPingUnix = serverData.LastEvent.TimeStamp;
   const lastPingElement = document.querySelector('#lastPing');
   if (!lastPingUnix) {
-    lastPingElement.textContent = `N/A`;
+    lastPingElement.textContent = t('not-available');
     return
   }
   const lastPingDate = new Date(lastPingUnix * 1000);
@@ -25,13 +34,13 @@ export function updateLastPingTime() {
   const diffInMinutes = Math.floor(diffInSeconds / 60);
   const diffInHours = Math.floor(diffInMinutes / 60);
   if (diffInHours < 0) {
-    lastPingElement.textContent = `N/A`;
+    lastPingElement.textContent = t('not-available');
   } else if (diffInHours > 0) {
-    lastPingElement.textContent = `${diffInHours} hour(s) ago`;
+    lastPingElement.textContent = t('time.hours-ago', diffInHours);
   } else if (diffInMinutes > 0) {
-    lastPingElement.textContent = `${diffInMinutes} minute(s) ago`;
+    lastPingElement.textContent = t('time.minutes-ago', diffInMinutes);
   } else {
-    lastPingElement.textContent = `${diffInSeconds} second(s) ago`;
+    lastPingElement.textContent = t('time.seconds-ago', diffInSeconds);
   }
 }
 
@@ -39,5 +48,5 @@ export function updateMovementStatus() {
   const { TimeStamp: lastPingUnix, Speed } = serverData.LastEvent;
   const lastPingDate = new Date(lastPingUnix * 1000);
   const isMoving = (new Date() - lastPingDate <= 10 * 60 * 1000) && Speed > 0;
-  document.getElementById('isMoving').textContent = isMoving ? 'Yes' : 'No';
+  document.getElementById('isMoving').textContent = t(isMoving ? 'yes' : 'no');
 }
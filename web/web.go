@@ -0,0 +1,39 @@
+// Package web holds the page templates and static assets, embedded in the binary
+// so a deployment needs nothing next to it.
+package web
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+//go:embed templates assets
+var files embed.FS
+
+// FS returns the templates under templates/ and the assets under assets/. In dev
+// mode they are read from web/ in the working directory so edits show up
+// without rebuilding.
+func FS(dev bool) fs.FS {
+	if dev {
+		return os.DirFS("web")
+	}
+	return files
+}
+
+// Open opens a file from disk, falling back to the embedded copy for paths
+// below web/ that are not on disk
+func Open(path string) (fs.File, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if name, ok := strings.CutPrefix(path, "web/"); ok {
+			return files.Open(name)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}